@@ -0,0 +1,465 @@
+package searchast
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/andersonjoseph/searchast/language"
+)
+
+// cacheFormat is part of every cache key and must be incremented whenever
+// the cached data, or the way scopes and tokens are computed, changes.
+const cacheFormat = 2
+
+// defaultCacheMaxBytes is the default size limit of a ParseCache.
+const defaultCacheMaxBytes = 256 << 20
+
+// pruneInterval is the minimum time between two prunes by PruneIfDue.
+const pruneInterval = time.Hour
+
+// pruneStamp is the file of the cache directory whose modification time is
+// the time of the last prune.
+const pruneStamp = "pruned"
+
+// cacheTag is the file marking a directory as a parse cache, in the format of
+// the Cache Directory Tagging Specification so that backup tools skip it.
+// Prune and Clean refuse to touch directories without it.
+const cacheTag = "CACHEDIR.TAG"
+
+// cacheTagContent is the content of the cacheTag file.
+const cacheTagContent = "Signature: 8a477f597d28d172789f06886806bc55\n" +
+	"# This file is a cache directory tag created by searchast.\n" +
+	"# For information about cache directory tags, see https://bford.info/cachedir/\n"
+
+// ParseCache stores the scopes and syntax highlighting tokens computed for
+// source files in a directory, so that files that did not change are not
+// parsed again. Entries are keyed by a hash of the content of the file, the
+// language and version of its grammar, and the version of searchast.
+type ParseCache struct {
+	dir      string
+	maxBytes int64
+
+	// stored reports whether an entry was written, which is the only way for
+	// the cache to grow.
+	stored atomic.Bool
+}
+
+type ParseCacheOption func(*ParseCache)
+
+// NewParseCache returns a cache storing its entries in dir, which is created
+// when the first entry is written.
+func NewParseCache(dir string, opts ...ParseCacheOption) *ParseCache {
+	pc := &ParseCache{
+		dir:      dir,
+		maxBytes: defaultCacheMaxBytes,
+	}
+
+	for _, opt := range opts {
+		opt(pc)
+	}
+
+	return pc
+}
+
+// WithCacheMaxBytes sets the size above which Prune removes the least
+// recently used entries. Zero disables the limit.
+func WithCacheMaxBytes(n int64) ParseCacheOption {
+	return func(pc *ParseCache) {
+		pc.maxBytes = n
+	}
+}
+
+// DefaultCacheDir returns the searchast directory of the user cache
+// directory, e.g. ~/.cache/searchast on Linux.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the user cache directory: %w", err)
+	}
+
+	return filepath.Join(dir, "searchast"), nil
+}
+
+// cacheEntry is what is stored for a file. The text of the lines is not
+// stored as it is read from the file anyway.
+type cacheEntry struct {
+	Lines []cacheLine
+}
+
+type cacheLine struct {
+	Parent   uint32
+	Start    uint32
+	End      uint32
+	Kind     string
+	Kinds    []string
+	Category string
+	Tokens   []cacheToken
+}
+
+type cacheToken struct {
+	Start int
+	End   int
+	Class string
+}
+
+// NewSourceTree is like the NewSourceTree function, but reuses the cached
+// scopes and tokens of the content if there are some, and caches them
+// otherwise. The cache is skipped when an entry can't be read or written.
+func (pc *ParseCache) NewSourceTree(ctx context.Context, r io.Reader, filename string) (*sourceTree, error) {
+	sourceCode, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filename, err)
+	}
+
+	path, err := pc.entryPath(sourceCode, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if st, ok := pc.load(path, sourceCode); ok {
+		if st.lang, err = language.FromFilename(filename); err != nil {
+			return nil, fmt.Errorf("failed to determine language for file %s: %w", filename, err)
+		}
+		if st.langName, err = language.NameFromFilename(filename); err != nil {
+			return nil, fmt.Errorf("failed to determine language for file %s: %w", filename, err)
+		}
+		return st, nil
+	}
+
+	st, err := parseSourceTree(ctx, sourceCode, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// Entries hold the tokens, so that they are available to every later
+	// run, whatever its output format.
+	st.ensureHighlighted()
+	_ = pc.store(path, st) // a missing entry only costs a parse
+
+	return st, nil
+}
+
+// entryPath returns the path of the entry of sourceCode.
+func (pc *ParseCache) entryPath(sourceCode []byte, filename string) (string, error) {
+	name, err := language.NameFromFilename(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to determine language for file %s: %w", filename, err)
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%d\x00%s\x00%s\x00%s\x00", cacheFormat, cacheVersion(), name, language.GrammarVersion(name))
+	hash.Write(sourceCode)
+	key := hex.EncodeToString(hash.Sum(nil))
+
+	return filepath.Join(pc.dir, key[:2], key[2:]), nil
+}
+
+// load returns the cached tree of sourceCode. Entries are touched when read
+// so that Prune removes the least recently used ones first.
+func (pc *ParseCache) load(path string, sourceCode []byte) (*sourceTree, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry); err != nil {
+		return nil, false
+	}
+
+	st := newSourceTree(sourceCode)
+	if len(entry.Lines) != len(st.lines) {
+		return nil, false
+	}
+
+	for i, cached := range entry.Lines {
+		st.lines[i].scope = scope{
+			parent:   cached.Parent,
+			start:    cached.Start,
+			end:      cached.End,
+			kind:     cached.Kind,
+			kinds:    cached.Kinds,
+			category: cached.Category,
+		}
+		for _, t := range cached.Tokens {
+			st.lines[i].tokens = append(st.lines[i].tokens, token{start: t.Start, end: t.End, class: t.Class})
+		}
+	}
+
+	st.highlighted = true
+
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+
+	return st, true
+}
+
+// store writes the entry of st. The entry is written to a temporary file
+// first, so that concurrent searches never read a partial entry.
+func (pc *ParseCache) store(path string, st *sourceTree) error {
+	entry := cacheEntry{Lines: make([]cacheLine, len(st.lines))}
+	for i, l := range st.lines {
+		cached := cacheLine{
+			Parent:   l.scope.parent,
+			Start:    l.scope.start,
+			End:      l.scope.end,
+			Kind:     l.scope.kind,
+			Kinds:    l.scope.kinds,
+			Category: l.scope.category,
+		}
+		for _, t := range l.tokens {
+			cached.Tokens = append(cached.Tokens, cacheToken{Start: t.start, End: t.end, Class: t.class})
+		}
+		entry.Lines[i] = cached
+	}
+
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(entry); err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := pc.tag(); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".entry-*")
+	if err != nil {
+		return fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	pc.stored.Store(true)
+
+	return nil
+}
+
+// Prune removes the least recently used entries until the cache is no
+// larger than its size limit, and records the time of the prune for
+// PruneIfDue. Only the entries of the cache are considered, other files of
+// its directory are left alone.
+func (pc *ParseCache) Prune() error {
+	if pc.maxBytes <= 0 {
+		return nil
+	}
+
+	entries, err := pc.entries()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, entry := range entries {
+		total += entry.size
+	}
+
+	slices.SortFunc(entries, func(a, b cacheFile) int {
+		return a.modTime.Compare(b.modTime)
+	})
+
+	for _, entry := range entries {
+		if total <= pc.maxBytes {
+			break
+		}
+
+		if err := os.Remove(entry.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove cache entry: %w", err)
+		}
+		total -= entry.size
+	}
+
+	if err := os.WriteFile(filepath.Join(pc.dir, pruneStamp), nil, 0o644); err != nil {
+		return fmt.Errorf("failed to record the prune time: %w", err)
+	}
+
+	return nil
+}
+
+// cacheFile is an entry file of the cache directory.
+type cacheFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// entries returns the entry files of the cache, which are stored as
+// <2 hex digits>/<62 hex digits> in its directory. It fails if the directory
+// isn't tagged as a parse cache.
+func (pc *ParseCache) entries() ([]cacheFile, error) {
+	tag, err := os.ReadFile(filepath.Join(pc.dir, cacheTag))
+	if errors.Is(err, fs.ErrNotExist) {
+		if _, statErr := os.Stat(pc.dir); errors.Is(statErr, fs.ErrNotExist) {
+			return nil, statErr
+		}
+	}
+	if err != nil || string(tag) != cacheTagContent {
+		return nil, fmt.Errorf("%s is not a searchast cache directory, %s is missing", pc.dir, cacheTag)
+	}
+
+	dirs, err := os.ReadDir(pc.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	var entries []cacheFile
+	for _, dir := range dirs {
+		if !dir.IsDir() || !isHex(dir.Name(), 2) {
+			continue
+		}
+
+		files, err := os.ReadDir(filepath.Join(pc.dir, dir.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cache directory: %w", err)
+		}
+		for _, file := range files {
+			if !file.Type().IsRegular() || !isHex(file.Name(), sha256.Size*2-2) {
+				continue
+			}
+
+			info, err := file.Info()
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read cache directory: %w", err)
+			}
+			entries = append(entries, cacheFile{
+				path:    filepath.Join(pc.dir, dir.Name(), file.Name()),
+				size:    info.Size(),
+				modTime: info.ModTime(),
+			})
+		}
+	}
+
+	return entries, nil
+}
+
+// tag writes the cacheTag file of the cache directory, if it is missing.
+func (pc *ParseCache) tag() error {
+	path := filepath.Join(pc.dir, cacheTag)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	if err := os.WriteFile(path, []byte(cacheTagContent), 0o644); err != nil {
+		return fmt.Errorf("failed to tag cache directory: %w", err)
+	}
+
+	return nil
+}
+
+// isHex reports whether s is made of n lowercase hexadecimal digits.
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// PruneIfDue prunes the cache if entries were written through pc and the
+// cache was not pruned in the last hour, so that runs reusing cached entries
+// don't walk the whole cache directory.
+func (pc *ParseCache) PruneIfDue() error {
+	if !pc.stored.Load() {
+		return nil
+	}
+
+	info, err := os.Stat(filepath.Join(pc.dir, pruneStamp))
+	if err == nil && time.Since(info.ModTime()) < pruneInterval {
+		return nil
+	}
+
+	return pc.Prune()
+}
+
+// Clean removes every entry of the cache, and its directory if nothing else
+// is left in it. Other files of the directory are left alone.
+func (pc *ParseCache) Clean() error {
+	entries, err := pc.entries()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := os.Remove(entry.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove cache entry: %w", err)
+		}
+		_ = os.Remove(filepath.Dir(entry.path)) // only removed once empty
+	}
+
+	for _, name := range []string{pruneStamp, cacheTag} {
+		if err := os.Remove(filepath.Join(pc.dir, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove cache directory: %w", err)
+		}
+	}
+	_ = os.Remove(pc.dir) // only removed once empty
+
+	return nil
+}
+
+// cacheVersion identifies the build of searchast in cache keys: the module
+// version when searchast is a dependency or was installed with go install,
+// and the VCS revision for local builds.
+var cacheVersion = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+
+	module := &info.Main
+	for _, dep := range info.Deps {
+		if dep.Path == "github.com/andersonjoseph/searchast" {
+			module = dep
+		}
+	}
+	if module.Version != "" && module.Version != "(devel)" {
+		return module.Version
+	}
+
+	settings := make(map[string]string)
+	for _, setting := range info.Settings {
+		settings[setting.Key] = setting.Value
+	}
+
+	return cmp.Or(settings["vcs.revision"], "devel") + settings["vcs.modified"]
+})
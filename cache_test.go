@@ -0,0 +1,277 @@
+package searchast
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cacheEntries returns the paths of the entries of a cache directory.
+func cacheEntries(t *testing.T, dir string) []string {
+	t.Helper()
+
+	var entries []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && d.Name() != pruneStamp && d.Name() != cacheTag {
+			entries = append(entries, path)
+		}
+		return err
+	})
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("failed to read cache directory: %v", err)
+	}
+
+	return entries
+}
+
+func TestParseCache_NewSourceTree(t *testing.T) {
+	source := `package main
+
+func main() {
+	if true {
+		println("hello")
+	}
+}`
+
+	t.Run("caches the computed lines", func(t *testing.T) {
+		dir := t.TempDir()
+		pc := NewParseCache(dir)
+
+		parsed, err := pc.NewSourceTree(t.Context(), strings.NewReader(source), "test.go")
+		if err != nil {
+			t.Fatalf("failed to create sourceTree: %v", err)
+		}
+		if entries := cacheEntries(t, dir); len(entries) != 1 {
+			t.Fatalf("expected 1 cache entry, got %v", entries)
+		}
+
+		cached, err := pc.NewSourceTree(t.Context(), strings.NewReader(source), "test.go")
+		if err != nil {
+			t.Fatalf("failed to create sourceTree: %v", err)
+		}
+
+		expected := mustNewSourceTree(t, source).Lines()
+		if !reflect.DeepEqual(parsed.lines, expected) || !reflect.DeepEqual(cached.lines, expected) {
+			t.Errorf("expected cached lines to equal the parsed ones")
+		}
+	})
+
+	t.Run("keys entries by content and language", func(t *testing.T) {
+		dir := t.TempDir()
+		pc := NewParseCache(dir)
+
+		for _, file := range []struct{ source, filename string }{
+			{source, "test.go"},
+			{source + "\n", "test.go"},
+			{source, "test.js"},
+		} {
+			if _, err := pc.NewSourceTree(t.Context(), strings.NewReader(file.source), file.filename); err != nil {
+				t.Fatalf("failed to create sourceTree: %v", err)
+			}
+		}
+
+		if entries := cacheEntries(t, dir); len(entries) != 3 {
+			t.Errorf("expected 3 cache entries, got %v", entries)
+		}
+	})
+
+	t.Run("parses files again when an entry is corrupted", func(t *testing.T) {
+		dir := t.TempDir()
+		pc := NewParseCache(dir)
+
+		if _, err := pc.NewSourceTree(t.Context(), strings.NewReader(source), "test.go"); err != nil {
+			t.Fatalf("failed to create sourceTree: %v", err)
+		}
+		entries := cacheEntries(t, dir)
+		if err := os.WriteFile(entries[0], []byte("corrupted"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		st, err := pc.NewSourceTree(t.Context(), strings.NewReader(source), "test.go")
+		if err != nil {
+			t.Fatalf("failed to create sourceTree: %v", err)
+		}
+		if !reflect.DeepEqual(st.lines, mustNewSourceTree(t, source).Lines()) {
+			t.Errorf("expected the file to be parsed again")
+		}
+	})
+
+	t.Run("returns an error for unsupported languages", func(t *testing.T) {
+		if _, err := NewParseCache(t.TempDir()).NewSourceTree(t.Context(), strings.NewReader(source), "test.unknown"); err == nil {
+			t.Error("expected an error, but got none")
+		}
+	})
+}
+
+func TestParseCache_Prune(t *testing.T) {
+	dir := t.TempDir()
+	pc := NewParseCache(dir)
+
+	sources := []string{"package a\n", "package b\n", "package c\n"}
+	for i, source := range sources {
+		if _, err := pc.NewSourceTree(t.Context(), strings.NewReader(source), "test.go"); err != nil {
+			t.Fatalf("failed to create sourceTree: %v", err)
+		}
+
+		// the first source is the least recently used
+		path, err := pc.entryPath([]byte(source), "test.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		used := time.Now().Add(time.Duration(i-len(sources)) * time.Hour)
+		if err := os.Chtimes(path, used, used); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries := cacheEntries(t, dir)
+	info, err := os.Stat(entries[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pc = NewParseCache(dir, WithCacheMaxBytes(2*info.Size()))
+	if err := pc.Prune(); err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+
+	if entries := cacheEntries(t, dir); len(entries) != 2 {
+		t.Fatalf("expected 2 cache entries, got %v", entries)
+	}
+	first, err := pc.entryPath([]byte(sources[0]), "test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Errorf("expected the least recently used entry to be removed")
+	}
+}
+
+func TestParseCache_PruneIfDue(t *testing.T) {
+	dir := t.TempDir()
+	stamp := filepath.Join(dir, pruneStamp)
+
+	// pc writes an entry larger than the size limit.
+	pc := NewParseCache(dir, WithCacheMaxBytes(1))
+	if _, err := pc.NewSourceTree(t.Context(), strings.NewReader("package a\n"), "test.go"); err != nil {
+		t.Fatalf("failed to create sourceTree: %v", err)
+	}
+
+	reader := NewParseCache(dir, WithCacheMaxBytes(1))
+	if _, err := reader.NewSourceTree(t.Context(), strings.NewReader("package a\n"), "test.go"); err != nil {
+		t.Fatalf("failed to create sourceTree: %v", err)
+	}
+	if err := reader.PruneIfDue(); err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+	if entries := cacheEntries(t, dir); len(entries) != 1 {
+		t.Fatalf("expected a cache only read from not to be pruned, got %v", entries)
+	}
+
+	if err := os.WriteFile(stamp, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := pc.PruneIfDue(); err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+	if entries := cacheEntries(t, dir); len(entries) != 1 {
+		t.Fatalf("expected a recently pruned cache not to be pruned, got %v", entries)
+	}
+
+	pruned := time.Now().Add(-2 * pruneInterval)
+	if err := os.Chtimes(stamp, pruned, pruned); err != nil {
+		t.Fatal(err)
+	}
+	if err := pc.PruneIfDue(); err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+	if entries := cacheEntries(t, dir); len(entries) != 0 {
+		t.Errorf("expected the cache to be pruned, got %v", entries)
+	}
+}
+
+func TestParseCache_Clean(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	pc := NewParseCache(dir)
+
+	if _, err := pc.NewSourceTree(t.Context(), strings.NewReader("package main\n"), "test.go"); err != nil {
+		t.Fatalf("failed to create sourceTree: %v", err)
+	}
+	if err := pc.Clean(); err != nil {
+		t.Fatalf("failed to clean: %v", err)
+	}
+
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected the cache directory to be removed")
+	}
+	if err := pc.Prune(); err != nil {
+		t.Errorf("expected pruning a missing cache to succeed, got %v", err)
+	}
+}
+
+func TestParseCache_ForeignFiles(t *testing.T) {
+	dir := t.TempDir()
+	foreign := []string{"main.go", ".searchast.toml", "ab/notes.txt", "cd/" + strings.Repeat("0", 62) + ".bak"}
+	for _, name := range foreign {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("package main\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Directories that weren't created as a cache are never touched.
+	pc := NewParseCache(dir, WithCacheMaxBytes(1))
+	if err := pc.Prune(); err == nil {
+		t.Error("expected pruning a directory which isn't a cache to fail")
+	}
+	if err := pc.Clean(); err == nil {
+		t.Error("expected cleaning a directory which isn't a cache to fail")
+	}
+
+	if _, err := pc.NewSourceTree(t.Context(), strings.NewReader("package main\n"), "test.go"); err != nil {
+		t.Fatalf("failed to create sourceTree: %v", err)
+	}
+	if err := pc.Prune(); err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+	if entries := cacheEntries(t, dir); len(entries) != len(foreign) {
+		t.Errorf("expected only the foreign files to be left after pruning, got %v", entries)
+	}
+
+	if _, err := pc.NewSourceTree(t.Context(), strings.NewReader("package main\n"), "test.go"); err != nil {
+		t.Fatalf("failed to create sourceTree: %v", err)
+	}
+	if err := pc.Clean(); err != nil {
+		t.Fatalf("failed to clean: %v", err)
+	}
+	for _, name := range foreign {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s to be left by Clean: %v", name, err)
+		}
+	}
+	if entries := cacheEntries(t, dir); len(entries) != len(foreign) {
+		t.Errorf("expected only the foreign files to be left after cleaning, got %v", entries)
+	}
+}
+
+func TestParseCache_NoLimit(t *testing.T) {
+	dir := t.TempDir()
+	pc := NewParseCache(dir, WithCacheMaxBytes(0))
+
+	if _, err := pc.NewSourceTree(t.Context(), strings.NewReader("package main\n"), "test.go"); err != nil {
+		t.Fatalf("failed to create sourceTree: %v", err)
+	}
+	if err := pc.Prune(); err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+	if entries := cacheEntries(t, dir); len(entries) != 1 {
+		t.Errorf("expected a cache without size limit to keep its entries, got %v", entries)
+	}
+}
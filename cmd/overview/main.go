@@ -8,16 +8,21 @@ import (
 	"os"
 
 	"github.com/andersonjoseph/searchast"
+	"github.com/andersonjoseph/searchast/config"
 )
 
 func main() {
 	var (
-		filename  string
-		colorFlag string
+		filename   string
+		colorFlag  string
+		configPath string
+		noConfig   bool
 	)
 
 	flag.StringVar(&filename, "filename", "", "Source code file to search (required)")
 	flag.StringVar(&colorFlag, "color", "auto", "Color output: auto, always, never")
+	flag.StringVar(&configPath, "config", "", "Configuration file to use instead of the discovered ones")
+	flag.BoolVar(&noConfig, "no-config", false, "Do not load any configuration file")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
@@ -33,6 +38,17 @@ func main() {
 		os.Exit(1)
 	}
 
+	cfg, err := config.Resolve(configPath, noConfig)
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+	if err := cfg.Apply(flag.CommandLine); err != nil {
+		log.Fatalf("Error applying configuration: %v", err)
+	}
+	if err := cfg.RegisterLanguages(); err != nil {
+		log.Fatalf("Error registering languages: %v", err)
+	}
+
 	f, err := os.Open(filename)
 	if err != nil {
 		log.Fatalf("Error opening source file '%s': %v", filename, err)
@@ -53,13 +69,7 @@ func main() {
 		log.Fatalf("No matches found")
 	}
 
-	overivewContextBuilder := searchast.NewContextBuilder(
-		searchast.WithSurroundingLines(2),
-		searchast.WithParentContext(false),
-		searchast.WithCloseScopeGaps(false),
-		searchast.WithExpandChildScopes(false),
-		searchast.WithChildLines(3),
-	)
+	overivewContextBuilder := searchast.NewContextBuilder(searchast.OverviewOptions()...)
 
 	linesToShow := overivewContextBuilder.AddContext(sourceTree, linesOfInterest)
 
@@ -76,6 +86,7 @@ func main() {
 	}
 
 	formatter := searchast.NewTextFormatter(searchast.WithColors(enableColors))
-	output := formatter.Format(sourceTree.Lines(), linesToShow, linesOfInterest)
-	fmt.Print(output)
+	if err := formatter.FormatTo(os.Stdout, sourceTree.LinesFor(formatter), linesToShow, linesOfInterest); err != nil {
+		log.Fatalf("Error writing output: %v", err)
+	}
 }
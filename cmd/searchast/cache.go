@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/andersonjoseph/searchast"
+	"github.com/andersonjoseph/searchast/config"
+)
+
+// runCache implements the cache subcommand, which manages the parse cache.
+func runCache(args []string) {
+	fs := flag.NewFlagSet("cache", flag.ExitOnError)
+
+	var (
+		cacheDir   string
+		configPath string
+		noConfig   bool
+	)
+
+	fs.StringVar(&cacheDir, "cache-dir", "", "Parse cache directory (default searchast in the user cache directory)")
+	fs.StringVar(&configPath, "config", "", "Configuration file to use instead of the discovered ones")
+	fs.BoolVar(&noConfig, "no-config", false, "Do not load any configuration file")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s cache: [flags] clean\n", os.Args[0])
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "clean removes every entry of the parse cache\n")
+	}
+
+	_ = fs.Parse(args) // exits on error
+	if fs.NArg() != 1 || fs.Arg(0) != "clean" {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	cfg, err := config.Resolve(configPath, noConfig)
+	if err != nil {
+		fatalf("Error loading configuration: %v", err)
+	}
+	if err := cfg.Apply(fs); err != nil {
+		fatalf("Error applying configuration: %v", err)
+	}
+
+	parseCache, err := newParseCache(cacheDir, 0)
+	if err != nil {
+		fatalf("Error opening parse cache: %v", err)
+	}
+	if err := parseCache.Clean(); err != nil {
+		fatalf("Error cleaning parse cache: %v", err)
+	}
+}
+
+// newParseCache returns the parse cache stored in dir, or in the default
+// directory if dir is empty, limited to maxSize MiB.
+func newParseCache(dir string, maxSize uint) (*searchast.ParseCache, error) {
+	if dir == "" {
+		var err error
+		if dir, err = searchast.DefaultCacheDir(); err != nil {
+			return nil, err
+		}
+	}
+
+	return searchast.NewParseCache(dir, searchast.WithCacheMaxBytes(int64(maxSize)<<20)), nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/andersonjoseph/searchast"
+	"github.com/andersonjoseph/searchast/diff"
+	"github.com/andersonjoseph/searchast/git"
+)
+
+// changedLines returns the 0-based lines added or modified in each file by
+// the diff of rev, keyed by changeKey. If rev is "-", a unified diff is read
+// from stdin instead of running git diff. newRev is the revision whose files
+// are searched, the new side of a range such as main...HEAD, or an empty
+// string for the working tree.
+func changedLines(rev string, newRev string, paths []string) (map[string]searchast.Set[uint32], error) {
+	var (
+		files []diff.File
+		err   error
+	)
+	if rev == "-" {
+		files, err = diff.Parse(os.Stdin)
+	} else {
+		files, err = git.Diff(context.Background(), rev, paths...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]searchast.Set[uint32], len(files))
+	for _, file := range files {
+		if file.NewPath == "" { // deleted
+			continue
+		}
+
+		key := newRev + ":" + file.NewPath
+		if newRev == "" {
+			if key, err = filepath.Abs(file.NewPath); err != nil {
+				return nil, fmt.Errorf("failed to resolve %s: %w", file.NewPath, err)
+			}
+		}
+
+		lines := searchast.NewSet[uint32]()
+		for _, line := range file.AddedLines() {
+			lines.Add(uint32(line - 1))
+		}
+		changed[key] = lines
+	}
+
+	return changed, nil
+}
+
+// diffRevision returns the revision holding the new side of the diff of rev,
+// which is B for ranges such as A..B or A...B, or an empty string when it is
+// the working tree.
+func diffRevision(rev string) (string, error) {
+	if rev == "-" {
+		return "", nil
+	}
+
+	_, newRev, err := git.Range(context.Background(), rev)
+	return newRev, err
+}
+
+// changeKey returns the key of a searched file in the changed lines: the file
+// itself for the files of a revision, named <rev>:<path>, and its absolute
+// path for the files of the working tree.
+func changeKey(file string, rev string) string {
+	if rev != "" {
+		return file
+	}
+	return absPath(file)
+}
+
+// changedFiles returns the files with changed lines, in the order of files.
+func changedFiles(files []string, changed map[string]searchast.Set[uint32], rev string) []string {
+	var result []string
+	for _, file := range files {
+		if lines := changed[changeKey(file, rev)]; len(lines) > 0 {
+			result = append(result, file)
+		}
+	}
+
+	return result
+}
+
+// absPath returns the absolute version of path, or path itself if it can't
+// be resolved.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/andersonjoseph/searchast/config"
+)
+
+func mustGit(t *testing.T, args ...string) {
+	t.Helper()
+
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, output)
+	}
+}
+
+func TestChangedLines_Range(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Chdir(t.TempDir())
+
+	mustGit(t, "init", "-q")
+	for _, content := range []string{
+		"package main\n\nfunc main() {\n}\n",
+		"package main\n\nfunc main() {\n\tprintln(\"added\")\n}\n",
+	} {
+		if err := os.WriteFile("main.go", []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		mustGit(t, "add", ".")
+		mustGit(t, "commit", "-q", "-m", "update main.go")
+	}
+
+	// The working tree moves the lines changed by the range.
+	if err := os.WriteFile("main.go", []byte("package main\n\n// uncommitted\n\nfunc main() {\n\tprintln(\"added\")\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	const rev = "HEAD~1..HEAD"
+	newRev, err := diffRevision(rev)
+	if err != nil {
+		t.Fatalf("failed to resolve %s: %v", rev, err)
+	}
+	if newRev != "HEAD" {
+		t.Fatalf("expected the range to be read from HEAD, got %q", newRev)
+	}
+
+	changed, err := changedLines(rev, newRev, nil)
+	if err != nil {
+		t.Fatalf("failed to read the changed lines: %v", err)
+	}
+	files, readFile, err := revisionFiles(newRev, nil, &config.Config{})
+	if err != nil {
+		t.Fatalf("failed to list the files of %s: %v", newRev, err)
+	}
+	files = changedFiles(files, changed, newRev)
+	if len(files) != 1 {
+		t.Fatalf("expected main.go to be changed, got %v", files)
+	}
+
+	source, err := readFile(files[0])
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", files[0], err)
+	}
+	lines := strings.Split(string(source), "\n")
+	for line := range changed[changeKey(files[0], newRev)] {
+		if lines[line] != "\tprintln(\"added\")" {
+			t.Errorf("expected changed line %d to be the added one, got %q", line, lines[line])
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/andersonjoseph/searchast"
+	"github.com/andersonjoseph/searchast/config"
+	"github.com/andersonjoseph/searchast/diff"
+	"github.com/andersonjoseph/searchast/git"
+	"github.com/andersonjoseph/searchast/language"
+)
+
+// runDiff implements the diff subcommand, which renders the changes between
+// two files, or of a git revision range, within their enclosing scopes.
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+
+	var (
+		lineNumbers         bool
+		gapSymbol           string
+		colorFlag           string
+		surroundingLines    uint
+		childLines          uint
+		gapToClose          uint
+		parentContext       bool
+		closeScopeGaps      bool
+		expandInitialScopes bool
+		configPath          string
+		noConfig            bool
+	)
+
+	fs.BoolVar(&lineNumbers, "line-numbers", true, "Show line numbers in output")
+	fs.StringVar(&gapSymbol, "gap-symbol", "⋮", "Symbol for gaps between line blocks")
+	fs.StringVar(&colorFlag, "color", "auto", "Color output: auto, always, never")
+	fs.UintVar(&surroundingLines, "surrounding-lines", 3, "Lines of context to show around each change")
+	fs.UintVar(&childLines, "child-lines", 3, "Lines of context to show after the start of a scope")
+	fs.UintVar(&gapToClose, "gap-to-close", 3, "Maximum gap between shown lines that is filled in")
+	fs.BoolVar(&parentContext, "parent-context", true, "Show the start and end of parent scopes")
+	fs.BoolVar(&closeScopeGaps, "close-scope-gaps", true, "Show every line of scopes starting at a change")
+	fs.BoolVar(&expandInitialScopes, "expand-scopes", true, "Show every line of scopes starting near a change")
+	fs.StringVar(&configPath, "config", "", "Configuration file to use instead of the discovered ones")
+	fs.BoolVar(&noConfig, "no-config", false, "Do not load any configuration file")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s diff: [flags] <old file> <new file>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s diff [flags] <revision or range> [path ...]\n", os.Args[0])
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "Example: %s diff main.go.orig main.go\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Example: %s diff main...HEAD ./handlers\n", os.Args[0])
+	}
+
+	_ = fs.Parse(args) // exits on error
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	cfg, err := config.Resolve(configPath, noConfig)
+	if err != nil {
+		fatalf("Error loading configuration: %v", err)
+	}
+	if err := cfg.Apply(fs); err != nil {
+		fatalf("Error applying configuration: %v", err)
+	}
+	if err := cfg.RegisterLanguages(); err != nil {
+		fatalf("Error registering languages: %v", err)
+	}
+
+	contextBuilder := searchast.NewContextBuilder(
+		searchast.WithSurroundingLines(uint32(surroundingLines)),
+		searchast.WithChildLines(uint32(childLines)),
+		searchast.WithGapToClose(uint32(gapToClose)),
+		searchast.WithParentContext(parentContext),
+		searchast.WithCloseScopeGaps(closeScopeGaps),
+		searchast.WithExpandChildScopes(expandInitialScopes),
+	)
+
+	formatter := searchast.NewDiffFormatter(
+		searchast.WithDiffLineNumbers(lineNumbers),
+		searchast.WithDiffGapSymbol(gapSymbol),
+		searchast.WithDiffColors(colorFlag != "never"),
+	)
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	// Files that can't be read or parsed are reported and skipped, the exit
+	// status tells about them once the others are written.
+	hadErrors := false
+
+	// render writes the diff of a file, the language is detected from path.
+	render := func(oldPath, newPath, path string, oldSource, newSource []byte) {
+		ctx := context.Background()
+
+		oldTree, err := searchast.NewSourceTree(ctx, bytes.NewReader(oldSource), path)
+		if err != nil {
+			log.Printf("Error parsing '%s': %v", oldPath, err)
+			hadErrors = true
+			return
+		}
+		newTree, err := searchast.NewSourceTree(ctx, bytes.NewReader(newSource), path)
+		if err != nil {
+			log.Printf("Error parsing '%s': %v", newPath, err)
+			hadErrors = true
+			return
+		}
+
+		lines := diff.Lines(splitLines(oldSource), splitLines(newSource))
+		if err := formatter.WriteHeader(out, oldPath, newPath); err != nil {
+			fatalf("Error writing output: %v", err)
+		}
+		if err := formatter.FormatTo(out, oldTree, newTree, lines, contextBuilder); err != nil {
+			fatalf("Error writing output: %v", err)
+		}
+		if err := out.Flush(); err != nil {
+			fatalf("Error writing output: %v", err)
+		}
+	}
+
+	if fs.NArg() == 2 && isFile(fs.Arg(0)) && isFile(fs.Arg(1)) {
+		oldSource, err := os.ReadFile(fs.Arg(0))
+		if err != nil {
+			fatalf("Error reading '%s': %v", fs.Arg(0), err)
+		}
+		newSource, err := os.ReadFile(fs.Arg(1))
+		if err != nil {
+			fatalf("Error reading '%s': %v", fs.Arg(1), err)
+		}
+
+		if !bytes.Equal(oldSource, newSource) {
+			render(fs.Arg(0), fs.Arg(1), fs.Arg(1), oldSource, newSource)
+		}
+		exitDiff(out, hadErrors)
+		return
+	}
+
+	ctx := context.Background()
+	rev, paths := fs.Arg(0), fs.Args()[1:]
+
+	oldRev, newRev, err := git.Range(ctx, rev)
+	if err != nil {
+		fatalf("Error reading revisions: %v", err)
+	}
+	files, err := git.Diff(ctx, rev, paths...)
+	if err != nil {
+		fatalf("Error reading diff: %v", err)
+	}
+
+	for _, file := range files {
+		path := file.NewPath
+		if path == "" {
+			path = file.OldPath
+		}
+		if _, err := language.FromFilename(path); err != nil || cfg.Ignored(path) {
+			continue
+		}
+
+		var oldSource, newSource []byte
+		if file.OldPath != "" {
+			if oldSource, err = git.Show(ctx, oldRev, file.OldPath); err != nil {
+				log.Printf("Error reading '%s': %v", revPath(oldRev, file.OldPath), err)
+				hadErrors = true
+				continue
+			}
+		}
+		switch {
+		case file.NewPath == "":
+		case newRev == "":
+			newSource, err = os.ReadFile(file.NewPath)
+		default:
+			newSource, err = git.Show(ctx, newRev, file.NewPath)
+		}
+		if err != nil {
+			log.Printf("Error reading '%s': %v", revPath(newRev, file.NewPath), err)
+			hadErrors = true
+			continue
+		}
+
+		render(revPath(oldRev, file.OldPath), revPath(newRev, file.NewPath), path, oldSource, newSource)
+	}
+
+	exitDiff(out, hadErrors)
+}
+
+// exitDiff flushes out and exits with exitError if a file could not be
+// diffed, like the main command does for files it could not search.
+func exitDiff(out *bufio.Writer, hadErrors bool) {
+	if err := out.Flush(); err != nil {
+		fatalf("Error writing output: %v", err)
+	}
+	if hadErrors {
+		os.Exit(exitError)
+	}
+}
+
+// splitLines splits source into lines like NewSourceTree does. An empty source
+// has no lines, so that created and deleted files are entirely added or removed.
+func splitLines(source []byte) []string {
+	if len(source) == 0 {
+		return nil
+	}
+	return strings.Split(string(source), "\n")
+}
+
+// revPath returns how a path at a revision is printed in headers, the working
+// tree being the empty revision.
+func revPath(rev string, path string) string {
+	switch {
+	case path == "":
+		return "/dev/null"
+	case rev == "":
+		return path
+	default:
+		return rev + ":" + path
+	}
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
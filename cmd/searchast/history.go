@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/andersonjoseph/searchast"
+	"github.com/andersonjoseph/searchast/config"
+	"github.com/andersonjoseph/searchast/diff"
+	"github.com/andersonjoseph/searchast/git"
+	"github.com/andersonjoseph/searchast/language"
+)
+
+// searcher is the part of a source tree used to find the lines of interest.
+type searcher interface {
+	SearchAny(patterns []string, opts ...searchast.SearchOption) (searchast.Set[uint32], error)
+	SearchScopes(q searchast.ScopeQuery, opts ...searchast.SearchOption) (searchast.Set[uint32], error)
+}
+
+// revisionFiles returns the supported files of the tree of rev below paths,
+// named "rev:path" like git grep does, and a function reading them.
+func revisionFiles(rev string, paths []string, cfg *config.Config) ([]string, func(file string) ([]byte, error), error) {
+	ctx := context.Background()
+
+	entries, err := git.Tree(ctx, rev, paths...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var files []string
+	objects := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		if _, err := language.FromFilename(entry.Path); err != nil || cfg.Ignored(entry.Path) {
+			continue
+		}
+
+		file := rev + ":" + entry.Path
+		files = append(files, file)
+		objects[file] = entry.Object
+	}
+
+	readFile := func(file string) ([]byte, error) {
+		return git.CatFile(ctx, objects[file])
+	}
+
+	return files, readFile, nil
+}
+
+// historySearch searches the changes of each commit for lines that were added
+// or removed and match.
+type historySearch struct {
+	search      func(tree searcher) (searchast.Set[uint32], error)
+	contextOpts []searchast.Option
+	formatter   *searchast.DiffFormatter
+	cfg         *config.Config
+}
+
+// run searches the commits of rev that change paths, newest first, and writes
+// the changes of every commit that added or removed a matching line. Those
+// lines are shown with their enclosing scopes, in the version of the file
+// before the commit for removed lines and after it for added lines. It
+// reports whether a line matched.
+func (hs historySearch) run(w io.Writer, rev string, paths []string) (bool, error) {
+	ctx := context.Background()
+
+	commits, err := git.Log(ctx, rev, paths...)
+	if err != nil {
+		return false, err
+	}
+
+	found := false
+	for _, commit := range commits {
+		files, err := git.Changes(ctx, commit.Hash, paths...)
+		if err != nil {
+			return found, err
+		}
+
+		isCommitPrinted := false
+		for _, file := range files {
+			path := file.NewPath
+			if path == "" {
+				path = file.OldPath
+			}
+			if _, err := language.FromFilename(path); err != nil || hs.cfg.Ignored(path) {
+				continue
+			}
+
+			var oldSource, newSource []byte
+			if file.OldPath != "" {
+				if oldSource, err = git.Show(ctx, commit.Hash+"^", file.OldPath); err != nil {
+					return found, err
+				}
+			}
+			if file.NewPath != "" {
+				if newSource, err = git.Show(ctx, commit.Hash, file.NewPath); err != nil {
+					return found, err
+				}
+			}
+
+			oldTree, err := searchast.NewSourceTree(ctx, bytes.NewReader(oldSource), path)
+			if err != nil {
+				log.Printf("Error parsing '%s' before %s: %v", path, commit.Short(), err)
+				continue
+			}
+			newTree, err := searchast.NewSourceTree(ctx, bytes.NewReader(newSource), path)
+			if err != nil {
+				log.Printf("Error parsing '%s' at %s: %v", path, commit.Short(), err)
+				continue
+			}
+
+			oldMatches, err := hs.search(oldTree)
+			if err != nil {
+				return found, err
+			}
+			newMatches, err := hs.search(newTree)
+			if err != nil {
+				return found, err
+			}
+
+			lines := diff.Lines(splitLines(oldSource), splitLines(newSource))
+			removed := searchast.NewSet[uint32]()
+			added := searchast.NewSet[uint32]()
+			for _, l := range lines {
+				switch {
+				case l.Op == diff.Removed && oldMatches.Has(uint32(l.OldNumber-1)):
+					removed.Add(uint32(l.OldNumber - 1))
+				case l.Op == diff.Added && newMatches.Has(uint32(l.NewNumber-1)):
+					added.Add(uint32(l.NewNumber - 1))
+				}
+			}
+			if len(removed) == 0 && len(added) == 0 {
+				continue
+			}
+			found = true
+
+			if !isCommitPrinted {
+				if _, err := fmt.Fprintf(w, "commit %s %s %s: %s\n", commit.Short(), commit.Date, commit.Author, commit.Subject); err != nil {
+					return found, err
+				}
+				isCommitPrinted = true
+			}
+
+			cb := searchast.NewContextBuilder(hs.contextOpts...)
+			if err := hs.formatter.WriteHeader(w, revPath(commit.Short()+"^", file.OldPath), revPath(commit.Short(), file.NewPath)); err != nil {
+				return found, err
+			}
+			if err := hs.formatter.FormatChangesTo(w, oldTree, newTree, lines, removed, added, cb); err != nil {
+				return found, err
+			}
+		}
+	}
+
+	return found, nil
+}
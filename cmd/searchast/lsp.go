@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/andersonjoseph/searchast"
+	"github.com/andersonjoseph/searchast/config"
+	"github.com/andersonjoseph/searchast/lsp"
+	"github.com/andersonjoseph/searchast/walk"
+)
+
+// runLSP implements the lsp subcommand, which runs a language server over
+// stdin and stdout.
+func runLSP(args []string) {
+	fs := flag.NewFlagSet("lsp", flag.ExitOnError)
+
+	var (
+		ignoreCase          bool
+		smartCase           bool
+		wholeWord           bool
+		surroundingLines    uint
+		childLines          uint
+		gapToClose          uint
+		parentContext       bool
+		closeScopeGaps      bool
+		expandInitialScopes bool
+		useCache            bool
+		noCache             bool
+		cacheDir            string
+		cacheMaxSize        uint
+		configPath          string
+		noConfig            bool
+		maxFiles            uint
+	)
+
+	fs.BoolVar(&ignoreCase, "i", false, "Search case-insensitively")
+	fs.BoolVar(&smartCase, "S", false, "Search case-insensitively unless the pattern contains uppercase characters")
+	fs.BoolVar(&wholeWord, "w", false, "Only match whole words")
+	fs.UintVar(&surroundingLines, "surrounding-lines", 3, "Lines of context to return around each match")
+	fs.UintVar(&childLines, "child-lines", 3, "Lines of context to return after the start of a scope")
+	fs.UintVar(&gapToClose, "gap-to-close", 3, "Maximum gap between returned lines that is filled in")
+	fs.BoolVar(&parentContext, "parent-context", true, "Return the start and end of parent scopes")
+	fs.BoolVar(&closeScopeGaps, "close-scope-gaps", true, "Return every line of scopes starting at a match")
+	fs.BoolVar(&expandInitialScopes, "expand-scopes", true, "Return every line of scopes starting near a match")
+	fs.BoolVar(&useCache, "cache", false, "Cache parsed workspace files on disk and reuse them while they do not change")
+	fs.BoolVar(&noCache, "no-cache", false, "Do not use the parse cache, even if enabled in the configuration")
+	fs.StringVar(&cacheDir, "cache-dir", "", "Parse cache directory (default searchast in the user cache directory)")
+	fs.UintVar(&cacheMaxSize, "cache-max-size", 256, "Size limit of the parse cache in MiB, 0 for no limit")
+	fs.StringVar(&configPath, "config", "", "Configuration file to use instead of the discovered ones")
+	fs.BoolVar(&noConfig, "no-config", false, "Do not load any configuration file")
+	fs.UintVar(&maxFiles, "max-files", 5000, "Number of parsed workspace files kept in memory, 0 for no limit")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s lsp: [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "Runs a language server over stdin and stdout providing document and workspace\n")
+		fmt.Fprintf(os.Stderr, "symbols, folding ranges and the %s request\n", lsp.SearchMethod)
+	}
+
+	_ = fs.Parse(args) // exits on error
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	cfg, err := config.Resolve(configPath, noConfig)
+	if err != nil {
+		fatalf("Error loading configuration: %v", err)
+	}
+	if err := cfg.Apply(fs); err != nil {
+		fatalf("Error applying configuration: %v", err)
+	}
+	if err := cfg.RegisterLanguages(); err != nil {
+		fatalf("Error registering languages: %v", err)
+	}
+
+	opts := []lsp.Option{
+		lsp.WithSearchOptions(
+			searchast.WithIgnoreCase(ignoreCase),
+			searchast.WithSmartCase(smartCase),
+			searchast.WithWholeWord(wholeWord),
+		),
+		lsp.WithContextOptions(
+			searchast.WithSurroundingLines(uint32(surroundingLines)),
+			searchast.WithChildLines(uint32(childLines)),
+			searchast.WithGapToClose(uint32(gapToClose)),
+			searchast.WithParentContext(parentContext),
+			searchast.WithCloseScopeGaps(closeScopeGaps),
+			searchast.WithExpandChildScopes(expandInitialScopes),
+		),
+		lsp.WithFileLister(func(root string) ([]string, error) {
+			return walk.Files([]string{root}, cfg.Ignored)
+		}),
+		lsp.WithMaxFiles(int(maxFiles)),
+	}
+
+	var parseCache *searchast.ParseCache
+	if useCache && !noCache {
+		if parseCache, err = newParseCache(cacheDir, cacheMaxSize); err != nil {
+			fatalf("Error opening parse cache: %v", err)
+		}
+		opts = append(opts, lsp.WithParseCache(parseCache))
+	}
+
+	serveErr := lsp.NewServer(opts...).Serve(context.Background(), os.Stdin, os.Stdout)
+
+	if parseCache != nil {
+		if err := parseCache.PruneIfDue(); err != nil {
+			log.Printf("Error pruning parse cache: %v", err)
+		}
+	}
+	if serveErr != nil {
+		fatalf("Error serving: %v", serveErr)
+	}
+}
@@ -1,75 +1,304 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/andersonjoseph/searchast"
+	"github.com/andersonjoseph/searchast/config"
+	"github.com/andersonjoseph/searchast/language"
+	"github.com/andersonjoseph/searchast/walk"
 )
 
+// Exit codes follow grep conventions so scripts can tell a missing match
+// apart from a failure.
+const (
+	exitMatch   = 0
+	exitNoMatch = 1
+	exitError   = 2
+)
+
+// fatalf logs an error and exits with exitError.
+func fatalf(format string, args ...any) {
+	log.Printf(format, args...)
+	os.Exit(exitError)
+}
+
 func main() {
+	log.SetFlags(0)
+	log.SetPrefix("searchast: ")
+
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		runDiff(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "cache" {
+		runCache(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "lsp" {
+		runLSP(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "mcp" {
+		runMCP(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		runServe(os.Args[2:])
+		return
+	}
+
 	var (
-		filename        string
-		pattern         string
-		lineNumbers     bool
-		highlightSymbol string
-		contextSymbol   string
-		gapSymbol       string
-		spacer          string
-		colorFlag       string
+		filename            string
+		pattern             string
+		lineNumbers         bool
+		highlightSymbol     string
+		contextSymbol       string
+		gapSymbol           string
+		spacer              string
+		colorFlag           string
+		surroundingLines    uint
+		childLines          uint
+		gapToClose          uint
+		parentContext       bool
+		closeScopeGaps      bool
+		expandInitialScopes bool
+		configPath          string
+		noConfig            bool
+		filesWithMatches    bool
+		filesWithoutMatch   bool
+		count               bool
+		quiet               bool
+		maxCount            int
+		fixedStrings        bool
+		ignoreCase          bool
+		smartCase           bool
+		wholeWord           bool
+		multiline           bool
+		patternsFile        string
+		patterns            patternList
+		andPatterns         patternList
+		notPatterns         patternList
+		invertMatch         bool
+		scopeKinds          string
+		outputFormat        string
+		highlightSyntax     bool
+		themeName           string
+		colorDepthFlag      string
+		rulesFile           string
+		templateFile        string
+		heading             bool
+		separator           string
+		showStats           bool
+		hyperlinkFormat     string
+		tabWidth            uint
+		maxColumnsFlag      string
+		maxLineBytes        uint
+		diffRev             string
+		revision            string
+		historyMode         bool
+		useCache            bool
+		noCache             bool
+		cacheDir            string
+		cacheMaxSize        uint
 	)
 
-	flag.StringVar(&filename, "filename", "", "Source code file to search (required)")
-	flag.StringVar(&pattern, "pattern", "", "Search pattern to find (required)")
+	flag.StringVar(&filename, "filename", "", "Source code file to search")
+	flag.StringVar(&pattern, "pattern", "", "Search pattern to find")
+	flag.Var(&patterns, "e", "Search pattern to find, can be repeated to match any of them")
+	flag.StringVar(&patternsFile, "f", "", "File with one search pattern per line")
+	flag.StringVar(&rulesFile, "rules", "", "TOML file with [[rule]] tables defining named patterns, used as rules by -format sarif")
+	flag.Var(&andPatterns, "and", "Only report scopes that also contain this pattern, can be repeated")
+	flag.Var(&notPatterns, "not", "Only report scopes that do not contain this pattern, can be repeated")
+	flag.BoolVar(&invertMatch, "v", false, "Report scopes that do not contain any of the patterns")
+	flag.StringVar(&scopeKinds, "scope", "", "Comma separated scope kinds for scope queries: function, method, class or node types (default \"function,method\")")
+	flag.StringVar(&diffRev, "diff", "", "Only report lines added or modified by git diff of a revision or range such as main...HEAD, or by a unified diff read from stdin with -")
+	flag.StringVar(&revision, "rev", "", "Search the files of a commit instead of the working tree, or the commits of a revision range with -log")
+	flag.BoolVar(&historyMode, "log", false, "Search the changes of each commit of -rev (default HEAD) and show the commits that added or removed a matching line")
 	flag.BoolVar(&lineNumbers, "line-numbers", true, "Show line numbers in output")
 	flag.StringVar(&highlightSymbol, "highlight-symbol", "█", "Symbol for highlighted lines")
 	flag.StringVar(&contextSymbol, "context-symbol", "│", "Symbol for context lines")
 	flag.StringVar(&gapSymbol, "gap-symbol", "⋮", "Symbol for gaps between line blocks")
 	flag.StringVar(&spacer, "spacer", " ", "Spacer between line numbers and content")
 	flag.StringVar(&colorFlag, "color", "auto", "Color output: auto, always, never")
+	flag.BoolVar(&highlightSyntax, "highlight-syntax", false, "Highlight the syntax of the output when colors are enabled")
+	flag.StringVar(&themeName, "theme", "dark", "Syntax highlighting theme: "+strings.Join(searchast.ThemeNames(), ", "))
+	flag.StringVar(&colorDepthFlag, "color-depth", "auto", "Syntax highlighting colors: auto (detect truecolor from $COLORTERM), 256, truecolor")
+	flag.StringVar(&outputFormat, "format", "text", "Output format: text, markdown, html, sarif, grep, vimgrep, json, template")
+	flag.StringVar(&templateFile, "template", "", "text/template file used to render each file, implies -format template")
+	flag.BoolVar(&heading, "heading", true, "Print the path, language and number of matching lines before each file when searching several files")
+	flag.StringVar(&separator, "separator", "", "Line printed between the output of two files in text format")
+	flag.BoolVar(&showStats, "stats", false, "Print statistics about the search after the results")
+	flag.StringVar(&hyperlinkFormat, "hyperlink-format", "", "Make line numbers and file headers terminal hyperlinks: file, vscode, cursor or a URL template such as 'vscode://file{path}:{line}'")
+	flag.UintVar(&tabWidth, "tab-width", 0, "Expand tabs to this many columns (0 prints tabs as they are)")
+	flag.StringVar(&maxColumnsFlag, "max-columns", "0", "Truncate lines to this many columns keeping the match visible: a number, 0 to disable, or auto for the terminal width")
+	flag.UintVar(&maxLineBytes, "max-line-bytes", 0, "Replace lines longer than this many bytes with a note (0 to disable)")
+	flag.UintVar(&surroundingLines, "surrounding-lines", 3, "Lines of context to show around each match")
+	flag.UintVar(&childLines, "child-lines", 3, "Lines of context to show after the start of a scope")
+	flag.UintVar(&gapToClose, "gap-to-close", 3, "Maximum gap between shown lines that is filled in")
+	flag.BoolVar(&parentContext, "parent-context", true, "Show the start and end of parent scopes")
+	flag.BoolVar(&closeScopeGaps, "close-scope-gaps", true, "Show every line of scopes starting at a match")
+	flag.BoolVar(&expandInitialScopes, "expand-scopes", true, "Show every line of scopes starting near a match")
+	flag.StringVar(&configPath, "config", "", "Configuration file to use instead of the discovered ones")
+	flag.BoolVar(&noConfig, "no-config", false, "Do not load any configuration file")
+	flag.BoolVar(&useCache, "cache", false, "Cache parsed files on disk and reuse them while they do not change")
+	flag.BoolVar(&noCache, "no-cache", false, "Do not use the parse cache, even if enabled in the configuration")
+	flag.StringVar(&cacheDir, "cache-dir", "", "Parse cache directory (default searchast in the user cache directory)")
+	flag.UintVar(&cacheMaxSize, "cache-max-size", 256, "Size limit of the parse cache in MiB, 0 for no limit")
+	flag.BoolVar(&filesWithMatches, "l", false, "Only print the names of files with matches")
+	flag.BoolVar(&filesWithoutMatch, "L", false, "Only print the names of files without matches")
+	flag.BoolVar(&count, "c", false, "Only print the number of matching lines per file")
+	flag.BoolVar(&quiet, "q", false, "Do not print anything, exit with 0 on the first match")
+	flag.IntVar(&maxCount, "m", 0, "Stop after N matching lines per file (0 means no limit)")
+	flag.BoolVar(&fixedStrings, "F", false, "Treat the pattern as a literal string instead of a regular expression")
+	flag.BoolVar(&ignoreCase, "i", false, "Search case-insensitively")
+	flag.BoolVar(&smartCase, "S", false, "Search case-insensitively unless the pattern contains uppercase characters")
+	flag.BoolVar(&wholeWord, "w", false, "Only match whole words")
+	flag.BoolVar(&multiline, "U", false, "Allow matches to span several lines")
+	flag.BoolVar(&multiline, "multiline", false, "Allow matches to span several lines (same as -U)")
 
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage of %s: [flags] [path ...]\n", os.Args[0])
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "Example: %s -filename sourcetree.go -pattern 'AI\\\\?' -highlight-symbol '>>' -context-symbol '| '\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Example: %s -e 'Begin' -not 'Commit' ./handlers\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Example: %s -diff main...HEAD -pattern 'TODO'\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Example: %s -log -pattern 'ioutil\\.' ./handlers\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Example: %s -v -scope function -pattern 'err != nil' ./handlers\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "Color options: auto (detect terminal), always, never\n")
+		fmt.Fprintf(os.Stderr, "Exit status: 0 if a line matched, 1 if no line matched, 2 if an error occurred\n")
+		fmt.Fprintf(os.Stderr, "Subcommands: %s diff, %s cache clean, %s lsp, %s mcp, %s serve\n", os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0])
+		fmt.Fprintf(os.Stderr, "Defaults are read from $XDG_CONFIG_HOME/searchast/config.toml and the nearest %s\n", config.ProjectFilename)
 	}
 
 	flag.Parse()
 
-	if filename == "" || pattern == "" {
-		flag.Usage()
-		os.Exit(1)
+	paths := flag.Args()
+	if filename != "" {
+		paths = append([]string{filename}, paths...)
 	}
 
-	f, err := os.Open(filename)
-	if err != nil {
-		log.Fatalf("Error opening source file '%s': %v", filename, err)
+	if pattern != "" {
+		patterns = append(patternList{pattern}, patterns...)
 	}
-	defer func() {
-		if err := f.Close(); err != nil {
-			log.Fatalf("Error closing file '%s': %v", filename, err)
+	if patternsFile != "" {
+		filePatterns, err := readPatterns(patternsFile)
+		if err != nil {
+			fatalf("Error reading patterns: %v", err)
 		}
-	}()
+		patterns = append(patterns, filePatterns...)
+	}
 
-	sourceTree, err := searchast.NewSourceTree(context.Background(), f, filename)
-	if err != nil {
-		log.Fatalf("Error opening source file '%s': %v", filename, err)
+	// rules describe every positive pattern for SARIF output, patterns
+	// without a name get a generated id.
+	var rules []searchast.Rule
+	for i, p := range slices.Concat(patterns, andPatterns) {
+		rules = append(rules, searchast.Rule{ID: fmt.Sprintf("pattern-%d", i+1), Pattern: p})
+	}
+	if rulesFile != "" {
+		fileRules, err := config.LoadRules(rulesFile)
+		if err != nil {
+			fatalf("Error reading rules: %v", err)
+		}
+
+		var namedRules []searchast.Rule
+		for _, rule := range fileRules {
+			if slices.ContainsFunc(rules, func(r searchast.Rule) bool { return r.ID == rule.ID }) {
+				fatalf("Error reading rules: rule %s in %s has the id of a pattern given on the command line", rule.ID, rulesFile)
+			}
+
+			patterns = append(patterns, rule.Pattern)
+			namedRules = append(namedRules, searchast.Rule{
+				ID:          rule.ID,
+				Pattern:     rule.Pattern,
+				Description: rule.Description,
+				Level:       rule.Level,
+			})
+		}
+		rules = append(namedRules, rules...)
 	}
 
-	linesOfInterest, err := sourceTree.Search(pattern)
+	if (diffRev != "" || revision != "" || historyMode) && len(paths) == 0 {
+		paths = []string{"."}
+	}
+	if diffRev != "" && (revision != "" || historyMode) {
+		fatalf("-diff can't be combined with -rev or -log")
+	}
+	if historyMode && revision == "" {
+		revision = "HEAD"
+	}
+	if diffRev != "" {
+		// The line numbers of a diff between two revisions are those of the
+		// files of the second one, which are searched as with -rev.
+		newRev, err := diffRevision(diffRev)
+		if err != nil {
+			fatalf("Error reading diff: %v", err)
+		}
+		revision = newRev
+	}
+
+	// Without patterns, -diff reports every changed line.
+	if len(paths) == 0 || (len(patterns)+len(andPatterns)+len(notPatterns) == 0 && diffRev == "") {
+		flag.Usage()
+		os.Exit(exitError)
+	}
+
+	cfg, err := config.Resolve(configPath, noConfig)
 	if err != nil {
-		log.Fatalf("Error searching for pattern '%s': %v", pattern, err)
+		fatalf("Error loading configuration: %v", err)
+	}
+	if err := cfg.Apply(flag.CommandLine); err != nil {
+		fatalf("Error applying configuration: %v", err)
+	}
+	if err := cfg.RegisterLanguages(); err != nil {
+		fatalf("Error registering languages: %v", err)
 	}
 
-	if len(linesOfInterest) == 0 {
-		log.Fatalf("No matches found")
+	// newSourceTree parses the searched files, through the parse cache if enabled.
+	newSourceTree := searchast.NewSourceTree
+	var parseCache *searchast.ParseCache
+	if useCache && !noCache {
+		parseCache, err = newParseCache(cacheDir, cacheMaxSize)
+		if err != nil {
+			fatalf("Error opening parse cache: %v", err)
+		}
+		newSourceTree = parseCache.NewSourceTree
+	}
+
+	// Files are read from the working tree, or from the tree of -rev.
+	var files []string
+	readFile := os.ReadFile
+	switch {
+	case historyMode:
+		// The files are those changed by each commit.
+	case revision != "":
+		files, readFile, err = revisionFiles(revision, paths, cfg)
+	default:
+		files, err = walk.Files(paths, cfg.Ignored)
+	}
+	if err != nil {
+		fatalf("Error collecting files: %v", err)
 	}
 
-	linesToShow := searchast.NewContextBuilder().AddContext(sourceTree, linesOfInterest)
+	var changed map[string]searchast.Set[uint32]
+	if diffRev != "" {
+		changed, err = changedLines(diffRev, revision, paths)
+		if err != nil {
+			fatalf("Error reading diff: %v", err)
+		}
+		files = changedFiles(files, changed, revision)
+	}
 
 	var enableColors bool
 	switch colorFlag {
@@ -83,6 +312,28 @@ func main() {
 		enableColors = true
 	}
 
+	contextOpts := []searchast.Option{
+		searchast.WithSurroundingLines(uint32(surroundingLines)),
+		searchast.WithChildLines(uint32(childLines)),
+		searchast.WithGapToClose(uint32(gapToClose)),
+		searchast.WithParentContext(parentContext),
+		searchast.WithCloseScopeGaps(closeScopeGaps),
+		searchast.WithExpandChildScopes(expandInitialScopes),
+	}
+	contextBuilder := searchast.NewContextBuilder(contextOpts...)
+
+	var maxColumns int
+	switch maxColumnsFlag {
+	case "auto":
+		maxColumns = terminalColumns()
+	default:
+		n, err := strconv.Atoi(maxColumnsFlag)
+		if err != nil || n < 0 {
+			fatalf("Invalid -max-columns '%s', expected a number or auto", maxColumnsFlag)
+		}
+		maxColumns = n
+	}
+
 	formatterOpts := []searchast.TextFormatterOption{
 		searchast.WithHighlightSymbol(highlightSymbol),
 		searchast.WithContextSymbol(contextSymbol),
@@ -90,9 +341,425 @@ func main() {
 		searchast.WithSpacer(spacer),
 		searchast.WithLineNumbers(lineNumbers),
 		searchast.WithColors(enableColors),
+		searchast.WithHyperlinkFormat(hyperlinkFormat),
+		searchast.WithTabWidth(int(tabWidth)),
+		searchast.WithMaxColumns(maxColumns),
+		searchast.WithMaxLineBytes(int(maxLineBytes)),
+	}
+
+	if highlightSyntax && enableColors {
+		theme, err := searchast.ThemeByName(themeName)
+		if err != nil {
+			fatalf("Error selecting theme: %v", err)
+		}
+
+		var colorDepth searchast.ColorDepth
+		switch colorDepthFlag {
+		case "256":
+			colorDepth = searchast.Color256
+		case "truecolor":
+			colorDepth = searchast.TrueColor
+		case "auto":
+			if colorTerm := os.Getenv("COLORTERM"); colorTerm == "truecolor" || colorTerm == "24bit" {
+				colorDepth = searchast.TrueColor
+			}
+		default:
+			fatalf("Unknown color depth '%s'", colorDepthFlag)
+		}
+
+		formatterOpts = append(formatterOpts,
+			searchast.WithSyntaxHighlighting(theme),
+			searchast.WithColorDepth(colorDepth),
+		)
+	}
+
+	textFormatter := searchast.NewTextFormatter(formatterOpts...)
+
+	searchOpts := []searchast.SearchOption{
+		searchast.WithFixedStrings(fixedStrings),
+		searchast.WithIgnoreCase(ignoreCase),
+		searchast.WithSmartCase(smartCase),
+		searchast.WithWholeWord(wholeWord),
+		searchast.WithMultiline(multiline),
+	}
+
+	if historyMode {
+		if outputFormat != "text" || quiet || filesWithMatches || filesWithoutMatch || count {
+			fatalf("-log only supports the text output format")
+		}
+
+		history := historySearch{
+			search: func(tree searcher) (searchast.Set[uint32], error) {
+				if query, ok := scopeQuery(patterns, andPatterns, notPatterns, invertMatch, scopeKinds); ok {
+					return tree.SearchScopes(query, searchOpts...)
+				}
+				return tree.SearchAny(patterns, searchOpts...)
+			},
+			contextOpts: contextOpts,
+			formatter: searchast.NewDiffFormatter(
+				searchast.WithDiffLineNumbers(lineNumbers),
+				searchast.WithDiffGapSymbol(gapSymbol),
+				searchast.WithDiffColors(enableColors),
+			),
+			cfg: cfg,
+		}
+
+		out := bufio.NewWriter(os.Stdout)
+		found, err := history.run(out, revision, paths)
+		if flushErr := out.Flush(); err == nil {
+			err = flushErr
+		}
+		if err != nil {
+			fatalf("Error searching history: %v", err)
+		}
+		if !found {
+			os.Exit(exitNoMatch)
+		}
+		return
+	}
+
+	if templateFile != "" {
+		outputFormat = "template"
+	}
+
+	// newFormatter returns the formatter for file, formats that render the
+	// file name or language need one formatter per file.
+	var newFormatter func(file string) searchast.Formatter
+	switch outputFormat {
+	case "text":
+		newFormatter = func(file string) searchast.Formatter {
+			if hyperlinkFormat == "" {
+				return textFormatter
+			}
+			return searchast.NewTextFormatter(append(slices.Clip(formatterOpts), searchast.WithHyperlinkPath(file))...)
+		}
+	case "markdown":
+		newFormatter = func(file string) searchast.Formatter {
+			lang, _ := language.NameFromFilename(file)
+			return searchast.NewMarkdownFormatter(file, lang, searchast.WithMarkdownLineNumbers(lineNumbers))
+		}
+	case "html":
+		newFormatter = func(file string) searchast.Formatter {
+			return searchast.NewHTMLFormatter(file, searchast.WithHTMLFragment(true), searchast.WithHTMLLineNumbers(lineNumbers))
+		}
+	case "grep", "vimgrep":
+		newGrepFormatter := searchast.NewGrepFormatter
+		if outputFormat == "vimgrep" {
+			newGrepFormatter = searchast.NewVimgrepFormatter
+		}
+		newFormatter = func(file string) searchast.Formatter {
+			formatter, err := newGrepFormatter(file, slices.Concat(patterns, andPatterns), searchOpts...)
+			if err != nil {
+				fatalf("Error searching: %v", err)
+			}
+			return formatter
+		}
+	case "json":
+		newFormatter = func(file string) searchast.Formatter {
+			formatter, err := searchast.NewJSONFormatter(file, slices.Concat(patterns, andPatterns), searchOpts...)
+			if err != nil {
+				fatalf("Error searching: %v", err)
+			}
+			return formatter
+		}
+	case "template":
+		if templateFile == "" {
+			fatalf("-format template requires -template")
+		}
+		text, err := os.ReadFile(templateFile)
+		if err != nil {
+			fatalf("Error reading template: %v", err)
+		}
+		tmpl, err := searchast.ParseTemplate(string(text))
+		if err != nil {
+			fatalf("Error reading template %s: %v", templateFile, err)
+		}
+		newFormatter = func(file string) searchast.Formatter {
+			lang, _ := language.NameFromFilename(file)
+			return searchast.NewTemplateFormatter(file, lang, tmpl)
+		}
+	case "sarif":
+		// SARIF results are collected from every file and written as a single log.
+	default:
+		fatalf("Unknown output format '%s'", outputFormat)
+	}
+
+	listing := quiet || filesWithMatches || filesWithoutMatch || count
+	sarifLog := outputFormat == "sarif" && !listing
+	var sarifResults []searchast.SARIFResult
+
+	// The JSON stream ends with a summary of every searched file.
+	jsonStream := outputFormat == "json" && !listing
+	var jsonStats searchast.SearchStats
+
+	started := time.Now()
+	var stats searchast.SearchStats
+
+	// Output is buffered per file and flushed as soon as each file is done.
+	out := bufio.NewWriter(os.Stdout)
+
+	// HTML files are rendered as sections of a single page.
+	htmlPage := outputFormat == "html" && !listing
+	if htmlPage {
+		if err := searchast.WriteHTMLHeader(out, "searchast: "+strings.Join(paths, " ")); err != nil {
+			fatalf("Error writing output: %v", err)
+		}
+	}
+
+	var (
+		found     bool
+		hadErrors bool
+		printed   bool
+	)
+	for _, file := range files {
+		// The formatter is created first, so the JSON stats include the time spent searching the file.
+		var formatter searchast.Formatter
+		if newFormatter != nil {
+			formatter = newFormatter(file)
+		}
+
+		source, err := readFile(file)
+		if err != nil {
+			log.Printf("Error opening source file '%s': %v", file, err)
+			hadErrors = true
+			continue
+		}
+		stats.Add(searchast.SearchStats{Searches: 1, BytesSearched: int64(len(source))})
+
+		sourceTree, err := newSourceTree(context.Background(), bytes.NewReader(source), file)
+		if err != nil {
+			log.Printf("Error parsing source file '%s': %v", file, err)
+			hadErrors = true
+			stats.ParseErrors++
+			continue
+		}
+
+		var linesOfInterest searchast.Set[uint32]
+		switch query, ok := scopeQuery(patterns, andPatterns, notPatterns, invertMatch, scopeKinds); {
+		case ok:
+			linesOfInterest, err = sourceTree.SearchScopes(query, searchOpts...)
+		case len(patterns) > 0:
+			linesOfInterest, err = sourceTree.SearchAny(patterns, searchOpts...)
+		default:
+			linesOfInterest = changed[changeKey(file, revision)]
+		}
+		if err != nil {
+			fatalf("Error searching: %v", err)
+		}
+		if changed != nil {
+			linesOfInterest = linesOfInterest.Intersect(changed[changeKey(file, revision)])
+		}
+
+		if maxCount > 0 && len(linesOfInterest) > maxCount {
+			linesOfInterest = firstLines(linesOfInterest, maxCount)
+		}
+		matched := len(linesOfInterest) > 0
+
+		if matched {
+			stats.SearchesWithMatch++
+			stats.MatchedLines += len(linesOfInterest)
+			if showStats {
+				matches, err := sourceTree.Matches(slices.Concat(patterns, andPatterns), searchOpts...)
+				if err != nil {
+					fatalf("Error searching: %v", err)
+				}
+				stats.Matches += countMatches(matches, linesOfInterest)
+			}
+		}
+
+		switch {
+		case quiet:
+			if matched {
+				os.Exit(exitMatch)
+			}
+		case filesWithMatches:
+			if matched {
+				fmt.Fprintln(out, file)
+				found = true
+			}
+		case filesWithoutMatch:
+			if !matched {
+				fmt.Fprintln(out, file)
+				found = true
+			}
+		case count:
+			if len(files) > 1 {
+				fmt.Fprintf(out, "%s:%d\n", file, len(linesOfInterest))
+			} else {
+				fmt.Fprintln(out, len(linesOfInterest))
+			}
+		case matched:
+			linesToShow := contextBuilder.AddContext(sourceTree, linesOfInterest)
+
+			if sarifLog {
+				// The files of a revision are reported at their path in the
+				// repository, without the revision prefix.
+				artifact := file
+				if revision != "" {
+					artifact = strings.TrimPrefix(file, revision+":")
+				}
+				formatter, err := searchast.NewSARIFFormatter(artifact, rules, searchOpts...)
+				if err != nil {
+					fatalf("Error searching: %v", err)
+				}
+				sarifResults = append(sarifResults, formatter.Results(sourceTree.LinesFor(formatter), linesToShow, linesOfInterest)...)
+				break
+			}
+
+			if outputFormat == "text" && maxColumns > 0 {
+				// Truncated lines are moved to keep their first match visible.
+				matches, err := sourceTree.Matches(slices.Concat(patterns, andPatterns), searchOpts...)
+				if err != nil {
+					fatalf("Error searching: %v", err)
+				}
+				formatter = searchast.NewTextFormatter(append(slices.Clip(formatterOpts), searchast.WithHyperlinkPath(file), searchast.WithMatches(matches))...)
+			}
+
+			if outputFormat == "text" {
+				if printed {
+					fmt.Fprintln(out, separator)
+				}
+				if heading && len(files) > 1 {
+					lang, _ := language.NameFromFilename(file)
+					if err := textFormatter.WriteHeader(out, file, lang, len(linesOfInterest)); err != nil {
+						fatalf("Error writing output: %v", err)
+					}
+				}
+			}
+			printed = true
+			if err := formatter.FormatTo(out, sourceTree.LinesFor(formatter), linesToShow, linesOfInterest); err != nil {
+				fatalf("Error writing output: %v", err)
+			}
+		}
+
+		if jsonStream {
+			stats := searchast.SearchStats{Searches: 1, BytesSearched: int64(len(source))}
+			if jsonFormatter, ok := formatter.(*searchast.JSONFormatter); ok && matched {
+				stats = jsonFormatter.Stats()
+			}
+			jsonStats.Add(stats)
+		}
+
+		if err := out.Flush(); err != nil {
+			fatalf("Error writing output: %v", err)
+		}
+
+		if matched && !filesWithoutMatch {
+			found = true
+		}
+	}
+
+	if htmlPage {
+		if err := searchast.WriteHTMLFooter(out); err != nil {
+			fatalf("Error writing output: %v", err)
+		}
+		if err := out.Flush(); err != nil {
+			fatalf("Error writing output: %v", err)
+		}
+	}
+
+	if jsonStream {
+		if err := searchast.WriteJSONSummary(out, jsonStats, time.Since(started)); err != nil {
+			fatalf("Error writing output: %v", err)
+		}
+		if err := out.Flush(); err != nil {
+			fatalf("Error writing output: %v", err)
+		}
+	}
+
+	if sarifLog {
+		if err := searchast.WriteSARIF(out, rules, sarifResults); err != nil {
+			fatalf("Error writing output: %v", err)
+		}
+		if err := out.Flush(); err != nil {
+			fatalf("Error writing output: %v", err)
+		}
+	}
+
+	if showStats {
+		stats.Elapsed = time.Since(started)
+
+		// Statistics go to stderr when they would make a document invalid.
+		var statsOutput io.Writer = out
+		if !listing && slices.Contains([]string{"html", "json", "sarif"}, outputFormat) {
+			statsOutput = os.Stderr
+		}
+
+		if printed || listing {
+			fmt.Fprintln(statsOutput)
+		}
+		fmt.Fprint(statsOutput, stats)
+		if err := out.Flush(); err != nil {
+			fatalf("Error writing output: %v", err)
+		}
+	}
+
+	if parseCache != nil {
+		if err := parseCache.PruneIfDue(); err != nil {
+			log.Printf("Error pruning parse cache: %v", err)
+		}
+	}
+
+	switch {
+	case hadErrors:
+		os.Exit(exitError)
+	case !found:
+		os.Exit(exitNoMatch)
+	}
+}
+
+// firstLines returns the n lowest line numbers of lines.
+func firstLines(lines searchast.Set[uint32], n int) searchast.Set[uint32] {
+	sorted := lines.ToSlice()
+	slices.Sort(sorted)
+
+	return searchast.NewSetFromSlice(sorted[:n])
+}
+
+// terminalColumns returns the width of the terminal from $COLUMNS, or by
+// asking the terminal stdout is attached to. It returns 0 if the width is
+// unknown, e.g. when the output is piped.
+func terminalColumns() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+
+	return terminalWidth(os.Stdout)
+}
+
+// countMatches returns the number of matches starting on the lines of
+// interest. Lines of interest without a match, such as the scope headers
+// reported by an inverted search, count as one match.
+func countMatches(matches []searchast.Match, linesOfInterest searchast.Set[uint32]) int {
+	count := 0
+	linesWithMatches := searchast.NewSet[uint32]()
+	for _, match := range matches {
+		if linesOfInterest.Has(match.StartLine) {
+			linesWithMatches.Add(match.StartLine)
+			count++
+		}
+	}
+
+	return count + len(linesOfInterest) - len(linesWithMatches)
+}
+
+// scopeQuery builds the scope query selected by the command line flags. It
+// returns false if the flags describe a plain line search.
+func scopeQuery(patterns, andPatterns, notPatterns []string, invert bool, kinds string) (searchast.ScopeQuery, bool) {
+	query := searchast.ScopeQuery{Any: patterns, All: andPatterns, None: notPatterns}
+
+	if invert {
+		query.Any = nil
+		query.None = append(slices.Clone(notPatterns), patterns...)
+		if kinds == "" {
+			kinds = searchast.ScopeKindFunction + "," + searchast.ScopeKindMethod
+		}
+	}
+
+	if kinds != "" {
+		query.Kinds = strings.Split(kinds, ",")
 	}
 
-	formatter := searchast.NewTextFormatter(formatterOpts...)
-	output := formatter.Format(sourceTree.Lines(), linesToShow, linesOfInterest)
-	fmt.Print(output)
+	isScopeQuery := len(query.All) > 0 || len(query.None) > 0 || len(query.Kinds) > 0
+	return query, isScopeQuery
 }
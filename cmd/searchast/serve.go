@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/andersonjoseph/searchast"
+	"github.com/andersonjoseph/searchast/config"
+	"github.com/andersonjoseph/searchast/httpapi"
+	"github.com/andersonjoseph/searchast/walk"
+)
+
+// runServe implements the serve subcommand, which runs an HTTP server
+// answering searches of a directory.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+
+	var (
+		ignoreCase          bool
+		smartCase           bool
+		wholeWord           bool
+		surroundingLines    uint
+		childLines          uint
+		gapToClose          uint
+		parentContext       bool
+		closeScopeGaps      bool
+		expandInitialScopes bool
+		useCache            bool
+		noCache             bool
+		cacheDir            string
+		cacheMaxSize        uint
+		configPath          string
+		noConfig            bool
+		addr                string
+		pollInterval        time.Duration
+		maxFiles            uint
+	)
+
+	fs.StringVar(&addr, "addr", "localhost:7420", "Address to listen on, host:port or unix:path for a unix socket")
+	fs.DurationVar(&pollInterval, "poll-interval", time.Second, "Interval at which parsed files are checked for changes")
+	fs.UintVar(&maxFiles, "max-files", 5000, "Number of parsed files kept in memory, 0 for no limit")
+
+	fs.BoolVar(&ignoreCase, "i", false, "Search case-insensitively")
+	fs.BoolVar(&smartCase, "S", false, "Search case-insensitively unless the pattern contains uppercase characters")
+	fs.BoolVar(&wholeWord, "w", false, "Only match whole words")
+	fs.UintVar(&surroundingLines, "surrounding-lines", 3, "Lines of context to show around each match")
+	fs.UintVar(&childLines, "child-lines", 3, "Lines of context to show after the start of a scope")
+	fs.UintVar(&gapToClose, "gap-to-close", 3, "Maximum gap between returned lines that is filled in")
+	fs.BoolVar(&parentContext, "parent-context", true, "Show the start and end of parent scopes")
+	fs.BoolVar(&closeScopeGaps, "close-scope-gaps", true, "Show every line of scopes starting at a match")
+	fs.BoolVar(&expandInitialScopes, "expand-scopes", true, "Show every line of scopes starting near a match")
+	fs.BoolVar(&useCache, "cache", false, "Cache parsed files on disk and reuse them while they do not change")
+	fs.BoolVar(&noCache, "no-cache", false, "Do not use the parse cache, even if enabled in the configuration")
+	fs.StringVar(&cacheDir, "cache-dir", "", "Parse cache directory (default searchast in the user cache directory)")
+	fs.UintVar(&cacheMaxSize, "cache-max-size", 256, "Size limit of the parse cache in MiB, 0 for no limit")
+	fs.StringVar(&configPath, "config", "", "Configuration file to use instead of the discovered ones")
+	fs.BoolVar(&noConfig, "no-config", false, "Do not load any configuration file")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s serve: [flags] [directory]\n", os.Args[0])
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "Runs an HTTP server answering /search, /overview and /languages with JSON for the\n")
+		fmt.Fprintf(os.Stderr, "files of the directory, the current one by default. The flags set the defaults of\n")
+		fmt.Fprintf(os.Stderr, "/search\n")
+	}
+
+	_ = fs.Parse(args) // exits on error
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+	root := "."
+	if fs.NArg() == 1 {
+		root = fs.Arg(0)
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		fatalf("Error: %s is not a directory", root)
+	}
+
+	cfg, err := config.Resolve(configPath, noConfig)
+	if err != nil {
+		fatalf("Error loading configuration: %v", err)
+	}
+	if err := cfg.Apply(fs); err != nil {
+		fatalf("Error applying configuration: %v", err)
+	}
+	if err := cfg.RegisterLanguages(); err != nil {
+		fatalf("Error registering languages: %v", err)
+	}
+
+	opts := []httpapi.Option{
+		httpapi.WithSearchOptions(
+			searchast.WithIgnoreCase(ignoreCase),
+			searchast.WithSmartCase(smartCase),
+			searchast.WithWholeWord(wholeWord),
+		),
+		httpapi.WithContextOptions(
+			searchast.WithSurroundingLines(uint32(surroundingLines)),
+			searchast.WithChildLines(uint32(childLines)),
+			searchast.WithGapToClose(uint32(gapToClose)),
+			searchast.WithParentContext(parentContext),
+			searchast.WithCloseScopeGaps(closeScopeGaps),
+			searchast.WithExpandChildScopes(expandInitialScopes),
+		),
+		httpapi.WithFileLister(func(paths []string) ([]string, error) {
+			return walk.Files(paths, cfg.Ignored)
+		}),
+		httpapi.WithPollInterval(pollInterval),
+		httpapi.WithMaxFiles(int(maxFiles)),
+	}
+
+	var parseCache *searchast.ParseCache
+	if useCache && !noCache {
+		if parseCache, err = newParseCache(cacheDir, cacheMaxSize); err != nil {
+			fatalf("Error opening parse cache: %v", err)
+		}
+		opts = append(opts, httpapi.WithParseCache(parseCache))
+	}
+
+	network, address := "tcp", addr
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		network, address = "unix", path
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		fatalf("Error listening on %s: %v", addr, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := httpapi.NewServer(root, opts...)
+	go server.Watch(ctx)
+
+	httpServer := &http.Server{Handler: server}
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-ctx.Done()
+		if err := httpServer.Shutdown(context.Background()); err != nil {
+			log.Printf("Error shutting down: %v", err)
+		}
+	}()
+
+	log.Printf("Serving %s on %s", root, listener.Addr())
+	serveErr := httpServer.Serve(listener)
+	if errors.Is(serveErr, http.ErrServerClosed) {
+		<-shutdown // wait for the requests in flight
+		serveErr = nil
+	}
+
+	if parseCache != nil {
+		if err := parseCache.PruneIfDue(); err != nil {
+			log.Printf("Error pruning parse cache: %v", err)
+		}
+	}
+	if serveErr != nil {
+		fatalf("Error serving: %v", serveErr)
+	}
+}
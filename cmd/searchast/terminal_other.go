@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package main
+
+import "os"
+
+// terminalWidth returns 0, the terminal width is only detected on unix
+// systems, elsewhere it is read from $COLUMNS.
+func terminalWidth(*os.File) int {
+	return 0
+}
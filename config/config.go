@@ -0,0 +1,382 @@
+// Package config loads default settings for the searchast command line tools
+// from TOML files. Settings are read from the user configuration directory and
+// from the nearest project file, and are applied as defaults to command line
+// flags so that explicitly passed flags always take precedence.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/andersonjoseph/searchast/language"
+)
+
+// ProjectFilename is the name of the project level configuration file.
+const ProjectFilename = ".searchast.toml"
+
+// Config holds the settings read from one or more configuration files.
+// Unset fields are nil so that merging only overrides what a file defines.
+type Config struct {
+	Format  Format  `toml:"format"`
+	Context Context `toml:"context"`
+	Search  Search  `toml:"search"`
+	Cache   Cache   `toml:"cache"`
+	// Ignore lists glob patterns for files and directories to skip when walking paths.
+	Ignore []string `toml:"ignore"`
+	// Languages maps file extensions to supported language names, e.g. ".tpl" = "html".
+	Languages map[string]string `toml:"languages"`
+}
+
+// Format holds the defaults for the TextFormatter options.
+type Format struct {
+	LineNumbers     *bool   `toml:"line-numbers"`
+	HighlightSymbol *string `toml:"highlight-symbol"`
+	ContextSymbol   *string `toml:"context-symbol"`
+	GapSymbol       *string `toml:"gap-symbol"`
+	Spacer          *string `toml:"spacer"`
+	Color           *string `toml:"color"`
+	HighlightSyntax *bool   `toml:"highlight-syntax"`
+	Theme           *string `toml:"theme"`
+	ColorDepth      *string `toml:"color-depth"`
+	Heading         *bool   `toml:"heading"`
+	Separator       *string `toml:"separator"`
+	HyperlinkFormat *string `toml:"hyperlink-format"`
+	TabWidth        *uint32 `toml:"tab-width"`
+	MaxColumns      *string `toml:"max-columns"`
+	MaxLineBytes    *uint32 `toml:"max-line-bytes"`
+}
+
+// Context holds the defaults for the contextBuilder options.
+type Context struct {
+	SurroundingLines    *uint32 `toml:"surrounding-lines"`
+	ChildLines          *uint32 `toml:"child-lines"`
+	GapToClose          *uint32 `toml:"gap-to-close"`
+	ParentContext       *bool   `toml:"parent-context"`
+	CloseScopeGaps      *bool   `toml:"close-scope-gaps"`
+	ExpandInitialScopes *bool   `toml:"expand-scopes"`
+}
+
+// Search holds the defaults for the search options.
+type Search struct {
+	FixedStrings *bool `toml:"fixed-strings"`
+	IgnoreCase   *bool `toml:"ignore-case"`
+	SmartCase    *bool `toml:"smart-case"`
+	WholeWord    *bool `toml:"whole-word"`
+}
+
+// Cache holds the settings of the parse cache.
+type Cache struct {
+	Enabled *bool `toml:"enabled"`
+	// Dir is the cache directory, which can only be set in the user file.
+	Dir *string `toml:"dir"`
+	// MaxSize is the size limit of the cache in MiB, zero for no limit.
+	MaxSize *uint32 `toml:"max-size"`
+}
+
+// Paths returns the configuration files to consider, in increasing order of
+// precedence: the user file under $XDG_CONFIG_HOME (or ~/.config) and the
+// nearest ProjectFilename found walking up from dir.
+func Paths(dir string) []string {
+	var paths []string
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			configHome = filepath.Join(home, ".config")
+		}
+	}
+	if configHome != "" {
+		paths = append(paths, filepath.Join(configHome, "searchast", "config.toml"))
+	}
+
+	for current := dir; ; {
+		candidate := filepath.Join(current, ProjectFilename)
+		if _, err := os.Stat(candidate); err == nil {
+			paths = append(paths, candidate)
+			break
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			break
+		}
+		current = parent
+	}
+
+	return paths
+}
+
+// Discover loads every configuration file returned by Paths for the current
+// working directory. Missing files are skipped, and project files setting the
+// cache directory are rejected.
+func Discover() (*Config, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	cfg := &Config{}
+	for _, path := range Paths(cwd) {
+		fileCfg, err := Load(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// The cache directory is pruned and cleaned, so a project can't
+		// point it at its own files.
+		if filepath.Base(path) == ProjectFilename && fileCfg.Cache.Dir != nil {
+			return nil, fmt.Errorf("cache dir can only be set in the user config file, not in %s", path)
+		}
+		cfg.Merge(fileCfg)
+	}
+
+	return cfg, nil
+}
+
+// Resolve returns the configuration selected by the command line: an empty
+// configuration if disabled is true, the file at path if it is not empty, or
+// the discovered configuration otherwise.
+func Resolve(path string, disabled bool) (*Config, error) {
+	switch {
+	case disabled:
+		return &Config{}, nil
+	case path != "":
+		return Load(path)
+	default:
+		return Discover()
+	}
+}
+
+// Load reads a single configuration file.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	meta, err := toml.DecodeFile(path, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config file %s: %w", path, err)
+	}
+
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("unknown key %s in config file %s", undecoded[0], path)
+	}
+
+	return cfg, nil
+}
+
+// Merge overrides the settings of c with the ones defined in other.
+// Ignore patterns are appended and language mappings are combined.
+func (c *Config) Merge(other *Config) {
+	mergeValue(&c.Format.LineNumbers, other.Format.LineNumbers)
+	mergeValue(&c.Format.HighlightSymbol, other.Format.HighlightSymbol)
+	mergeValue(&c.Format.ContextSymbol, other.Format.ContextSymbol)
+	mergeValue(&c.Format.GapSymbol, other.Format.GapSymbol)
+	mergeValue(&c.Format.Spacer, other.Format.Spacer)
+	mergeValue(&c.Format.Color, other.Format.Color)
+	mergeValue(&c.Format.HighlightSyntax, other.Format.HighlightSyntax)
+	mergeValue(&c.Format.Theme, other.Format.Theme)
+	mergeValue(&c.Format.ColorDepth, other.Format.ColorDepth)
+	mergeValue(&c.Format.Heading, other.Format.Heading)
+	mergeValue(&c.Format.Separator, other.Format.Separator)
+	mergeValue(&c.Format.HyperlinkFormat, other.Format.HyperlinkFormat)
+	mergeValue(&c.Format.TabWidth, other.Format.TabWidth)
+	mergeValue(&c.Format.MaxColumns, other.Format.MaxColumns)
+	mergeValue(&c.Format.MaxLineBytes, other.Format.MaxLineBytes)
+
+	mergeValue(&c.Context.SurroundingLines, other.Context.SurroundingLines)
+	mergeValue(&c.Context.ChildLines, other.Context.ChildLines)
+	mergeValue(&c.Context.GapToClose, other.Context.GapToClose)
+	mergeValue(&c.Context.ParentContext, other.Context.ParentContext)
+	mergeValue(&c.Context.CloseScopeGaps, other.Context.CloseScopeGaps)
+	mergeValue(&c.Context.ExpandInitialScopes, other.Context.ExpandInitialScopes)
+
+	mergeValue(&c.Search.FixedStrings, other.Search.FixedStrings)
+	mergeValue(&c.Search.IgnoreCase, other.Search.IgnoreCase)
+	mergeValue(&c.Search.SmartCase, other.Search.SmartCase)
+	mergeValue(&c.Search.WholeWord, other.Search.WholeWord)
+
+	mergeValue(&c.Cache.Enabled, other.Cache.Enabled)
+	mergeValue(&c.Cache.Dir, other.Cache.Dir)
+	mergeValue(&c.Cache.MaxSize, other.Cache.MaxSize)
+
+	c.Ignore = append(c.Ignore, other.Ignore...)
+
+	if len(other.Languages) > 0 && c.Languages == nil {
+		c.Languages = make(map[string]string, len(other.Languages))
+	}
+	for ext, name := range other.Languages {
+		c.Languages[ext] = name
+	}
+}
+
+func mergeValue[T any](dst **T, src *T) {
+	if src != nil {
+		*dst = src
+	}
+}
+
+// Apply sets the configured values as the values of the matching flags in fs,
+// keyed by the same names used in the configuration file, except for the
+// search options which use the short grep style flag names. Flags that were
+// explicitly set on the command line and flags not defined in fs are left
+// untouched. It must be called after fs has been parsed.
+func (c *Config) Apply(fs *flag.FlagSet) error {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	values := map[string]string{}
+	setString := func(name string, v *string) {
+		if v != nil {
+			values[name] = *v
+		}
+	}
+	setBool := func(name string, v *bool) {
+		if v != nil {
+			values[name] = strconv.FormatBool(*v)
+		}
+	}
+	setUint := func(name string, v *uint32) {
+		if v != nil {
+			values[name] = strconv.FormatUint(uint64(*v), 10)
+		}
+	}
+
+	setBool("line-numbers", c.Format.LineNumbers)
+	setString("highlight-symbol", c.Format.HighlightSymbol)
+	setString("context-symbol", c.Format.ContextSymbol)
+	setString("gap-symbol", c.Format.GapSymbol)
+	setString("spacer", c.Format.Spacer)
+	setString("color", c.Format.Color)
+	setBool("highlight-syntax", c.Format.HighlightSyntax)
+	setString("theme", c.Format.Theme)
+	setString("color-depth", c.Format.ColorDepth)
+	setBool("heading", c.Format.Heading)
+	setString("separator", c.Format.Separator)
+	setString("hyperlink-format", c.Format.HyperlinkFormat)
+	setUint("tab-width", c.Format.TabWidth)
+	setString("max-columns", c.Format.MaxColumns)
+	setUint("max-line-bytes", c.Format.MaxLineBytes)
+
+	setUint("surrounding-lines", c.Context.SurroundingLines)
+	setUint("child-lines", c.Context.ChildLines)
+	setUint("gap-to-close", c.Context.GapToClose)
+	setBool("parent-context", c.Context.ParentContext)
+	setBool("close-scope-gaps", c.Context.CloseScopeGaps)
+	setBool("expand-scopes", c.Context.ExpandInitialScopes)
+
+	setBool("F", c.Search.FixedStrings)
+	setBool("i", c.Search.IgnoreCase)
+	setBool("S", c.Search.SmartCase)
+	setBool("w", c.Search.WholeWord)
+
+	setBool("cache", c.Cache.Enabled)
+	setString("cache-dir", c.Cache.Dir)
+	setUint("cache-max-size", c.Cache.MaxSize)
+
+	for name, value := range values {
+		if explicit[name] || fs.Lookup(name) == nil {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid config value for %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// RegisterLanguages registers the configured extension to language mappings.
+func (c *Config) RegisterLanguages() error {
+	for ext, name := range c.Languages {
+		if err := language.Register(ext, name); err != nil {
+			return fmt.Errorf("invalid language mapping for %s: %w", ext, err)
+		}
+	}
+
+	return nil
+}
+
+// Ignored reports whether path matches any of the ignore patterns. Patterns are
+// matched against the slash separated path, its base name and each of its
+// parent directories.
+func (c *Config) Ignored(path string) bool {
+	path = filepath.ToSlash(filepath.Clean(path))
+
+	for _, pattern := range c.Ignore {
+		pattern = strings.TrimSuffix(pattern, "/")
+
+		segments := strings.Split(path, "/")
+		for i := range segments {
+			if ok, _ := filepath.Match(pattern, segments[i]); ok {
+				return true
+			}
+			if ok, _ := filepath.Match(pattern, strings.Join(segments[:i+1], "/")); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Rule is a named search pattern read from a rules file.
+type Rule struct {
+	ID          string `toml:"id"`
+	Pattern     string `toml:"pattern"`
+	Description string `toml:"description"`
+	// Level is the severity of the matches: "error", "warning", "note", or
+	// empty for the default level.
+	Level string `toml:"level"`
+}
+
+// LoadRules reads a rules file, a TOML file with a [[rule]] table per rule:
+//
+//	[[rule]]
+//	id = "no-print"
+//	pattern = 'fmt\.Print'
+//	description = "Use the logger instead of printing"
+//	level = "warning"
+func LoadRules(path string) ([]Rule, error) {
+	var file struct {
+		Rules []Rule `toml:"rule"`
+	}
+
+	meta, err := toml.DecodeFile(path, &file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rules file %s: %w", path, err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("unknown key %s in rules file %s", undecoded[0], path)
+	}
+
+	seen := make(map[string]bool)
+	for i, rule := range file.Rules {
+		switch {
+		case rule.ID == "":
+			return nil, fmt.Errorf("rule %d in %s has no id", i+1, path)
+		case rule.Pattern == "":
+			return nil, fmt.Errorf("rule %s in %s has no pattern", rule.ID, path)
+		case seen[rule.ID]:
+			return nil, fmt.Errorf("duplicated rule %s in %s", rule.ID, path)
+		}
+
+		switch rule.Level {
+		case "", "error", "warning", "note":
+		default:
+			return nil, fmt.Errorf("rule %s in %s has an invalid level %s", rule.ID, path, rule.Level)
+		}
+
+		seen[rule.ID] = true
+	}
+
+	return file.Rules, nil
+}
@@ -0,0 +1,287 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/andersonjoseph/searchast/language"
+)
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("reads all sections", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ProjectFilename)
+		writeFile(t, path, `
+ignore = ["vendor", "*.pb.go"]
+
+[format]
+highlight-symbol = ">>"
+line-numbers = false
+
+[context]
+surrounding-lines = 5
+
+[search]
+smart-case = true
+whole-word = true
+
+[cache]
+enabled = true
+
+[languages]
+".tpl" = "html"
+`)
+
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+
+		if cfg.Format.HighlightSymbol == nil || *cfg.Format.HighlightSymbol != ">>" {
+			t.Errorf("expected highlight-symbol to be >>, got %v", cfg.Format.HighlightSymbol)
+		}
+		if cfg.Format.LineNumbers == nil || *cfg.Format.LineNumbers {
+			t.Errorf("expected line-numbers to be false, got %v", cfg.Format.LineNumbers)
+		}
+		if cfg.Format.GapSymbol != nil {
+			t.Errorf("expected gap-symbol to be unset, got %v", *cfg.Format.GapSymbol)
+		}
+		if cfg.Context.SurroundingLines == nil || *cfg.Context.SurroundingLines != 5 {
+			t.Errorf("expected surrounding-lines to be 5, got %v", cfg.Context.SurroundingLines)
+		}
+		if cfg.Search.SmartCase == nil || !*cfg.Search.SmartCase {
+			t.Errorf("expected smart-case to be true, got %v", cfg.Search.SmartCase)
+		}
+		if cfg.Search.WholeWord == nil || !*cfg.Search.WholeWord {
+			t.Errorf("expected whole-word to be true, got %v", cfg.Search.WholeWord)
+		}
+		if cfg.Cache.Enabled == nil || !*cfg.Cache.Enabled {
+			t.Errorf("expected cache.enabled to be true, got %v", cfg.Cache.Enabled)
+		}
+		if !reflect.DeepEqual(cfg.Ignore, []string{"vendor", "*.pb.go"}) {
+			t.Errorf("unexpected ignore patterns: %v", cfg.Ignore)
+		}
+		if cfg.Languages[".tpl"] != "html" {
+			t.Errorf("expected .tpl to map to html, got %q", cfg.Languages[".tpl"])
+		}
+	})
+
+	t.Run("returns an error for unknown keys", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ProjectFilename)
+		writeFile(t, path, "[format]\nhighlight = \">>\"\n")
+
+		if _, err := Load(path); err == nil {
+			t.Fatal("expected an error for an unknown key, but got none")
+		}
+	})
+}
+
+func TestPaths(t *testing.T) {
+	root := t.TempDir()
+	configHome := filepath.Join(root, "config")
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+
+	project := filepath.Join(root, "project")
+	nested := filepath.Join(project, "a", "b")
+	writeFile(t, filepath.Join(project, ProjectFilename), "")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	expected := []string{
+		filepath.Join(configHome, "searchast", "config.toml"),
+		filepath.Join(project, ProjectFilename),
+	}
+	if paths := Paths(nested); !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected paths %v, but got %v", expected, paths)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	root := t.TempDir()
+	configHome := filepath.Join(root, "config")
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	project := filepath.Join(root, "project")
+
+	writeFile(t, filepath.Join(configHome, "searchast", "config.toml"), "[cache]\ndir = \"/tmp/cache\"\n")
+	writeFile(t, filepath.Join(project, ProjectFilename), "[cache]\nenabled = true\n")
+	t.Chdir(project)
+
+	cfg, err := Discover()
+	if err != nil {
+		t.Fatalf("failed to discover configuration: %v", err)
+	}
+	if cfg.Cache.Dir == nil || *cfg.Cache.Dir != "/tmp/cache" || cfg.Cache.Enabled == nil || !*cfg.Cache.Enabled {
+		t.Errorf("expected the cache settings of both files, got %+v", cfg.Cache)
+	}
+
+	writeFile(t, filepath.Join(project, ProjectFilename), "[cache]\ndir = \".\"\n")
+	if _, err := Discover(); err == nil {
+		t.Error("expected an error for a project file setting the cache directory")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	user := ">"
+	project := ">>"
+	surrounding := uint32(1)
+
+	cfg := &Config{Ignore: []string{"vendor"}}
+	cfg.Merge(&Config{
+		Format:  Format{HighlightSymbol: &user},
+		Context: Context{SurroundingLines: &surrounding},
+	})
+	cfg.Merge(&Config{
+		Format: Format{HighlightSymbol: &project},
+		Ignore: []string{"testdata"},
+	})
+
+	if *cfg.Format.HighlightSymbol != project {
+		t.Errorf("expected later files to override, got %s", *cfg.Format.HighlightSymbol)
+	}
+	if *cfg.Context.SurroundingLines != surrounding {
+		t.Errorf("expected unset values to be kept, got %d", *cfg.Context.SurroundingLines)
+	}
+	if !reflect.DeepEqual(cfg.Ignore, []string{"vendor", "testdata"}) {
+		t.Errorf("expected ignore patterns to be appended, got %v", cfg.Ignore)
+	}
+}
+
+func TestApply(t *testing.T) {
+	symbol := ">>"
+	spacer := "  "
+	lines := uint32(7)
+	cfg := &Config{
+		Format:  Format{HighlightSymbol: &symbol, Spacer: &spacer},
+		Context: Context{SurroundingLines: &lines},
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	highlight := fs.String("highlight-symbol", "█", "")
+	spacerFlag := fs.String("spacer", " ", "")
+	surrounding := fs.Uint("surrounding-lines", 3, "")
+	if err := fs.Parse([]string{"-spacer", "|"}); err != nil {
+		t.Fatalf("failed to parse flags: %v", err)
+	}
+
+	if err := cfg.Apply(fs); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+
+	if *highlight != ">>" {
+		t.Errorf("expected config value to be applied, got %q", *highlight)
+	}
+	if *surrounding != 7 {
+		t.Errorf("expected config value to be applied, got %d", *surrounding)
+	}
+	if *spacerFlag != "|" {
+		t.Errorf("expected explicit flag to take precedence, got %q", *spacerFlag)
+	}
+}
+
+func TestRegisterLanguages(t *testing.T) {
+	cfg := &Config{Languages: map[string]string{".cshtml": "c_sharp", ".tpl": "html"}}
+	if err := cfg.RegisterLanguages(); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+
+	for filename, expected := range map[string]string{"view.cshtml": "csharp", "page.tpl": "html"} {
+		if name, err := language.NameFromFilename(filename); err != nil || name != expected {
+			t.Errorf("expected %s to map to %s, got %q (%v)", filename, expected, name, err)
+		}
+	}
+
+	cfg = &Config{Languages: map[string]string{".x": "unknown"}}
+	if err := cfg.RegisterLanguages(); err == nil {
+		t.Error("expected an error for an unknown language, but got none")
+	}
+}
+
+func TestIgnored(t *testing.T) {
+	cfg := &Config{Ignore: []string{"vendor/", "*.pb.go", "internal/gen"}}
+
+	testCases := []struct {
+		path     string
+		expected bool
+	}{
+		{"vendor", true},
+		{"vendor/lib/lib.go", true},
+		{"api/service.pb.go", true},
+		{"internal/gen/code.go", true},
+		{"internal/app/main.go", false},
+		{"main.go", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			if got := cfg.Ignored(tc.path); got != tc.expected {
+				t.Errorf("expected Ignored(%q) to be %v, got %v", tc.path, tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	t.Run("reads every rule", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "rules.toml")
+		writeFile(t, path, `
+[[rule]]
+id = "no-print"
+pattern = 'fmt\.Print'
+description = "Use the logger instead of printing"
+
+[[rule]]
+id = "no-panic"
+pattern = 'panic\('
+level = "error"
+`)
+
+		rules, err := LoadRules(path)
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+
+		if len(rules) != 2 {
+			t.Fatalf("expected 2 rules, got %d", len(rules))
+		}
+		if rules[0].ID != "no-print" || rules[0].Pattern != `fmt\.Print` || rules[0].Description == "" {
+			t.Errorf("unexpected first rule: %+v", rules[0])
+		}
+		if rules[1].Level != "error" {
+			t.Errorf("expected second rule level to be error, got %q", rules[1].Level)
+		}
+	})
+
+	testCases := []struct {
+		name    string
+		content string
+	}{
+		{"rejects rules without id", "[[rule]]\npattern = 'x'\n"},
+		{"rejects rules without pattern", "[[rule]]\nid = 'x'\n"},
+		{"rejects duplicated ids", "[[rule]]\nid = 'x'\npattern = 'x'\n[[rule]]\nid = 'x'\npattern = 'y'\n"},
+		{"rejects invalid levels", "[[rule]]\nid = 'x'\npattern = 'x'\nlevel = 'fatal'\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rules.toml")
+			writeFile(t, path, tc.content)
+
+			if _, err := LoadRules(path); err == nil {
+				t.Fatal("expected an error but got none")
+			}
+		})
+	}
+}
@@ -238,3 +238,15 @@ func WithExpandChildScopes(enabled bool) Option {
 		cb.ExpandInitialScopes = enabled
 	}
 }
+
+// OverviewOptions returns the options used to outline a file from its top
+// level lines, which show the start of each scope without expanding it.
+func OverviewOptions() []Option {
+	return []Option{
+		WithSurroundingLines(2),
+		WithParentContext(false),
+		WithCloseScopeGaps(false),
+		WithExpandChildScopes(false),
+		WithChildLines(3),
+	}
+}
@@ -0,0 +1,178 @@
+package searchast
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andersonjoseph/searchast/diff"
+)
+
+const (
+	ansiCodeGreen = "\033[32m"
+	ansiCodeBold  = "\033[1m"
+)
+
+// DiffFormatter renders the changes between two versions of a file with "+"
+// and "-" markers. Unlike a plain unified diff, the context of each change is
+// chosen by a context builder from the scopes of the version it belongs to, so
+// changes are shown within their enclosing functions and classes.
+type DiffFormatter struct {
+	lineNumbers  bool
+	gapSymbol    string
+	enableColors bool
+}
+
+type DiffFormatterOption func(*DiffFormatter)
+
+func NewDiffFormatter(opts ...DiffFormatterOption) *DiffFormatter {
+	formatter := &DiffFormatter{
+		lineNumbers:  true,
+		gapSymbol:    "⋮",
+		enableColors: false,
+	}
+
+	for _, opt := range opts {
+		opt(formatter)
+	}
+
+	return formatter
+}
+
+func WithDiffLineNumbers(lineNumbers bool) DiffFormatterOption {
+	return func(df *DiffFormatter) {
+		df.lineNumbers = lineNumbers
+	}
+}
+
+func WithDiffGapSymbol(symbol string) DiffFormatterOption {
+	return func(df *DiffFormatter) {
+		df.gapSymbol = symbol
+	}
+}
+
+func WithDiffColors(enabled bool) DiffFormatterOption {
+	return func(df *DiffFormatter) {
+		df.enableColors = enabled
+	}
+}
+
+// WriteHeader writes the paths of the old and new versions of a file.
+func (df *DiffFormatter) WriteHeader(w io.Writer, oldPath string, newPath string) error {
+	header := fmt.Sprintf("--- %s\n+++ %s", oldPath, newPath)
+	if df.enableColors {
+		header = ansiCodeBold + header + ansiCodeReset
+	}
+
+	_, err := fmt.Fprintln(w, header)
+	return err
+}
+
+// FormatTo writes the changed lines of the diff between oldTree and newTree,
+// as computed by diff.Lines, with the context cb adds around the removed lines
+// in oldTree and around the added lines in newTree. Nothing is written if the
+// versions are equal.
+func (df *DiffFormatter) FormatTo(w io.Writer, oldTree *sourceTree, newTree *sourceTree, lines []diff.Line, cb *contextBuilder) error {
+	removed := NewSet[lineNumber]()
+	added := NewSet[lineNumber]()
+	for _, l := range lines {
+		switch l.Op {
+		case diff.Removed:
+			removed.Add(lineNumber(l.OldNumber - 1))
+		case diff.Added:
+			added.Add(lineNumber(l.NewNumber - 1))
+		}
+	}
+
+	return df.FormatChangesTo(w, oldTree, newTree, lines, removed, added, cb)
+}
+
+// FormatChangesTo is like FormatTo, but only adds context around the given
+// 0-based lines of oldTree and newTree, such as the changed lines matching a
+// search. Other changes are only written when they are part of that context,
+// or next to a given line like the new version of a modified line. Nothing is
+// written if both sets are empty.
+func (df *DiffFormatter) FormatChangesTo(w io.Writer, oldTree *sourceTree, newTree *sourceTree, lines []diff.Line, removed Set[lineNumber], added Set[lineNumber], cb *contextBuilder) error {
+	if len(removed) == 0 && len(added) == 0 {
+		return nil
+	}
+
+	oldToShow := cb.AddContext(oldTree, removed)
+	newToShow := cb.AddContext(newTree, added)
+
+	// The gap symbol is aligned with the markers.
+	oldWidth, newWidth, gapIndent := 0, 0, 0
+	if df.lineNumbers {
+		oldWidth = len(fmt.Sprint(len(oldTree.lines)))
+		newWidth = len(fmt.Sprint(len(newTree.lines)))
+		gapIndent = oldWidth + newWidth + 2
+	}
+
+	shown := make([]bool, len(lines))
+	for i, l := range lines {
+		shown[i] = (l.OldNumber > 0 && oldToShow.Has(lineNumber(l.OldNumber-1))) ||
+			(l.NewNumber > 0 && newToShow.Has(lineNumber(l.NewNumber-1)))
+	}
+
+	// Runs of consecutive changes are shown entirely if any of their lines is.
+	for start := 0; start < len(lines); start++ {
+		if lines[start].Op == diff.Context {
+			continue
+		}
+
+		end, isRunShown := start, false
+		for ; end < len(lines) && lines[end].Op != diff.Context; end++ {
+			isRunShown = isRunShown || shown[end]
+		}
+		for i := start; i < end; i++ {
+			shown[i] = isRunShown
+		}
+		start = end
+	}
+
+	isGapPrinted := false
+	for i, l := range lines {
+		isShown := shown[i]
+
+		if !isShown {
+			if !isGapPrinted {
+				if _, err := fmt.Fprintf(w, "%*s%s\n", gapIndent, "", df.gapSymbol); err != nil {
+					return err
+				}
+				isGapPrinted = true
+			}
+			continue
+		}
+		isGapPrinted = false
+
+		gutter := string(l.Op)
+		if df.lineNumbers {
+			gutter = fmt.Sprintf("%s %s %c", lineNumberColumn(l.OldNumber, oldWidth), lineNumberColumn(l.NewNumber, newWidth), l.Op)
+		}
+
+		text := gutter + " │ " + l.Text
+		if df.enableColors {
+			switch l.Op {
+			case diff.Removed:
+				text = ansiCodeRed + text + ansiCodeReset
+			case diff.Added:
+				text = ansiCodeGreen + text + ansiCodeReset
+			}
+		}
+
+		if _, err := fmt.Fprintln(w, text); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lineNumberColumn right-aligns a 1-based line number, leaving the column
+// blank for lines missing from a version.
+func lineNumberColumn(number int, width int) string {
+	if number == 0 {
+		return strings.Repeat(" ", width)
+	}
+	return fmt.Sprintf("%*d", width, number)
+}
@@ -0,0 +1,193 @@
+// Package diff parses unified diffs, as produced by git diff or diff -u, and
+// computes line diffs, to find the lines changed in each file.
+package diff
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Op is the operation applied to a line of a hunk.
+type Op byte
+
+const (
+	Context Op = ' '
+	Added   Op = '+'
+	Removed Op = '-'
+)
+
+// File is the diff of a single file. OldPath is empty for created files and
+// NewPath is empty for deleted files.
+type File struct {
+	OldPath string
+	NewPath string
+	Hunks   []Hunk
+}
+
+// Hunk is a group of changed lines with their context.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []Line
+}
+
+// Line is a line of a diff. OldNumber and NewNumber are the 1-based line
+// numbers in the old and new file, 0 for lines missing from the file.
+type Line struct {
+	Op        Op
+	Text      string
+	OldNumber int
+	NewNumber int
+}
+
+// AddedLines returns the 1-based numbers of the lines added to the new file,
+// which include the new version of modified lines.
+func (f File) AddedLines() []int {
+	var lines []int
+	for _, hunk := range f.Hunks {
+		for _, line := range hunk.Lines {
+			if line.Op == Added {
+				lines = append(lines, line.NewNumber)
+			}
+		}
+	}
+
+	return lines
+}
+
+// Parse reads the files of a unified diff. The "a/" and "b/" prefixes of git
+// diffs are removed from the paths. Anything outside of the file headers and
+// hunks, such as git extended headers, is ignored.
+func Parse(r io.Reader) ([]File, error) {
+	var (
+		files []File
+		hunk  *Hunk
+		// remaining old and new lines of the current hunk
+		oldLeft, newLeft int
+		oldLine, newLine int
+	)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		text := scanner.Text()
+		lineNumber++
+
+		if hunk != nil && (oldLeft > 0 || newLeft > 0) {
+			if text == "" {
+				text = " " // some tools strip the space of empty context lines
+			}
+
+			line := Line{Op: Op(text[0]), Text: text[1:]}
+			switch line.Op {
+			case Context:
+				line.OldNumber, line.NewNumber = oldLine, newLine
+				oldLine++
+				newLine++
+				oldLeft--
+				newLeft--
+			case Removed:
+				line.OldNumber = oldLine
+				oldLine++
+				oldLeft--
+			case Added:
+				line.NewNumber = newLine
+				newLine++
+				newLeft--
+			case '\\': // \ No newline at end of file
+				continue
+			default:
+				return nil, fmt.Errorf("line %d: unexpected line in hunk: %q", lineNumber, text)
+			}
+			hunk.Lines = append(hunk.Lines, line)
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(text, "--- "):
+			files = append(files, File{OldPath: parsePath(text[4:], "a/")})
+			hunk = nil
+		case strings.HasPrefix(text, "+++ "):
+			if len(files) == 0 {
+				return nil, fmt.Errorf("line %d: missing --- line before +++ line", lineNumber)
+			}
+			files[len(files)-1].NewPath = parsePath(text[4:], "b/")
+		case strings.HasPrefix(text, "@@ "):
+			if len(files) == 0 {
+				return nil, fmt.Errorf("line %d: hunk outside of a file", lineNumber)
+			}
+
+			file := &files[len(files)-1]
+			h, err := parseHunkHeader(text)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			file.Hunks = append(file.Hunks, h)
+			hunk = &file.Hunks[len(file.Hunks)-1]
+			oldLeft, newLeft = h.OldLines, h.NewLines
+			oldLine, newLine = h.OldStart, h.NewStart
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read diff: %w", err)
+	}
+
+	return files, nil
+}
+
+// parsePath returns the path of a --- or +++ line, or an empty string for
+// /dev/null.
+func parsePath(text string, prefix string) string {
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	} else if i := strings.IndexByte(text, '\t'); i >= 0 {
+		text = text[:i] // diff -u adds the modification time
+	}
+
+	if text == "/dev/null" {
+		return ""
+	}
+
+	return strings.TrimPrefix(text, prefix)
+}
+
+// parseHunkHeader parses a "@@ -start,lines +start,lines @@" line. The
+// number of lines is 1 when omitted.
+func parseHunkHeader(text string) (Hunk, error) {
+	fields := strings.Fields(text)
+	if len(fields) < 4 || fields[3] != "@@" || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return Hunk{}, fmt.Errorf("invalid hunk header %q", text)
+	}
+
+	var h Hunk
+	var err error
+	if h.OldStart, h.OldLines, err = parseRange(fields[1][1:]); err != nil {
+		return Hunk{}, fmt.Errorf("invalid hunk header %q: %w", text, err)
+	}
+	if h.NewStart, h.NewLines, err = parseRange(fields[2][1:]); err != nil {
+		return Hunk{}, fmt.Errorf("invalid hunk header %q: %w", text, err)
+	}
+
+	return h, nil
+}
+
+func parseRange(text string) (int, int, error) {
+	startText, linesText, hasLines := strings.Cut(text, ",")
+
+	start, err := strconv.Atoi(startText)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !hasLines {
+		return start, 1, nil
+	}
+
+	lines, err := strconv.Atoi(linesText)
+	return start, lines, err
+}
@@ -0,0 +1,92 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const gitDiff = `diff --git a/cmd/main.go b/cmd/main.go
+index 3b18e51..a9c1f5e 100644
+--- a/cmd/main.go
++++ b/cmd/main.go
+@@ -3,3 +3,4 @@ import "fmt"
+ func main() {
+-	fmt.Println("hello")
++	fmt.Println("hello, world")
++	fmt.Println("bye")
+ }
+@@ -10 +10,0 @@ func other() {
+-	return
+diff --git a/new.go b/new.go
+new file mode 100644
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1,2 @@
++package main
++
+\ No newline at end of file
+`
+
+func TestParse(t *testing.T) {
+	files, err := Parse(strings.NewReader(gitDiff))
+	if err != nil {
+		t.Fatalf("failed to parse diff: %v", err)
+	}
+
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(files))
+	}
+
+	t.Run("parses the paths", func(t *testing.T) {
+		if files[0].OldPath != "cmd/main.go" || files[0].NewPath != "cmd/main.go" {
+			t.Errorf("unexpected paths %q and %q", files[0].OldPath, files[0].NewPath)
+		}
+		if files[1].OldPath != "" || files[1].NewPath != "new.go" {
+			t.Errorf("expected a created file, got %q and %q", files[1].OldPath, files[1].NewPath)
+		}
+	})
+
+	t.Run("numbers the lines of each hunk", func(t *testing.T) {
+		expected := []Line{
+			{Op: Context, Text: "func main() {", OldNumber: 3, NewNumber: 3},
+			{Op: Removed, Text: "\tfmt.Println(\"hello\")", OldNumber: 4},
+			{Op: Added, Text: "\tfmt.Println(\"hello, world\")", NewNumber: 4},
+			{Op: Added, Text: "\tfmt.Println(\"bye\")", NewNumber: 5},
+			{Op: Context, Text: "}", OldNumber: 5, NewNumber: 6},
+		}
+		if !reflect.DeepEqual(files[0].Hunks[0].Lines, expected) {
+			t.Errorf("expected lines %+v, got %+v", expected, files[0].Hunks[0].Lines)
+		}
+
+		hunk := files[0].Hunks[1]
+		if hunk.OldStart != 10 || hunk.OldLines != 1 || hunk.NewStart != 10 || hunk.NewLines != 0 {
+			t.Errorf("unexpected hunk header %+v", hunk)
+		}
+	})
+
+	t.Run("reports the added lines", func(t *testing.T) {
+		if added := files[0].AddedLines(); !reflect.DeepEqual(added, []int{4, 5}) {
+			t.Errorf("expected added lines [4 5], got %v", added)
+		}
+		if added := files[1].AddedLines(); !reflect.DeepEqual(added, []int{1, 2}) {
+			t.Errorf("expected added lines [1 2], got %v", added)
+		}
+	})
+
+	t.Run("parses plain unified diffs", func(t *testing.T) {
+		files, err := Parse(strings.NewReader("--- main.go\t2024-01-01 10:00:00\n+++ main.go\t2024-01-02 10:00:00\n@@ -1 +1 @@\n-a\n+b\n"))
+		if err != nil {
+			t.Fatalf("failed to parse diff: %v", err)
+		}
+		if files[0].NewPath != "main.go" || !reflect.DeepEqual(files[0].AddedLines(), []int{1}) {
+			t.Errorf("unexpected file %+v", files[0])
+		}
+	})
+
+	t.Run("returns an error for invalid hunks", func(t *testing.T) {
+		if _, err := Parse(strings.NewReader("--- a\n+++ b\n@@ -1 +x @@\n")); err == nil {
+			t.Fatal("expected an error but got none")
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package diff
+
+import (
+	"slices"
+)
+
+// Lines computes the difference between two versions of a file with Myers'
+// algorithm. It returns every line of both versions in order, unchanged lines
+// as Context and each change as Removed lines followed by Added lines.
+func Lines(oldLines []string, newLines []string) []Line {
+	n, m := len(oldLines), len(newLines)
+	offset := n + m + 1
+
+	// v holds the furthest x reached on each diagonal k = x - y, and trace
+	// the diagonals around 0 before each round d, which only reach up to d.
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, slices.Clone(v[offset-d:offset+d+1]))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // down: insertion
+			} else {
+				x = v[offset+k-1] + 1 // right: deletion
+			}
+
+			y := x - k
+			for x < n && y < m && oldLines[x] == newLines[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk the trace back from the end to recover the edit script.
+	var reversed []Line
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		round := trace[d]
+		at := func(k int) int {
+			return round[k+d]
+		}
+
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+
+		prevX := 0
+		if d > 0 {
+			prevX = at(prevK)
+		}
+		prevY := prevX - prevK
+		if d == 0 {
+			prevY = 0
+		}
+
+		for x > prevX && y > prevY {
+			reversed = append(reversed, Line{Op: Context, Text: newLines[y-1], OldNumber: x, NewNumber: y})
+			x--
+			y--
+		}
+
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, Line{Op: Added, Text: newLines[y-1], NewNumber: y})
+			} else {
+				reversed = append(reversed, Line{Op: Removed, Text: oldLines[x-1], OldNumber: x})
+			}
+		}
+
+		x, y = prevX, prevY
+	}
+
+	slices.Reverse(reversed)
+	groupChanges(reversed)
+
+	return reversed
+}
+
+// groupChanges reorders each run of changes so that removed lines come
+// before added lines, as in unified diffs.
+func groupChanges(lines []Line) {
+	for start := 0; start < len(lines); {
+		if lines[start].Op == Context {
+			start++
+			continue
+		}
+
+		end := start
+		for end < len(lines) && lines[end].Op != Context {
+			end++
+		}
+
+		slices.SortStableFunc(lines[start:end], func(a, b Line) int {
+			if a.Op == b.Op {
+				return 0
+			}
+			if a.Op == Removed {
+				return -1
+			}
+			return 1
+		})
+		start = end
+	}
+}
@@ -0,0 +1,81 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	testCases := []struct {
+		name     string
+		old      string
+		new      string
+		expected []Line
+	}{
+		{
+			name: "reports unchanged files as context",
+			old:  "a\nb",
+			new:  "a\nb",
+			expected: []Line{
+				{Op: Context, Text: "a", OldNumber: 1, NewNumber: 1},
+				{Op: Context, Text: "b", OldNumber: 2, NewNumber: 2},
+			},
+		},
+		{
+			name: "reports modified lines as removed and added",
+			old:  "a\nb\nc\nd",
+			new:  "a\nx\ny\nc\nd",
+			expected: []Line{
+				{Op: Context, Text: "a", OldNumber: 1, NewNumber: 1},
+				{Op: Removed, Text: "b", OldNumber: 2},
+				{Op: Added, Text: "x", NewNumber: 2},
+				{Op: Added, Text: "y", NewNumber: 3},
+				{Op: Context, Text: "c", OldNumber: 3, NewNumber: 4},
+				{Op: Context, Text: "d", OldNumber: 4, NewNumber: 5},
+			},
+		},
+		{
+			name: "handles changes at both ends",
+			old:  "a\nb\nc",
+			new:  "b\nd",
+			expected: []Line{
+				{Op: Removed, Text: "a", OldNumber: 1},
+				{Op: Context, Text: "b", OldNumber: 2, NewNumber: 1},
+				{Op: Removed, Text: "c", OldNumber: 3},
+				{Op: Added, Text: "d", NewNumber: 2},
+			},
+		},
+		{
+			name:     "handles created files",
+			old:      "",
+			new:      "a",
+			expected: []Line{{Op: Removed, Text: "", OldNumber: 1}, {Op: Added, Text: "a", NewNumber: 1}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lines := Lines(strings.Split(tc.old, "\n"), strings.Split(tc.new, "\n"))
+
+			if !reflect.DeepEqual(lines, tc.expected) {
+				t.Errorf("expected %+v, but got %+v", tc.expected, lines)
+			}
+		})
+	}
+
+	t.Run("finds a minimal diff", func(t *testing.T) {
+		old := strings.Split("a b c a b b a", " ")
+		new := strings.Split("c b a b a c", " ")
+
+		changes := 0
+		for _, line := range Lines(old, new) {
+			if line.Op != Context {
+				changes++
+			}
+		}
+		if changes != 5 {
+			t.Errorf("expected 5 changed lines, got %d", changes)
+		}
+	})
+}
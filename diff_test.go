@@ -0,0 +1,136 @@
+package searchast
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/andersonjoseph/searchast/diff"
+)
+
+func TestDiffFormatter_FormatTo(t *testing.T) {
+	oldSource := `package main
+
+func main() {
+	a := 1
+	b := 2
+	c := 3
+	d := 4
+	e := 5
+	fmt.Println(a, b, c, d, e)
+}
+
+func other() {
+	return
+}`
+	newSource := strings.Replace(oldSource, "e := 5", "e := 6", 1)
+
+	oldTree := mustNewSourceTree(t, oldSource)
+	newTree := mustNewSourceTree(t, newSource)
+	lines := diff.Lines(strings.Split(oldSource, "\n"), strings.Split(newSource, "\n"))
+
+	t.Run("shows changes within their enclosing scope", func(t *testing.T) {
+		output := strings.Builder{}
+		cb := NewContextBuilder(WithSurroundingLines(0), WithChildLines(0), WithGapToClose(0))
+		if err := NewDiffFormatter().FormatTo(&output, oldTree, newTree, lines, cb); err != nil {
+			t.Fatalf("failed to format: %v", err)
+		}
+
+		expected := "" +
+			"      ⋮\n" +
+			" 3  3   │ func main() {\n" +
+			"      ⋮\n" +
+			" 8    - │ \te := 5\n" +
+			"    8 + │ \te := 6\n" +
+			"      ⋮\n" +
+			"10 10   │ }\n" +
+			"      ⋮\n"
+		if output.String() != expected {
+			t.Errorf("expected output:\n%s\nbut got:\n%s", expected, output.String())
+		}
+	})
+
+	t.Run("colors changed lines", func(t *testing.T) {
+		output := strings.Builder{}
+		if err := NewDiffFormatter(WithDiffColors(true), WithDiffLineNumbers(false)).FormatTo(&output, oldTree, newTree, lines, NewContextBuilder()); err != nil {
+			t.Fatalf("failed to format: %v", err)
+		}
+
+		for _, expected := range []string{"\033[31m- │ \te := 5\033[0m\n", "\033[32m+ │ \te := 6\033[0m\n"} {
+			if !strings.Contains(output.String(), expected) {
+				t.Errorf("expected output to contain %q, but got:\n%s", expected, output.String())
+			}
+		}
+	})
+
+	t.Run("writes nothing for equal versions", func(t *testing.T) {
+		output := strings.Builder{}
+		lines := diff.Lines(strings.Split(oldSource, "\n"), strings.Split(oldSource, "\n"))
+		if err := NewDiffFormatter().FormatTo(&output, oldTree, oldTree, lines, NewContextBuilder()); err != nil {
+			t.Fatalf("failed to format: %v", err)
+		}
+
+		if output.String() != "" {
+			t.Errorf("expected no output, but got:\n%s", output.String())
+		}
+	})
+}
+
+func TestDiffFormatter_FormatChangesTo(t *testing.T) {
+	oldSource := `package main
+
+func main() {
+	fmt.Println(1)
+}
+
+func other() {
+	return
+}`
+	newSource := strings.NewReplacer("Println(1)", "Println(2)", "\treturn", "\tlegacy()\n\treturn").Replace(oldSource)
+
+	oldTree := mustNewSourceTree(t, oldSource)
+	newTree := mustNewSourceTree(t, newSource)
+	lines := diff.Lines(strings.Split(oldSource, "\n"), strings.Split(newSource, "\n"))
+
+	testCases := []struct {
+		name     string
+		removed  []uint32
+		added    []uint32
+		expected string
+	}{
+		{
+			name:  "hides changes outside of the context",
+			added: []uint32{7},
+			expected: "" +
+				"     ⋮\n" +
+				"7  7   │ func other() {\n" +
+				"   8 + │ \tlegacy()\n" +
+				"     ⋮\n" +
+				"9 10   │ }\n",
+		},
+		{
+			name:    "shows the whole run of a change",
+			removed: []uint32{3},
+			expected: "" +
+				"     ⋮\n" +
+				"3  3   │ func main() {\n" +
+				"4    - │ \tfmt.Println(1)\n" +
+				"   4 + │ \tfmt.Println(2)\n" +
+				"5  5   │ }\n" +
+				"     ⋮\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output := strings.Builder{}
+			cb := NewContextBuilder(WithSurroundingLines(0), WithChildLines(0), WithGapToClose(0))
+			if err := NewDiffFormatter().FormatChangesTo(&output, oldTree, newTree, lines, NewSetFromSlice(tc.removed), NewSetFromSlice(tc.added), cb); err != nil {
+				t.Fatalf("failed to format: %v", err)
+			}
+
+			if output.String() != tc.expected {
+				t.Errorf("expected output:\n%s\nbut got:\n%s", tc.expected, output.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package searchast
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const ellipsis = "…"
+
+// WithTabWidth expands tabs to spaces, up to the next multiple of width
+// columns. A width of 0 prints tabs as they are.
+func WithTabWidth(width int) TextFormatterOption {
+	return func(tf *TextFormatter) {
+		tf.tabWidth = width
+	}
+}
+
+// WithMaxColumns truncates the printed lines, including the gutter, to the
+// given number of columns, marking the cut with an ellipsis. The window of
+// text kept is moved to keep the first match of the line visible, see
+// WithMatches. A width of 0 disables truncation.
+func WithMaxColumns(columns int) TextFormatterOption {
+	return func(tf *TextFormatter) {
+		tf.maxColumns = columns
+	}
+}
+
+// WithMaxLineBytes replaces lines longer than the given number of bytes with a
+// "[line omitted: N bytes]" note. A limit of 0 prints every line.
+func WithMaxLineBytes(limit int) TextFormatterOption {
+	return func(tf *TextFormatter) {
+		tf.maxLineBytes = limit
+	}
+}
+
+// WithMatches sets the matches of the formatted file, used to keep the
+// matched text visible when truncating lines.
+func WithMatches(matches []Match) TextFormatterOption {
+	return func(tf *TextFormatter) {
+		tf.matchSpans = make(map[lineNumber][2]int, len(matches))
+		for _, match := range matches {
+			if _, exists := tf.matchSpans[match.StartLine]; exists {
+				continue
+			}
+
+			end := -1 // until the end of the line
+			if match.EndLine == match.StartLine {
+				end = match.EndColumn
+			}
+			tf.matchSpans[match.StartLine] = [2]int{match.StartColumn, end}
+		}
+	}
+}
+
+// displayLine returns the line as it is printed after applying the tab width,
+// the byte limit and the column limit, which applies to the text after a
+// gutter of the given width. The tokens of the line are moved accordingly.
+func (tf *TextFormatter) displayLine(l line, number lineNumber, gutterWidth int) line {
+	if tf.maxLineBytes > 0 && len(l.text) > tf.maxLineBytes {
+		return line{text: fmt.Sprintf("[line omitted: %d bytes]", len(l.text))}
+	}
+
+	if tf.tabWidth == 0 && tf.maxColumns == 0 {
+		return l
+	}
+
+	// offsets maps each byte of the original text, and its end, to the byte
+	// where it starts in the new text. columns holds the byte where each
+	// column of the new text starts.
+	offsets := make([]int, len(l.text)+1)
+	columns := make([]int, 0, len(l.text))
+	text := strings.Builder{}
+
+	for i, r := range l.text {
+		for b := i; b < i+utf8.RuneLen(r) && b < len(l.text); b++ {
+			offsets[b] = text.Len()
+		}
+
+		if r == '\t' && tf.tabWidth > 0 {
+			for range tf.tabWidth - len(columns)%tf.tabWidth {
+				columns = append(columns, text.Len())
+				text.WriteByte(' ')
+			}
+			continue
+		}
+
+		columns = append(columns, text.Len())
+		text.WriteRune(r)
+	}
+	offsets[len(l.text)] = text.Len()
+
+	expanded := text.String()
+	start, end := 0, len(expanded)
+	var prefix, suffix string
+
+	width := max(tf.maxColumns-gutterWidth, 3) // room for both ellipses
+	if tf.maxColumns > 0 && len(columns) > width {
+		columnOf := func(offset int) int {
+			return sort.SearchInts(columns, offset)
+		}
+
+		focusStart, focusEnd := 0, 0
+		if span, exists := tf.matchSpans[number]; exists {
+			focusStart = columnOf(offsets[min(span[0], len(l.text))])
+			focusEnd = len(columns)
+			if span[1] >= 0 {
+				focusEnd = columnOf(offsets[min(span[1], len(l.text))])
+			}
+		}
+
+		// Center the match, unless it already fits at the start of the line.
+		firstColumn := 0
+		if focusEnd > width-1 {
+			firstColumn = min(max((focusStart+focusEnd-width)/2, 0), len(columns)-width)
+		}
+		lastColumn := firstColumn + width
+
+		if firstColumn > 0 {
+			prefix = ellipsis
+			firstColumn++
+		}
+		if lastColumn < len(columns) {
+			suffix = ellipsis
+			lastColumn--
+		}
+
+		start = columns[firstColumn]
+		if lastColumn < len(columns) {
+			end = columns[lastColumn]
+		}
+	}
+
+	displayed := line{
+		text:  prefix + expanded[start:end] + suffix,
+		scope: l.scope,
+	}
+	for _, tok := range l.tokens {
+		if tok.start > len(l.text) || tok.end > len(l.text) {
+			continue
+		}
+
+		tokStart := min(max(offsets[tok.start], start), end) - start + len(prefix)
+		tokEnd := min(max(offsets[tok.end], start), end) - start + len(prefix)
+		if tokEnd > tokStart {
+			displayed.tokens = append(displayed.tokens, token{start: tokStart, end: tokEnd, class: tok.class})
+		}
+	}
+
+	return displayed
+}
@@ -0,0 +1,78 @@
+package searchast
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTextFormatter_DisplayLine(t *testing.T) {
+	source := "package main\n\nvar s = \"" + strings.Repeat("a", 40) + "needle" + strings.Repeat("b", 40) + "\"\n\nfunc main() {\n\tif true {\n\t\treturn\n\t}\n}"
+
+	st := mustNewSourceTree(t, source)
+	matches, err := st.Matches([]string{"needle"})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		opts     []TextFormatterOption
+		line     lineNumber
+		expected string
+	}{
+		{
+			name:     "expands tabs to the next tab stop",
+			opts:     []TextFormatterOption{WithTabWidth(4)},
+			line:     6,
+			expected: "7 █         return\n",
+		},
+		{
+			name:     "truncates long lines keeping the match visible",
+			opts:     []TextFormatterOption{WithMaxColumns(24), WithMatches(matches)},
+			line:     2,
+			expected: "3 █ …aaaaaaneedlebbbbbb…\n",
+		},
+		{
+			name:     "truncates the end of lines without matches",
+			opts:     []TextFormatterOption{WithMaxColumns(24)},
+			line:     2,
+			expected: "3 █ var s = \"aaaaaaaaaa…\n",
+		},
+		{
+			name:     "leaves short lines alone",
+			opts:     []TextFormatterOption{WithMaxColumns(24)},
+			line:     0,
+			expected: "1 █ package main\n",
+		},
+		{
+			name:     "omits lines over the byte limit",
+			opts:     []TextFormatterOption{WithMaxLineBytes(50)},
+			line:     2,
+			expected: "3 █ [line omitted: 96 bytes]\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lines := NewSetFromSlice([]lineNumber{tc.line})
+			output := NewTextFormatter(tc.opts...).Format(st.Lines(), lines, lines)
+
+			if !strings.Contains(output, tc.expected) {
+				t.Errorf("expected output to contain:\n%q\nbut got:\n%q", tc.expected, output)
+			}
+		})
+	}
+
+	t.Run("moves syntax highlighting with the text", func(t *testing.T) {
+		l := NewTextFormatter(WithTabWidth(4)).displayLine(st.Lines()[5], 5, 0)
+
+		if l.text != "    if true {" {
+			t.Fatalf("unexpected text %q", l.text)
+		}
+		for text, class := range l.segments() {
+			if class == tokenKeyword && text != "if" {
+				t.Errorf("expected the keyword to be \"if\", got %q", text)
+			}
+		}
+	})
+}
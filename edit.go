@@ -0,0 +1,198 @@
+package searchast
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// span is the extent of a top level node of a syntax tree.
+type span struct {
+	kind      string
+	startByte uint32
+	endByte   uint32
+	startRow  lineNumber
+	endRow    lineNumber
+	hasError  bool
+}
+
+// topLevelSpans returns the spans of the children of the root of tree.
+func topLevelSpans(tree *sitter.Tree) []span {
+	root := tree.RootNode()
+
+	spans := make([]span, root.ChildCount())
+	for i := range spans {
+		child := root.Child(i)
+		spans[i] = span{
+			kind:      child.Type(),
+			startByte: child.StartByte(),
+			endByte:   child.EndByte(),
+			startRow:  child.StartPoint().Row,
+			endRow:    child.EndPoint().Row,
+			hasError:  child.HasError(),
+		}
+	}
+
+	return spans
+}
+
+// pointAt returns the row and byte column of offset in source.
+func pointAt(source []byte, offset uint32) sitter.Point {
+	before := source[:offset]
+	row := bytes.Count(before, []byte("\n"))
+	column := len(before) - (bytes.LastIndexByte(before, '\n') + 1)
+
+	return sitter.Point{Row: uint32(row), Column: uint32(column)}
+}
+
+// Edit updates the tree after the bytes from startByte to oldEndByte of the
+// source were replaced with the ones from startByte to newEndByte of
+// newContent, which is the whole new source. The source is parsed again
+// incrementally, reusing the unchanged parts of the previous syntax tree, and
+// only the scopes and tokens of the lines of the top level nodes affected by
+// the edit are computed again. The others are kept, moved by the number of
+// added or removed lines.
+func (st *sourceTree) Edit(startByte uint32, oldEndByte uint32, newEndByte uint32, newContent []byte) error {
+	switch {
+	case st.lang == nil:
+		return errors.New("invalid edit: the tree was released")
+	case startByte > oldEndByte || int(oldEndByte) > len(st.source):
+		return fmt.Errorf("invalid edit: old range %d-%d is outside of the source", startByte, oldEndByte)
+	case startByte > newEndByte || int(newEndByte) > len(newContent):
+		return fmt.Errorf("invalid edit: new range %d-%d is outside of the new content", startByte, newEndByte)
+	case len(newContent)-int(newEndByte) != len(st.source)-int(oldEndByte):
+		return fmt.Errorf("invalid edit: the content after the edit has a different length")
+	}
+
+	parser := sitter.NewParser()
+	defer parser.Close()
+	parser.SetLanguage(st.lang)
+
+	if st.tree == nil {
+		// Trees read from a cache are parsed from scratch the first time.
+		tree, err := parser.ParseCtx(context.Background(), nil, newContent)
+		if err != nil {
+			return fmt.Errorf("failed to parse edited source: %w", err)
+		}
+
+		lang, langName := st.lang, st.langName
+		*st = *newSourceTree(newContent)
+		st.tree = tree
+		st.lang = lang
+		st.langName = langName
+		st.build(tree.RootNode(), 0, lineNumber(len(st.lines)-1))
+
+		return nil
+	}
+
+	startPoint := pointAt(st.source, startByte)
+	oldEndPoint := pointAt(st.source, oldEndByte)
+	newEndPoint := pointAt(newContent, newEndByte)
+
+	oldSpans := topLevelSpans(st.tree)
+	oldRootRow := st.tree.RootNode().StartPoint().Row
+	st.tree.Edit(sitter.EditInput{
+		StartIndex:  startByte,
+		OldEndIndex: oldEndByte,
+		NewEndIndex: newEndByte,
+		StartPoint:  startPoint,
+		OldEndPoint: oldEndPoint,
+		NewEndPoint: newEndPoint,
+	})
+
+	tree, err := parser.ParseCtx(context.Background(), st.tree, newContent)
+	if err != nil {
+		return fmt.Errorf("failed to parse edited source: %w", err)
+	}
+	newSpans := topLevelSpans(tree)
+
+	// The top level nodes before and after the edit that are unchanged, except
+	// for being moved by the edit, keep their lines. Error recovery may parse
+	// the same text differently, so nodes with errors are always changed.
+	byteDelta := int64(newEndByte) - int64(oldEndByte)
+	prefix := 0
+	for prefix < min(len(oldSpans), len(newSpans)) && oldSpans[prefix] == newSpans[prefix] && !newSpans[prefix].hasError && newSpans[prefix].endByte <= startByte {
+		prefix++
+	}
+	suffix := 0
+	for suffix < min(len(oldSpans), len(newSpans))-prefix {
+		o, n := oldSpans[len(oldSpans)-1-suffix], newSpans[len(newSpans)-1-suffix]
+		if o.kind != n.kind || o.hasError || n.hasError || o.startByte < oldEndByte || int64(o.startByte)+byteDelta != int64(n.startByte) || o.endByte-o.startByte != n.endByte-n.startByte {
+			break
+		}
+		suffix++
+	}
+
+	newLines := newSourceTree(newContent).lines
+	rowDelta := len(newLines) - len(st.lines)
+
+	// first and last are the new lines whose scopes and tokens change, which
+	// are the lines of the changed top level nodes of both trees.
+	first, last := startPoint.Row, newEndPoint.Row
+	if prefix < len(newSpans)-suffix {
+		first = min(first, newSpans[prefix].startRow)
+		last = max(last, newSpans[len(newSpans)-suffix-1].endRow)
+	}
+	if prefix < len(oldSpans)-suffix {
+		first = min(first, oldSpans[prefix].startRow)
+		last = lineNumber(max(int(last), int(oldSpans[len(oldSpans)-suffix-1].endRow)+rowDelta))
+	}
+
+	root := tree.RootNode()
+	newRootRow := root.StartPoint().Row
+
+	// Lines after the edit whose parent starts on one of the edited lines may
+	// belong to nodes before or after the edit, their scopes are computed again.
+	var uncertain []lineNumber
+	for i := range newLines {
+		switch {
+		case i < int(first):
+			newLines[i].scope = st.lines[i].scope
+			newLines[i].tokens = st.lines[i].tokens
+		case i > int(last):
+			old := st.lines[i-rowDelta]
+			newLines[i].scope = scope{
+				start:    lineNumber(int(old.scope.start) + rowDelta),
+				end:      lineNumber(int(old.scope.end) + rowDelta),
+				kind:     old.scope.kind,
+				kinds:    old.scope.kinds,
+				category: old.scope.category,
+			}
+			switch parent := old.scope.parent; {
+			case parent < startPoint.Row:
+				newLines[i].scope.parent = parent
+			case parent > oldEndPoint.Row:
+				newLines[i].scope.parent = lineNumber(int(parent) + rowDelta)
+			default:
+				uncertain = append(uncertain, lineNumber(i))
+			}
+			newLines[i].tokens = old.tokens
+		}
+	}
+
+	st.lines = newLines
+	st.source = newContent
+	st.tree = tree
+
+	st.build(root, first, last)
+	if st.highlighted {
+		st.highlight(root, first, last)
+	}
+
+	// The root spans every line, so the scope of the line it starts on
+	// changes with the number of lines.
+	if int(oldRootRow)+rowDelta > int(last) {
+		oldRootRow = lineNumber(int(oldRootRow) + rowDelta)
+	}
+	for _, row := range append(uncertain, oldRootRow, newRootRow) {
+		if (row < first || row > last) && int(row) < len(st.lines) {
+			st.lines[row].scope = scope{start: row, end: row}
+			st.build(root, row, row)
+		}
+	}
+
+	return nil
+}
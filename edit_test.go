@@ -0,0 +1,160 @@
+package searchast
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const editSource = `package main
+
+import "fmt"
+
+// greet prints a greeting.
+func greet(name string) {
+	if name == "" {
+		name = "world"
+	}
+	fmt.Println("hello", name)
+}
+
+type point struct {
+	x, y int
+}
+
+func main() {
+	greet("")
+}
+`
+
+// replace returns the arguments of Edit to replace old, which must be found
+// once in source, with new.
+func replace(t *testing.T, source string, old string, new string) (uint32, uint32, uint32, []byte) {
+	t.Helper()
+
+	if strings.Count(source, old) != 1 {
+		t.Fatalf("expected %q to be found once", old)
+	}
+
+	start := strings.Index(source, old)
+	newContent := source[:start] + new + source[start+len(old):]
+
+	return uint32(start), uint32(start + len(old)), uint32(start + len(new)), []byte(newContent)
+}
+
+// assertSameTree fails if st differs from a tree parsed from its source.
+func assertSameTree(t *testing.T, st *sourceTree) {
+	t.Helper()
+
+	lines, expected := st.Lines(), mustNewSourceTree(t, string(st.source)).Lines()
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %d", len(expected), len(lines))
+	}
+
+	for i := range expected {
+		if !reflect.DeepEqual(lines[i], expected[i]) {
+			t.Fatalf("line %d: expected %+v, got %+v\nsource:\n%s", i, expected[i], lines[i], st.source)
+		}
+	}
+}
+
+func TestSourceTree_Edit(t *testing.T) {
+	testCases := []struct {
+		name string
+		old  string
+		new  string
+	}{
+		{"edits a line", `"hello"`, `"hi"`},
+		{"adds lines to a scope", "\tfmt.Println", "\tname += \"!\"\n\tfmt.Println"},
+		{"removes a scope", "\tif name == \"\" {\n\t\tname = \"world\"\n\t}\n", ""},
+		{"adds a top level declaration", "type point", "const answer = 42\n\ntype point"},
+		{"joins declarations", "}\n\ntype point struct {", "\ntype point struct {"},
+		{"opens an unterminated comment", "// greet prints", "/* greet prints"},
+		{"edits the first line", "package main", "package main_test"},
+		{"removes the end of the file", "func main() {\n\tgreet(\"\")\n}\n", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			st := mustNewSourceTree(t, editSource)
+			st.Lines() // computes the tokens, which Edit then updates
+
+			if err := st.Edit(replace(t, editSource, tc.old, tc.new)); err != nil {
+				t.Fatalf("failed to edit: %v", err)
+			}
+
+			assertSameTree(t, st)
+		})
+	}
+
+	t.Run("applies successive random edits", func(t *testing.T) {
+		fragments := []string{"", "\n", "}", "{\n", "x := 1\n", "\"", "// note\n", "func f() {\n\treturn\n}\n", "if x {\n"}
+		random := rand.New(rand.NewPCG(1, 2))
+
+		st := mustNewSourceTree(t, editSource)
+		st.Lines()
+		for range 200 {
+			source := string(st.source)
+			start := random.IntN(len(source) + 1)
+			end := start + random.IntN(min(len(source)-start, 20)+1)
+			fragment := fragments[random.IntN(len(fragments))]
+
+			newContent := source[:start] + fragment + source[end:]
+			if err := st.Edit(uint32(start), uint32(end), uint32(start+len(fragment)), []byte(newContent)); err != nil {
+				t.Fatalf("failed to edit: %v", err)
+			}
+
+			assertSameTree(t, st)
+		}
+	})
+
+	t.Run("computes the tokens of trees edited before being highlighted", func(t *testing.T) {
+		st := mustNewSourceTree(t, editSource)
+
+		if err := st.Edit(replace(t, editSource, "x, y int", "x, y, z int")); err != nil {
+			t.Fatalf("failed to edit: %v", err)
+		}
+
+		assertSameTree(t, st)
+	})
+
+	t.Run("parses trees read from a cache", func(t *testing.T) {
+		pc := NewParseCache(t.TempDir())
+		for range 2 {
+			if _, err := pc.NewSourceTree(t.Context(), strings.NewReader(editSource), "test.go"); err != nil {
+				t.Fatalf("failed to create sourceTree: %v", err)
+			}
+		}
+		st, err := pc.NewSourceTree(t.Context(), strings.NewReader(editSource), "test.go")
+		if err != nil {
+			t.Fatalf("failed to create sourceTree: %v", err)
+		}
+
+		if err := st.Edit(replace(t, editSource, "x, y int", "x, y, z int")); err != nil {
+			t.Fatalf("failed to edit: %v", err)
+		}
+
+		assertSameTree(t, st)
+	})
+
+	t.Run("returns an error for invalid edits", func(t *testing.T) {
+		st := mustNewSourceTree(t, editSource)
+
+		if err := st.Edit(0, uint32(len(editSource)+1), 0, nil); err == nil {
+			t.Error("expected an error for a range outside of the source")
+		}
+		if err := st.Edit(0, 1, 2, []byte(editSource)); err == nil {
+			t.Error("expected an error for an inconsistent new content")
+		}
+	})
+
+	t.Run("returns an error for released trees", func(t *testing.T) {
+		st := mustNewSourceTree(t, editSource)
+		st.Release()
+
+		if err := st.Edit(replace(t, editSource, "x, y int", "x, y, z int")); err == nil {
+			t.Error("expected an error for a released tree")
+		}
+	})
+}
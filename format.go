@@ -2,16 +2,25 @@ package searchast
 
 import (
 	"fmt"
+	"io"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 const (
 	ansiCodeReset = "\033[0m"
 	ansiCodeRed   = "\033[31m"
+
+	ansiCodeDefaultForeground = "\033[39m"
+	ansiCodeBoldMagenta       = "\033[1;35m"
 )
 
+// Formatter renders the lines to show of a source file. Format returns the
+// whole output at once, while FormatTo streams it to a writer.
 type Formatter interface {
 	Format(lines []line, linesToShow Set[lineNumber], linesToHighlight Set[lineNumber]) string
+	FormatTo(w io.Writer, lines []line, linesToShow Set[lineNumber], linesToHighlight Set[lineNumber]) error
 }
 
 type TextFormatter struct {
@@ -21,6 +30,14 @@ type TextFormatter struct {
 	gapSymbol       string
 	spacer          string
 	enableColors    bool
+	theme           *Theme
+	colorDepth      ColorDepth
+	hyperlinkFormat string
+	hyperlinkPath   string
+	tabWidth        int
+	maxColumns      int
+	maxLineBytes    int
+	matchSpans      map[lineNumber][2]int
 }
 
 type TextFormatterOption func(*TextFormatter)
@@ -33,6 +50,8 @@ func NewTextFormatter(opts ...TextFormatterOption) *TextFormatter {
 		gapSymbol:       "⋮",
 		spacer:          " ",
 		enableColors:    false,
+		theme:           nil,
+		colorDepth:      Color256,
 	}
 
 	for _, opt := range opts {
@@ -78,6 +97,21 @@ func WithColors(enabled bool) TextFormatterOption {
 	}
 }
 
+// WithSyntaxHighlighting colors the lines using the given theme, based on the
+// tree-sitter parse of the source. A nil theme disables syntax highlighting.
+func WithSyntaxHighlighting(theme *Theme) TextFormatterOption {
+	return func(tf *TextFormatter) {
+		tf.theme = theme
+	}
+}
+
+// WithColorDepth sets the escape sequences used for syntax highlighting colors.
+func WithColorDepth(depth ColorDepth) TextFormatterOption {
+	return func(tf *TextFormatter) {
+		tf.colorDepth = depth
+	}
+}
+
 func maxLineNumber(linesToShow Set[lineNumber]) int {
 	// Calculate the width needed for line numbers
 	var maxLineNumber lineNumber
@@ -87,12 +121,23 @@ func maxLineNumber(linesToShow Set[lineNumber]) int {
 	return len(fmt.Sprintf("%d", maxLineNumber))
 }
 
+func (tf *TextFormatter) usesTokens() bool {
+	return tf.theme != nil
+}
+
 func (tf *TextFormatter) Format(lines []line, linesToShow Set[lineNumber], linesToHighlight Set[lineNumber]) string {
+	output := strings.Builder{}
+	_ = tf.FormatTo(&output, lines, linesToShow, linesToHighlight) // writing to a strings.Builder never fails
+
+	return output.String()
+}
+
+// FormatTo writes the formatted lines to w as they are produced.
+func (tf *TextFormatter) FormatTo(w io.Writer, lines []line, linesToShow Set[lineNumber], linesToHighlight Set[lineNumber]) error {
 	if len(linesToShow) == 0 || len(linesToHighlight) == 0 {
-		return ""
+		return nil
 	}
 
-	output := strings.Builder{}
 	isGapPrinted := false
 
 	lineNumberWidth := maxLineNumber(linesToShow)
@@ -105,7 +150,9 @@ func (tf *TextFormatter) Format(lines []line, linesToShow Set[lineNumber], lines
 				} else {
 					gapPrefix = tf.gapSymbol
 				}
-				output.WriteString(gapPrefix + "\n")
+				if _, err := io.WriteString(w, gapPrefix+"\n"); err != nil {
+					return err
+				}
 				isGapPrinted = true
 			}
 
@@ -121,21 +168,63 @@ func (tf *TextFormatter) Format(lines []line, linesToShow Set[lineNumber], lines
 		}
 
 		var prefix string
+		gutterWidth := utf8.RuneCountInString(symbol + tf.spacer)
 		if tf.lineNumbers {
-			prefix = fmt.Sprintf("%*d%s%s%s", lineNumberWidth, i+1, tf.spacer, symbol, tf.spacer)
+			gutterWidth += lineNumberWidth + utf8.RuneCountInString(tf.spacer)
+			number := tf.hyperlink(strconv.Itoa(i+1), tf.hyperlinkPath, i+1)
+			prefix = fmt.Sprintf("%*s%s%s%s%s", lineNumberWidth-len(strconv.Itoa(i+1)), "", number, tf.spacer, symbol, tf.spacer)
 		} else {
 			prefix = fmt.Sprintf("%s%s", symbol, tf.spacer)
 		}
 
+		line = tf.displayLine(line, lineNumber(i), gutterWidth)
+
 		var lineText string
-		if tf.enableColors && linesToHighlight.Has(lineNumber(i)) {
+		switch {
+		case tf.theme != nil:
+			lineText = tf.theme.highlightLine(line, linesToHighlight.Has(lineNumber(i)), tf.colorDepth)
+		case tf.enableColors && linesToHighlight.Has(lineNumber(i)):
 			lineText = ansiCodeRed + line.text + ansiCodeReset
-		} else {
+		default:
 			lineText = line.text
 		}
 
-		output.WriteString(fmt.Sprintf("%s%s\n", prefix, lineText))
+		if _, err := fmt.Fprintf(w, "%s%s\n", prefix, lineText); err != nil {
+			return err
+		}
 	}
 
-	return output.String()
+	return nil
+}
+
+// WriteHeader writes the header printed before the lines of a file when
+// several files are searched: its path, followed by the language and the
+// number of matching lines when they are known. The path is styled when
+// colors are enabled, and links to the first line of the file when hyperlinks
+// are enabled.
+func (tf *TextFormatter) WriteHeader(w io.Writer, filename string, language string, matchingLines int) error {
+	path := filename
+	if tf.enableColors {
+		path = ansiCodeBoldMagenta + filename + ansiCodeReset
+	}
+	path = tf.hyperlink(path, absolutePath(filename), 1)
+
+	var details []string
+	if language != "" {
+		details = append(details, language)
+	}
+	switch {
+	case matchingLines == 1:
+		details = append(details, "1 matching line")
+	case matchingLines > 1:
+		details = append(details, fmt.Sprintf("%d matching lines", matchingLines))
+	}
+
+	if len(details) == 0 {
+		_, err := fmt.Fprintln(w, path)
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "%s (%s)\n", path, strings.Join(details, ", "))
+	return err
 }
@@ -1,6 +1,7 @@
 package searchast
 
 import (
+	"errors"
 	"strings"
 	"testing"
 )
@@ -83,3 +84,71 @@ func test() {
 		}
 	})
 }
+
+type failingWriter struct{}
+
+func (fw *failingWriter) Write(p []byte) (n int, err error) {
+	return 0, errors.New("write failed")
+}
+
+func TestTextFormatter_FormatTo(t *testing.T) {
+	source := `package main
+
+func main() {
+	fmt.Println("hello")
+}`
+
+	st := mustNewSourceTree(t, source)
+	linesOfInterest, err := st.Search("Println")
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	linesToShow := NewContextBuilder().AddContext(st, linesOfInterest)
+	formatter := NewTextFormatter()
+
+	t.Run("writes the same output as Format", func(t *testing.T) {
+		var output strings.Builder
+		if err := formatter.FormatTo(&output, st.Lines(), linesToShow, linesOfInterest); err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+
+		expected := formatter.Format(st.Lines(), linesToShow, linesOfInterest)
+		if output.String() != expected {
+			t.Errorf("expected output:\n%s\ngot:\n%s", expected, output.String())
+		}
+	})
+
+	t.Run("returns write errors", func(t *testing.T) {
+		if err := formatter.FormatTo(&failingWriter{}, st.Lines(), linesToShow, linesOfInterest); err == nil {
+			t.Fatal("expected an error for a failing writer, but got none")
+		}
+	})
+}
+
+func TestTextFormatter_WriteHeader(t *testing.T) {
+	testCases := []struct {
+		name          string
+		formatter     *TextFormatter
+		language      string
+		matchingLines int
+		expected      string
+	}{
+		{"prints the language and matching lines", NewTextFormatter(), "go", 3, "cmd/main.go (go, 3 matching lines)\n"},
+		{"uses the singular for one line", NewTextFormatter(), "go", 1, "cmd/main.go (go, 1 matching line)\n"},
+		{"omits unknown details", NewTextFormatter(), "", 0, "cmd/main.go\n"},
+		{"styles the path with colors", NewTextFormatter(WithColors(true)), "go", 2, "\033[1;35mcmd/main.go\033[0m (go, 2 matching lines)\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output := strings.Builder{}
+			if err := tc.formatter.WriteHeader(&output, "cmd/main.go", tc.language, tc.matchingLines); err != nil {
+				t.Fatalf("failed to write header: %v", err)
+			}
+
+			if output.String() != tc.expected {
+				t.Errorf("expected %q, but got %q", tc.expected, output.String())
+			}
+		})
+	}
+}
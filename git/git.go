@@ -0,0 +1,178 @@
+// Package git reads revisions of files from the git repository of the current
+// directory by running the local git binary.
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/andersonjoseph/searchast/diff"
+)
+
+// run runs git with the given arguments in the current directory and returns
+// its output. Errors include what git printed to stderr.
+func run(ctx context.Context, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return output, nil
+}
+
+// Diff runs git diff for the given revision, or revision range such as
+// "main...HEAD", and parses its output. Paths are relative to the current
+// directory and only changes below it are reported.
+func Diff(ctx context.Context, rev string, paths ...string) ([]diff.File, error) {
+	args := []string{"diff", "--no-color", "--no-ext-diff", "--relative", "-U0", rev, "--"}
+	args = append(args, paths...)
+
+	output, err := run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return diff.Parse(bytes.NewReader(output))
+}
+
+// Show returns the content of a file at the given revision. The path is
+// relative to the current directory.
+func Show(ctx context.Context, rev string, path string) ([]byte, error) {
+	return run(ctx, "show", rev+":./"+path)
+}
+
+// Range splits a revision range as accepted by git diff into the old and new
+// revisions. "A..B" compares A with B and "A...B" compares the merge base of A
+// and B with B, an omitted side being HEAD. A single revision compares it with
+// the working tree, which is reported as an empty new revision.
+func Range(ctx context.Context, rev string) (string, string, error) {
+	if oldRev, newRev, found := strings.Cut(rev, "..."); found {
+		oldRev, newRev = orHead(oldRev), orHead(newRev)
+
+		output, err := run(ctx, "merge-base", oldRev, newRev)
+		if err != nil {
+			return "", "", err
+		}
+		return strings.TrimSpace(string(output)), newRev, nil
+	}
+
+	if oldRev, newRev, found := strings.Cut(rev, ".."); found {
+		return orHead(oldRev), orHead(newRev), nil
+	}
+
+	return rev, "", nil
+}
+
+func orHead(rev string) string {
+	if rev == "" {
+		return "HEAD"
+	}
+	return rev
+}
+
+// TreeFile is a file of the tree of a commit.
+type TreeFile struct {
+	Path   string
+	Object string
+}
+
+// Tree lists the files of the tree of rev with git ls-tree, recursively.
+// Paths are relative to the current directory and only files below it, or
+// below the given paths, are listed. Submodules and symbolic links are skipped.
+func Tree(ctx context.Context, rev string, paths ...string) ([]TreeFile, error) {
+	args := []string{"ls-tree", "-r", "-z", rev, "--"}
+	args = append(args, paths...)
+
+	output, err := run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []TreeFile
+	for entry := range strings.SplitSeq(strings.TrimSuffix(string(output), "\x00"), "\x00") {
+		if entry == "" {
+			continue
+		}
+
+		// <mode> SP <type> SP <object> TAB <path>
+		info, path, found := strings.Cut(entry, "\t")
+		fields := strings.Fields(info)
+		if !found || len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected git ls-tree output %q", entry)
+		}
+		if fields[1] != "blob" || fields[0] == "120000" {
+			continue
+		}
+
+		files = append(files, TreeFile{Path: path, Object: fields[2]})
+	}
+
+	return files, nil
+}
+
+// CatFile returns the content of a blob object.
+func CatFile(ctx context.Context, object string) ([]byte, error) {
+	return run(ctx, "cat-file", "blob", object)
+}
+
+// Commit is a commit listed by Log.
+type Commit struct {
+	Hash    string
+	Author  string
+	Date    string
+	Subject string
+}
+
+// Short returns the abbreviated hash of the commit.
+func (c Commit) Short() string {
+	if len(c.Hash) > 7 {
+		return c.Hash[:7]
+	}
+	return c.Hash
+}
+
+// Log lists the commits reachable from rev, or in a range such as
+// "main..HEAD", that change the given paths, newest first. Merge commits are
+// skipped as their changes are reported by the merged commits.
+func Log(ctx context.Context, rev string, paths ...string) ([]Commit, error) {
+	args := []string{"log", "--no-merges", "--no-color", "--date=short", "--format=%H%x00%an%x00%ad%x00%s", rev, "--"}
+	args = append(args, paths...)
+
+	output, err := run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []Commit
+	for line := range strings.Lines(string(output)) {
+		fields := strings.Split(strings.TrimSuffix(line, "\n"), "\x00")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected git log output %q", line)
+		}
+
+		commits = append(commits, Commit{Hash: fields[0], Author: fields[1], Date: fields[2], Subject: fields[3]})
+	}
+
+	return commits, nil
+}
+
+// Changes returns the changes of a commit compared with its parent, like
+// Diff, and all of its files as created for a root commit.
+func Changes(ctx context.Context, commit string, paths ...string) ([]diff.File, error) {
+	args := []string{"show", "--format=", "--no-color", "--no-ext-diff", "--relative", "-U0", commit, "--"}
+	args = append(args, paths...)
+
+	output, err := run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return diff.Parse(bytes.NewReader(output))
+}
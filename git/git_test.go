@@ -0,0 +1,197 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+// newRepository creates a repository in a temporary directory, which becomes
+// the current directory, with a commit of main.go for each content.
+func newRepository(t *testing.T, contents ...string) {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Chdir(t.TempDir())
+
+	mustGit(t, "init", "-q")
+	for _, content := range contents {
+		writeFile(t, "main.go", content)
+		mustGit(t, "add", ".")
+		mustGit(t, "commit", "-q", "-m", "update main.go")
+	}
+}
+
+func mustGit(t *testing.T, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, output)
+	}
+
+	return string(output)
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	newRepository(t, "package main\n\nfunc main() {\n}\n")
+	writeFile(t, "main.go", "package main\n\nfunc main() {\n\tprintln()\n}\n")
+
+	files, err := Diff(t.Context(), "HEAD")
+	if err != nil {
+		t.Fatalf("failed to diff: %v", err)
+	}
+
+	if len(files) != 1 || files[0].NewPath != "main.go" || !reflect.DeepEqual(files[0].AddedLines(), []int{4}) {
+		t.Errorf("unexpected diff %+v", files)
+	}
+
+	if _, err := Diff(t.Context(), "missing-revision"); err == nil {
+		t.Fatal("expected an error for an unknown revision")
+	}
+}
+
+func TestShow(t *testing.T) {
+	newRepository(t, "first\n", "second\n")
+
+	content, err := Show(t.Context(), "HEAD~1", "main.go")
+	if err != nil {
+		t.Fatalf("failed to show: %v", err)
+	}
+	if string(content) != "first\n" {
+		t.Errorf("expected the first version, got %q", content)
+	}
+}
+
+func TestRange(t *testing.T) {
+	newRepository(t, "first\n", "second\n")
+	base := mustGit(t, "rev-parse", "HEAD~1")
+
+	testCases := []struct {
+		rev         string
+		expectedOld string
+		expectedNew string
+	}{
+		{"HEAD~1", "HEAD~1", ""},
+		{"HEAD~1..", "HEAD~1", "HEAD"},
+		{"HEAD~1..HEAD", "HEAD~1", "HEAD"},
+		{"HEAD~1...HEAD", base[:len(base)-1], "HEAD"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.rev, func(t *testing.T) {
+			oldRev, newRev, err := Range(t.Context(), tc.rev)
+			if err != nil {
+				t.Fatalf("failed to resolve range: %v", err)
+			}
+
+			if oldRev != tc.expectedOld || newRev != tc.expectedNew {
+				t.Errorf("expected %q and %q, got %q and %q", tc.expectedOld, tc.expectedNew, oldRev, newRev)
+			}
+		})
+	}
+}
+
+func TestTree(t *testing.T) {
+	newRepository(t, "first\n")
+	if err := os.Mkdir("pkg", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, "pkg/util.go", "package pkg\n")
+	mustGit(t, "add", ".")
+	mustGit(t, "commit", "-q", "-m", "add pkg")
+	writeFile(t, "pkg/util.go", "package changed\n")
+
+	files, err := Tree(t.Context(), "HEAD")
+	if err != nil {
+		t.Fatalf("failed to list tree: %v", err)
+	}
+
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	if !reflect.DeepEqual(paths, []string{"main.go", "pkg/util.go"}) {
+		t.Fatalf("unexpected files %v", paths)
+	}
+
+	content, err := CatFile(t.Context(), files[1].Object)
+	if err != nil {
+		t.Fatalf("failed to read blob: %v", err)
+	}
+	if string(content) != "package pkg\n" {
+		t.Errorf("expected the committed version, got %q", content)
+	}
+
+	t.Chdir("pkg")
+	files, err = Tree(t.Context(), "HEAD")
+	if err != nil {
+		t.Fatalf("failed to list tree: %v", err)
+	}
+	if len(files) != 1 || files[0].Path != "util.go" {
+		t.Errorf("expected paths relative to the current directory, got %+v", files)
+	}
+}
+
+func TestLog(t *testing.T) {
+	newRepository(t, "first\n", "second\n")
+
+	commits, err := Log(t.Context(), "HEAD")
+	if err != nil {
+		t.Fatalf("failed to list commits: %v", err)
+	}
+
+	if len(commits) != 2 {
+		t.Fatalf("expected 2 commits, got %+v", commits)
+	}
+	head := mustGit(t, "rev-parse", "HEAD")
+	if commits[0].Hash != head[:len(head)-1] || commits[0].Author != "test" || commits[0].Subject != "update main.go" {
+		t.Errorf("unexpected commit %+v", commits[0])
+	}
+	if len(commits[0].Short()) != 7 {
+		t.Errorf("expected a 7 characters short hash, got %q", commits[0].Short())
+	}
+}
+
+func TestChanges(t *testing.T) {
+	newRepository(t, "first\n", "second\n")
+
+	commits, err := Log(t.Context(), "HEAD")
+	if err != nil {
+		t.Fatalf("failed to list commits: %v", err)
+	}
+
+	testCases := []struct {
+		name            string
+		commit          string
+		expectedOldPath string
+	}{
+		{"changed file", commits[0].Hash, "main.go"},
+		{"root commit", commits[1].Hash, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			files, err := Changes(t.Context(), tc.commit)
+			if err != nil {
+				t.Fatalf("failed to read changes: %v", err)
+			}
+
+			if len(files) != 1 || files[0].OldPath != tc.expectedOldPath || files[0].NewPath != "main.go" || !reflect.DeepEqual(files[0].AddedLines(), []int{1}) {
+				t.Errorf("unexpected changes %+v", files)
+			}
+		})
+	}
+}
@@ -45,3 +45,5 @@ require (
 	github.com/alexaandru/go-sitter-forest/zig v1.9.4
 	github.com/smacker/go-tree-sitter v0.0.0-20240827094217-dd81d9e9be82
 )
+
+require github.com/BurntSushi/toml v1.6.0
@@ -0,0 +1,93 @@
+package searchast
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// GrepFormatter prints the highlighted lines as "path:line:col: text", the
+// format understood by Vim's quickfix list, Emacs compilation-mode and most
+// editor problem matchers. Lines and columns are 1-based, columns count bytes.
+type GrepFormatter struct {
+	filename   string
+	matchers   []*matcher
+	everyMatch bool
+}
+
+// NewGrepFormatter creates a formatter printing one line per highlighted line,
+// with the column of its first match. The patterns are only used to find the
+// columns and are compiled with the given search options, which should be the
+// ones used to search the file.
+func NewGrepFormatter(filename string, patterns []string, opts ...SearchOption) (*GrepFormatter, error) {
+	matchers, err := newMatchers(patterns, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GrepFormatter{
+		filename: filename,
+		matchers: matchers,
+	}, nil
+}
+
+// NewVimgrepFormatter creates a formatter like NewGrepFormatter, but printing
+// one line per match, so a line with several matches is printed several times.
+func NewVimgrepFormatter(filename string, patterns []string, opts ...SearchOption) (*GrepFormatter, error) {
+	formatter, err := NewGrepFormatter(filename, patterns, opts...)
+	if err != nil {
+		return nil, err
+	}
+	formatter.everyMatch = true
+
+	return formatter, nil
+}
+
+func (gf *GrepFormatter) Format(lines []line, linesToShow Set[lineNumber], linesToHighlight Set[lineNumber]) string {
+	output := strings.Builder{}
+	_ = gf.FormatTo(&output, lines, linesToShow, linesToHighlight) // writing to a strings.Builder never fails
+
+	return output.String()
+}
+
+// FormatTo writes a line to w for every highlighted line, or every match on
+// them. Highlighted lines without a match, e.g. the header of a scope reported
+// by an inverted search, are printed with column 1. linesToShow is ignored.
+func (gf *GrepFormatter) FormatTo(w io.Writer, lines []line, linesToShow Set[lineNumber], linesToHighlight Set[lineNumber]) error {
+	columns := make(map[lineNumber][]int, len(linesToHighlight))
+	var matches []Match
+	for _, m := range gf.matchers {
+		matches = append(matches, findMatches(lines, m)...)
+	}
+	sortMatches(matches)
+
+	for _, match := range matches {
+		if !linesToHighlight.Has(match.StartLine) {
+			continue
+		}
+		if !gf.everyMatch && len(columns[match.StartLine]) > 0 || slices.Contains(columns[match.StartLine], match.StartColumn) {
+			continue
+		}
+		columns[match.StartLine] = append(columns[match.StartLine], match.StartColumn)
+	}
+
+	for i, line := range lines {
+		if !linesToHighlight.Has(lineNumber(i)) {
+			continue
+		}
+
+		lineColumns, exists := columns[lineNumber(i)]
+		if !exists {
+			lineColumns = []int{0}
+		}
+
+		for _, column := range lineColumns {
+			if _, err := fmt.Fprintf(w, "%s:%d:%d: %s\n", gf.filename, i+1, column+1, line.text); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
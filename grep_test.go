@@ -0,0 +1,64 @@
+package searchast
+
+import (
+	"testing"
+)
+
+func TestGrepFormatter_Format(t *testing.T) {
+	source := `package main
+
+func main() {
+	fmt.Println("a"); fmt.Println("b")
+	log.Println("c")
+}`
+
+	st := mustNewSourceTree(t, source)
+	linesToShow := NewSetFromSlice([]lineNumber{2, 3, 4, 5})
+
+	testCases := []struct {
+		name             string
+		newFormatter     func(string, []string, ...SearchOption) (*GrepFormatter, error)
+		patterns         []string
+		linesToHighlight Set[lineNumber]
+		expected         string
+	}{
+		{
+			name:             "prints each highlighted line with its first match",
+			newFormatter:     NewGrepFormatter,
+			patterns:         []string{`Println`},
+			linesToHighlight: NewSetFromSlice([]lineNumber{3, 4}),
+			expected:         "cmd/main.go:4:6: \tfmt.Println(\"a\"); fmt.Println(\"b\")\n" + "cmd/main.go:5:6: \tlog.Println(\"c\")\n",
+		},
+		{
+			name:             "prints each match in vimgrep mode",
+			newFormatter:     NewVimgrepFormatter,
+			patterns:         []string{`fmt`, `Println`},
+			linesToHighlight: NewSetFromSlice([]lineNumber{3}),
+			expected: "cmd/main.go:4:2: \tfmt.Println(\"a\"); fmt.Println(\"b\")\n" +
+				"cmd/main.go:4:6: \tfmt.Println(\"a\"); fmt.Println(\"b\")\n" +
+				"cmd/main.go:4:20: \tfmt.Println(\"a\"); fmt.Println(\"b\")\n" +
+				"cmd/main.go:4:24: \tfmt.Println(\"a\"); fmt.Println(\"b\")\n",
+		},
+		{
+			name:             "prints highlighted lines without matches at column 1",
+			newFormatter:     NewVimgrepFormatter,
+			patterns:         []string{`Println`},
+			linesToHighlight: NewSetFromSlice([]lineNumber{2}),
+			expected:         "cmd/main.go:3:1: func main() {\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			formatter, err := tc.newFormatter("cmd/main.go", tc.patterns)
+			if err != nil {
+				t.Fatalf("failed to create formatter: %v", err)
+			}
+
+			output := formatter.Format(st.Lines(), linesToShow, tc.linesToHighlight)
+			if output != tc.expected {
+				t.Errorf("expected output:\n%q\nbut got:\n%q", tc.expected, output)
+			}
+		})
+	}
+}
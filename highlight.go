@@ -0,0 +1,170 @@
+package searchast
+
+import (
+	"iter"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Token classes used for syntax highlighting. They are derived from the
+// tree-sitter node types, so they work for every supported language.
+const (
+	tokenKeyword    = "keyword"
+	tokenString     = "string"
+	tokenComment    = "comment"
+	tokenNumber     = "number"
+	tokenConstant   = "constant"
+	tokenType       = "type"
+	tokenFunction   = "function"
+	tokenIdentifier = "identifier"
+)
+
+// token is a highlighted part of a line, delimited by byte columns.
+type token struct {
+	start int
+	end   int
+	class string
+}
+
+// highlight recursively traverses the tree-sitter abstract syntax tree (AST)
+// to populate the highlighted tokens of the lines from first to last.
+func (st *sourceTree) highlight(node *sitter.Node, first lineNumber, last lineNumber) {
+	if node.EndPoint().Row < first || node.StartPoint().Row > last {
+		return
+	}
+
+	if class := tokenClassOf(st.langName, node); class != "" {
+		st.addToken(node.StartPoint(), node.EndPoint(), class, first, last)
+		return
+	}
+
+	for i := range int(node.ChildCount()) {
+		st.highlight(node.Child(i), first, last)
+	}
+}
+
+// addToken adds a token to every line it spans from first to last.
+func (st *sourceTree) addToken(start sitter.Point, end sitter.Point, class string, first lineNumber, last lineNumber) {
+	for row := max(start.Row, first); row <= min(end.Row, last) && int(row) < len(st.lines); row++ {
+		startColumn := 0
+		if row == start.Row {
+			startColumn = int(start.Column)
+		}
+
+		endColumn := len(st.lines[row].text)
+		if row == end.Row {
+			endColumn = min(int(end.Column), endColumn)
+		}
+
+		if endColumn > startColumn {
+			st.lines[row].tokens = append(st.lines[row].tokens, token{start: startColumn, end: endColumn, class: class})
+		}
+	}
+}
+
+// tokenClassOf returns the highlight class of a node, or an empty string if
+// the node isn't highlighted as a whole. Comments and strings are highlighted
+// as a whole, any other class only applies to leaf nodes.
+func tokenClassOf(lang string, node *sitter.Node) string {
+	nodeType := node.Type()
+
+	switch {
+	case strings.Contains(nodeType, "comment"):
+		return tokenComment
+	case strings.Contains(nodeType, "string"), strings.Contains(nodeType, "char"):
+		return tokenString
+	}
+
+	if node.ChildCount() > 0 {
+		return ""
+	}
+
+	switch {
+	case !node.IsNamed():
+		if isWord(nodeType) {
+			return tokenKeyword
+		}
+		return ""
+	case isNumberType(nodeType):
+		return tokenNumber
+	case nodeType == "true", nodeType == "false", nodeType == "nil", nodeType == "null",
+		nodeType == "none", nodeType == "undefined":
+		return tokenConstant
+	case strings.Contains(nodeType, "type_identifier"), strings.HasSuffix(nodeType, "primitive_type"),
+		strings.HasSuffix(nodeType, "predefined_type"), strings.HasSuffix(nodeType, "builtin_type"):
+		return tokenType
+	case strings.Contains(nodeType, "identifier"):
+		if parent := node.Parent(); parent != nil && isFunctionName(lang, parent.Type()) {
+			return tokenFunction
+		}
+		return tokenIdentifier
+	default:
+		return ""
+	}
+}
+
+func isWord(s string) bool {
+	for _, r := range s {
+		if !(r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')) {
+			return false
+		}
+	}
+
+	return s != ""
+}
+
+func isNumberType(nodeType string) bool {
+	switch nodeType {
+	case "number", "integer", "float", "decimal":
+		return true
+	}
+
+	if !strings.HasSuffix(nodeType, "_literal") {
+		return false
+	}
+	for _, kind := range []string{"int", "float", "imaginary", "number", "decimal"} {
+		if strings.Contains(nodeType, kind) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isFunctionName reports whether identifiers directly below a node of the
+// given type name a function, either in its declaration or in a call.
+func isFunctionName(lang string, parentType string) bool {
+	switch scopeCategories[lang][parentType] {
+	case ScopeKindFunction, ScopeKindMethod:
+		return true
+	}
+
+	return strings.HasPrefix(parentType, "call")
+}
+
+// segments returns an iterator over the text of the line split into
+// highlighted and plain parts. It yields each part with its token class,
+// which is empty for plain text.
+func (l line) segments() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		pos := 0
+		for _, tok := range l.tokens {
+			if tok.start < pos || tok.end > len(l.text) {
+				continue
+			}
+
+			if tok.start > pos && !yield(l.text[pos:tok.start], "") {
+				return
+			}
+			if !yield(l.text[tok.start:tok.end], tok.class) {
+				return
+			}
+			pos = tok.end
+		}
+
+		if pos < len(l.text) {
+			yield(l.text[pos:], "")
+		}
+	}
+}
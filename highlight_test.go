@@ -0,0 +1,98 @@
+package searchast
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLineSegments(t *testing.T) {
+	const source = "package main\n\nfunc main() {\n\tif x := 1; true { // done\n\t\tfmt.Println(`a\nb`)\n\t}\n}\n"
+	st := mustNewSourceTree(t, source)
+
+	type segment struct {
+		text  string
+		class string
+	}
+	collect := func(l line) []segment {
+		var segments []segment
+		for text, class := range l.segments() {
+			segments = append(segments, segment{text, class})
+		}
+		return segments
+	}
+
+	testCases := []struct {
+		name     string
+		line     lineNumber
+		expected []segment
+	}{
+		{
+			name: "highlights keywords and function names",
+			line: 2,
+			expected: []segment{
+				{"func", tokenKeyword}, {" ", ""}, {"main", tokenFunction}, {"() {", ""},
+			},
+		},
+		{
+			name: "highlights identifiers, numbers, constants and comments",
+			line: 3,
+			expected: []segment{
+				{"\t", ""}, {"if", tokenKeyword}, {" ", ""}, {"x", tokenIdentifier}, {" := ", ""},
+				{"1", tokenNumber}, {"; ", ""}, {"true", tokenConstant}, {" { ", ""}, {"// done", tokenComment},
+			},
+		},
+		{
+			name: "highlights strings spanning several lines",
+			line: 5,
+			expected: []segment{
+				{"b`", tokenString}, {")", ""},
+			},
+		},
+		{
+			name:     "yields nothing for empty lines",
+			line:     1,
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			segments := collect(st.Lines()[tc.line])
+			if !reflect.DeepEqual(segments, tc.expected) {
+				t.Errorf("expected segments %q, but got %q", tc.expected, segments)
+			}
+		})
+	}
+}
+
+func TestSourceTree_LinesFor(t *testing.T) {
+	hasTokens := func(lines []line) bool {
+		for _, l := range lines {
+			if len(l.tokens) > 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	testCases := []struct {
+		name      string
+		formatter Formatter
+		expected  bool
+	}{
+		{"skips tokens for plain text", NewTextFormatter(WithColors(true)), false},
+		{"skips tokens for markdown", NewMarkdownFormatter("main.go", "go"), false},
+		{"computes tokens for syntax highlighting", NewTextFormatter(WithSyntaxHighlighting(themes["monokai"])), true},
+		{"computes tokens for html", NewHTMLFormatter("main.go"), true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			st := mustNewSourceTree(t, "package main\n\nfunc main() {}\n")
+
+			if got := hasTokens(st.LinesFor(tc.formatter)); got != tc.expected {
+				t.Errorf("expected tokens to be computed to be %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
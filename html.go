@@ -0,0 +1,208 @@
+package searchast
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// htmlStyle is the stylesheet embedded in the pages written by HTMLFormatter.
+const htmlStyle = `body { font-family: sans-serif; margin: 2em; color: #24292f; }
+.file { margin-bottom: 2em; }
+.file h2 { font-size: 1em; font-family: monospace; }
+.file h2 a { color: inherit; text-decoration: none; }
+.code { font-family: monospace; font-size: 13px; border: 1px solid #d0d7de; border-radius: 6px; padding: 0.5em 0; }
+.line { display: block; white-space: pre; padding: 0 1em; }
+.line.match { background: #fff8c5; }
+.line:target { background: #ddf4ff; }
+.ln { display: inline-block; min-width: 4ch; margin-right: 1em; text-align: right; color: #8c959f; text-decoration: none; user-select: none; }
+.gap summary { cursor: pointer; color: #8c959f; padding: 0 1em; list-style: none; }
+.gap[open] summary { border-bottom: 1px dashed #d0d7de; }
+.tok-keyword { color: #cf222e; }
+.tok-string { color: #0a3069; }
+.tok-comment { color: #6e7781; font-style: italic; }
+.tok-number, .tok-constant { color: #0550ae; }
+.tok-type { color: #953800; }
+.tok-function { color: #8250df; }
+`
+
+// HTMLFormatter renders the lines to show as an HTML page with syntax
+// highlighting, highlighted matches, per-line anchors and collapsible gaps
+// that expand to the hidden lines.
+type HTMLFormatter struct {
+	filename    string
+	lineNumbers bool
+	fragment    bool
+}
+
+type HTMLFormatterOption func(*HTMLFormatter)
+
+// NewHTMLFormatter creates a formatter for the given file.
+func NewHTMLFormatter(filename string, opts ...HTMLFormatterOption) *HTMLFormatter {
+	formatter := &HTMLFormatter{
+		filename:    filename,
+		lineNumbers: true,
+		fragment:    false,
+	}
+
+	for _, opt := range opts {
+		opt(formatter)
+	}
+
+	return formatter
+}
+
+func WithHTMLLineNumbers(lineNumbers bool) HTMLFormatterOption {
+	return func(hf *HTMLFormatter) {
+		hf.lineNumbers = lineNumbers
+	}
+}
+
+// WithHTMLFragment only writes the section of the file, without the enclosing
+// page. It's used to combine several files with WriteHTMLHeader and WriteHTMLFooter.
+func WithHTMLFragment(enabled bool) HTMLFormatterOption {
+	return func(hf *HTMLFormatter) {
+		hf.fragment = enabled
+	}
+}
+
+// WriteHTMLHeader writes the beginning of an HTML page, including the stylesheet.
+func WriteHTMLHeader(w io.Writer, title string) error {
+	_, err := fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s</style>\n</head>\n<body>\n",
+		html.EscapeString(title), htmlStyle)
+	return err
+}
+
+// WriteHTMLFooter writes the end of an HTML page started with WriteHTMLHeader.
+func WriteHTMLFooter(w io.Writer) error {
+	_, err := io.WriteString(w, "</body>\n</html>\n")
+	return err
+}
+
+func (hf *HTMLFormatter) usesTokens() bool {
+	return true
+}
+
+func (hf *HTMLFormatter) Format(lines []line, linesToShow Set[lineNumber], linesToHighlight Set[lineNumber]) string {
+	output := strings.Builder{}
+	_ = hf.FormatTo(&output, lines, linesToShow, linesToHighlight) // writing to a strings.Builder never fails
+
+	return output.String()
+}
+
+// FormatTo writes the HTML page, or only the file section if the formatter is
+// a fragment, to w as it is produced.
+func (hf *HTMLFormatter) FormatTo(w io.Writer, lines []line, linesToShow Set[lineNumber], linesToHighlight Set[lineNumber]) error {
+	if len(linesToShow) == 0 || len(linesToHighlight) == 0 {
+		return nil
+	}
+
+	if !hf.fragment {
+		if err := WriteHTMLHeader(w, hf.filename); err != nil {
+			return err
+		}
+	}
+
+	id := htmlID(hf.filename)
+	if _, err := fmt.Fprintf(w, "<section class=\"file\" id=\"%s\">\n<h2><a href=\"#%s\">%s</a></h2>\n<div class=\"code\">\n",
+		id, id, html.EscapeString(hf.filename)); err != nil {
+		return err
+	}
+
+	// Hidden lines are grouped in collapsed blocks that can be expanded.
+	var hidden []int
+	flushHidden := func() error {
+		if len(hidden) == 0 {
+			return nil
+		}
+
+		noun := "lines"
+		if len(hidden) == 1 {
+			noun = "line"
+		}
+		if _, err := fmt.Fprintf(w, "<details class=\"gap\"><summary>⋮ %d hidden %s</summary>\n", len(hidden), noun); err != nil {
+			return err
+		}
+		for _, i := range hidden {
+			if err := hf.writeLine(w, id, i, lines[i], false); err != nil {
+				return err
+			}
+		}
+		hidden = hidden[:0]
+
+		_, err := io.WriteString(w, "</details>\n")
+		return err
+	}
+
+	for i, line := range lines {
+		if !linesToShow.Has(lineNumber(i)) {
+			hidden = append(hidden, i)
+			continue
+		}
+
+		if err := flushHidden(); err != nil {
+			return err
+		}
+		if err := hf.writeLine(w, id, i, line, linesToHighlight.Has(lineNumber(i))); err != nil {
+			return err
+		}
+	}
+	if err := flushHidden(); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, "</div>\n</section>\n"); err != nil {
+		return err
+	}
+
+	if !hf.fragment {
+		return WriteHTMLFooter(w)
+	}
+
+	return nil
+}
+
+// writeLine writes a single line with its anchor and syntax highlighting.
+func (hf *HTMLFormatter) writeLine(w io.Writer, id string, i int, l line, isMatch bool) error {
+	class := "line"
+	if isMatch {
+		class = "line match"
+	}
+
+	anchor := fmt.Sprintf("%s-L%d", id, i+1)
+	output := strings.Builder{}
+	fmt.Fprintf(&output, "<span class=\"%s\" id=\"%s\">", class, anchor)
+	if hf.lineNumbers {
+		fmt.Fprintf(&output, "<a class=\"ln\" href=\"#%s\">%d</a>", anchor, i+1)
+	}
+
+	for text, tokenClass := range l.segments() {
+		if tokenClass == "" {
+			output.WriteString(html.EscapeString(text))
+			continue
+		}
+		fmt.Fprintf(&output, "<span class=\"tok-%s\">%s</span>", tokenClass, html.EscapeString(text))
+	}
+	output.WriteString("</span>\n")
+
+	_, err := io.WriteString(w, output.String())
+	return err
+}
+
+// htmlID turns a file name into a value usable as an HTML id and URL fragment.
+// Names differing only in the characters replaced by dashes, such as a/b.go
+// and a-b.go, are told apart by a short hash of the name.
+func htmlID(filename string) string {
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			return r
+		}
+		return '-'
+	}, filename)
+	hash := sha256.Sum256([]byte(filename))
+
+	return name + "-" + hex.EncodeToString(hash[:4])
+}
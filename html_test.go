@@ -0,0 +1,60 @@
+package searchast
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTMLFormatter_Format(t *testing.T) {
+	source := `package main
+
+func main() {
+	fmt.Println("<hello>")
+}`
+
+	st := mustNewSourceTree(t, source)
+	linesOfInterest, err := st.Search("Println")
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	linesToShow := NewContextBuilder(WithSurroundingLines(0)).AddContext(st, linesOfInterest)
+
+	output := NewHTMLFormatter("cmd/main.go").Format(st.Lines(), linesToShow, linesOfInterest)
+
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{"writes a standalone page", "<!DOCTYPE html>"},
+		{"closes the page", "</html>\n"},
+		{"titles the page with the file name", "<title>cmd/main.go</title>"},
+		{"adds an anchor per line", `<span class="line" id="cmd-main-go-c444f711-L3"><a class="ln" href="#cmd-main-go-c444f711-L3">3</a>`},
+		{"highlights matched lines", `<span class="line match" id="cmd-main-go-c444f711-L4">`},
+		{"highlights syntax", `<span class="tok-keyword">func</span>`},
+		{"escapes the source code", `<span class="tok-string">&#34;&lt;hello&gt;&#34;</span>`},
+		{"collapses hidden lines", "<details class=\"gap\"><summary>⋮ 2 hidden lines</summary>\n" +
+			`<span class="line" id="cmd-main-go-c444f711-L1"><a class="ln" href="#cmd-main-go-c444f711-L1">1</a><span class="tok-keyword">package</span> <span class="tok-identifier">main</span></span>`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if !strings.Contains(output, tc.expected) {
+				t.Errorf("expected output to contain %q, got:\n%s", tc.expected, output)
+			}
+		})
+	}
+
+	t.Run("gives every file a distinct id", func(t *testing.T) {
+		if a, b := htmlID("a/b.go"), htmlID("a-b.go"); a == b {
+			t.Errorf("expected distinct ids, got %s for both", a)
+		}
+	})
+
+	t.Run("only writes the file section as a fragment", func(t *testing.T) {
+		output := NewHTMLFormatter("main.go", WithHTMLFragment(true)).Format(st.Lines(), linesToShow, linesOfInterest)
+
+		if !strings.HasPrefix(output, `<section class="file" id="main-go-2873f79a">`) || strings.Contains(output, "<html>") {
+			t.Errorf("expected only the file section, got:\n%s", output)
+		}
+	})
+}
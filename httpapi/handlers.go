@@ -0,0 +1,210 @@
+package httpapi
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/andersonjoseph/searchast"
+	"github.com/andersonjoseph/searchast/language"
+)
+
+// fileResult is the response for a file. Lines are 1-based.
+type fileResult struct {
+	Path     string       `json:"path"`
+	Language string       `json:"language"`
+	Lines    []resultLine `json:"lines"`
+}
+
+type resultLine struct {
+	Line    int    `json:"line"`
+	Text    string `json:"text"`
+	IsMatch bool   `json:"is_match"`
+}
+
+// searchResult is the response of /search. Files that can't be read or
+// parsed are listed in SkippedFiles rather than failing the search.
+type searchResult struct {
+	Files        []fileResult  `json:"files"`
+	SkippedFiles []skippedFile `json:"skipped_files,omitempty"`
+}
+
+type skippedFile struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+type languageResult struct {
+	Name       string   `json:"name"`
+	Extensions []string `json:"extensions"`
+}
+
+// handleSearch answers /search. The pattern parameter can be repeated to
+// search for lines matching any of them, and the path parameter to search
+// several files or directories, the whole served directory by default. Only
+// invalid requests fail, the files that can't be read or parsed are skipped.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	patterns := query["pattern"]
+	if len(patterns) == 0 || slices.Contains(patterns, "") {
+		writeError(w, badRequest("missing pattern parameter"))
+		return
+	}
+
+	searchOpts := slices.Clone(s.searchOpts)
+	for name, opt := range map[string]func(bool) searchast.SearchOption{
+		"fixed_strings": searchast.WithFixedStrings,
+		"ignore_case":   searchast.WithIgnoreCase,
+		"smart_case":    searchast.WithSmartCase,
+		"whole_word":    searchast.WithWholeWord,
+	} {
+		value, err := boolParam(r, name)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if value != nil {
+			searchOpts = append(searchOpts, opt(*value))
+		}
+	}
+	if err := searchast.ValidatePatterns(patterns, searchOpts...); err != nil {
+		writeError(w, badRequest("%w", err))
+		return
+	}
+
+	contextOpts := slices.Clone(s.contextOpts)
+	for name, opt := range map[string]func(uint32) searchast.Option{
+		"surrounding_lines": searchast.WithSurroundingLines,
+		"child_lines":       searchast.WithChildLines,
+		"gap_to_close":      searchast.WithGapToClose,
+	} {
+		value, err := uintParam(r, name)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if value != nil {
+			contextOpts = append(contextOpts, opt(*value))
+		}
+	}
+	for name, opt := range map[string]func(bool) searchast.Option{
+		"parent_context":   searchast.WithParentContext,
+		"close_scope_gaps": searchast.WithCloseScopeGaps,
+		"expand_scopes":    searchast.WithExpandChildScopes,
+	} {
+		value, err := boolParam(r, name)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if value != nil {
+			contextOpts = append(contextOpts, opt(*value))
+		}
+	}
+
+	paths := query["path"]
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	for i, path := range paths {
+		resolved, err := s.resolve(path)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		paths[i] = resolved
+	}
+
+	files, err := s.listFiles(paths)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	result := searchResult{Files: []fileResult{}}
+	for _, file := range files {
+		if err := r.Context().Err(); err != nil {
+			return // the client is gone
+		}
+
+		entry, err := s.tree(r.Context(), file)
+		if err != nil {
+			result.SkippedFiles = append(result.SkippedFiles, skippedFile{Path: s.relative(file), Error: err.Error()})
+			continue
+		}
+
+		matchingLines, err := entry.tree.SearchAny(patterns, searchOpts...)
+		if err != nil {
+			writeError(w, badRequest("%w", err))
+			return
+		}
+		if len(matchingLines) == 0 {
+			continue
+		}
+
+		linesToShow := searchast.NewContextBuilder(contextOpts...).AddContext(entry.tree, matchingLines)
+		result.Files = append(result.Files, s.newFileResult(file, entry, linesToShow, matchingLines))
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleOverview answers /overview with the outline of the file given by the
+// path parameter, as printed by cmd/overview. Its top level lines are marked
+// as matches.
+func (s *Server) handleOverview(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		writeError(w, badRequest("missing path parameter"))
+		return
+	}
+
+	file, err := s.resolve(path)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	entry, err := s.tree(r.Context(), file)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	linesOfInterest := entry.tree.TopLevel()
+	linesToShow := searchast.NewContextBuilder(searchast.OverviewOptions()...).AddContext(entry.tree, linesOfInterest)
+
+	writeJSON(w, http.StatusOK, s.newFileResult(file, entry, linesToShow, linesOfInterest))
+}
+
+// handleLanguages answers /languages with the supported languages and their
+// file extensions.
+func handleLanguages(w http.ResponseWriter, r *http.Request) {
+	languages := language.Languages()
+
+	result := make([]languageResult, 0, len(languages))
+	for _, lang := range languages {
+		result = append(result, languageResult{Name: lang.Name, Extensions: lang.Extensions})
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// newFileResult returns the response for the lines to show of a file.
+func (s *Server) newFileResult(path string, entry *treeEntry, linesToShow searchast.Set[uint32], linesToHighlight searchast.Set[uint32]) fileResult {
+	name, _ := language.NameFromFilename(path)
+	fr := fileResult{Path: s.relative(path), Language: name, Lines: []resultLine{}}
+
+	lines := entry.tree.Lines()
+	numbers := linesToShow.ToSlice()
+	slices.Sort(numbers)
+	for _, number := range numbers {
+		fr.Lines = append(fr.Lines, resultLine{
+			Line:    int(number) + 1,
+			Text:    lines[number].Text(),
+			IsMatch: linesToHighlight.Has(number),
+		})
+	}
+
+	return fr
+}
@@ -0,0 +1,360 @@
+// Package httpapi implements an HTTP server answering searches and overviews
+// of the files of a directory as JSON. Parsed files are kept in memory, up to
+// a maximum number of files, and dropped when they change on disk.
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/andersonjoseph/searchast"
+	"github.com/andersonjoseph/searchast/language"
+	"github.com/andersonjoseph/searchast/walk"
+)
+
+// defaultPollInterval is the default interval at which Watch checks the
+// parsed files for changes.
+const defaultPollInterval = time.Second
+
+// defaultMaxFiles is the default number of parsed files kept in memory.
+const defaultMaxFiles = 5000
+
+// Server is an http.Handler serving the /search, /overview and /languages
+// endpoints. It is safe for concurrent use.
+type Server struct {
+	root         string
+	searchOpts   []searchast.SearchOption
+	contextOpts  []searchast.Option
+	parseCache   *searchast.ParseCache
+	listFiles    func(paths []string) ([]string, error)
+	pollInterval time.Duration
+	maxFiles     int
+	mux          *http.ServeMux
+
+	mu    sync.RWMutex
+	trees map[string]*treeEntry
+
+	// uses counts the accesses to the trees, to evict the least recently
+	// used ones.
+	uses atomic.Uint64
+}
+
+// treeEntry is a parsed file, along with the modification time and size it
+// had before being read. Its tree is released, as it is only searched.
+type treeEntry struct {
+	tree    *searchast.SourceTree
+	modTime time.Time
+	size    int64
+
+	// lastUse is the value of the uses counter of the server when the tree
+	// was last accessed.
+	lastUse atomic.Uint64
+}
+
+type Option func(*Server)
+
+// NewServer returns a server for the files in root. Request paths are
+// relative to root and cannot leave it.
+func NewServer(root string, opts ...Option) *Server {
+	s := &Server{
+		root:         root,
+		listFiles:    func(paths []string) ([]string, error) { return walk.Files(paths, nil) },
+		pollInterval: defaultPollInterval,
+		maxFiles:     defaultMaxFiles,
+		trees:        make(map[string]*treeEntry),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("GET /search", s.handleSearch)
+	s.mux.HandleFunc("GET /overview", s.handleOverview)
+	s.mux.HandleFunc("GET /languages", handleLanguages)
+
+	return s
+}
+
+// WithSearchOptions sets the options of every search, which requests can
+// only add to.
+func WithSearchOptions(opts ...searchast.SearchOption) Option {
+	return func(s *Server) {
+		s.searchOpts = opts
+	}
+}
+
+// WithContextOptions sets the default options of the context builder used
+// to select the lines returned with search matches.
+func WithContextOptions(opts ...searchast.Option) Option {
+	return func(s *Server) {
+		s.contextOpts = opts
+	}
+}
+
+// WithParseCache parses files through a parse cache, so that files which did
+// not change since a previous run are not parsed again.
+func WithParseCache(pc *searchast.ParseCache) Option {
+	return func(s *Server) {
+		s.parseCache = pc
+	}
+}
+
+// WithFileLister sets the function expanding the paths of a search into the
+// files to search. By default, directories are walked for files with a
+// supported language, skipping .git directories.
+func WithFileLister(listFiles func(paths []string) ([]string, error)) Option {
+	return func(s *Server) {
+		s.listFiles = listFiles
+	}
+}
+
+// WithPollInterval sets the interval at which Watch checks the parsed files
+// for changes.
+func WithPollInterval(d time.Duration) Option {
+	return func(s *Server) {
+		s.pollInterval = d
+	}
+}
+
+// WithMaxFiles sets the number of parsed files kept in memory, above which
+// the least recently used ones are dropped. Zero disables the limit.
+func WithMaxFiles(n int) Option {
+	return func(s *Server) {
+		s.maxFiles = n
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Watch checks the parsed files for changes until ctx is done, dropping the
+// trees of files that were modified or removed so that they are parsed again
+// when requested.
+func (s *Server) Watch(ctx context.Context) {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.poll()
+		}
+	}
+}
+
+// poll drops the trees of the files which changed since they were parsed.
+// The files are stat'ed without holding the lock, which is then taken once to
+// drop every changed file.
+func (s *Server) poll() {
+	s.mu.RLock()
+	entries := make(map[string]*treeEntry, len(s.trees))
+	maps.Copy(entries, s.trees)
+	s.mu.RUnlock()
+
+	var changed []string
+	for path, entry := range entries {
+		if info, err := os.Stat(path); err != nil || !entry.current(info) {
+			changed = append(changed, path)
+		}
+	}
+	if len(changed) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, path := range changed {
+		// The file may have been parsed again since it was stat'ed.
+		if s.trees[path] == entries[path] {
+			delete(s.trees, path)
+		}
+	}
+}
+
+// current reports whether the file described by info is the one that was
+// parsed.
+func (e *treeEntry) current(info fs.FileInfo) bool {
+	return info.ModTime().Equal(e.modTime) && info.Size() == e.size
+}
+
+// tree returns the parsed file at path, parsing it if it is not in memory.
+func (s *Server) tree(ctx context.Context, path string) (*treeEntry, error) {
+	s.mu.RLock()
+	entry, ok := s.trees[path]
+	if ok {
+		entry.lastUse.Store(s.uses.Add(1))
+	}
+	s.mu.RUnlock()
+	if ok {
+		return entry, nil
+	}
+
+	if _, err := language.FromFilename(path); err != nil {
+		return nil, badRequest("failed to parse %s: %w", s.relative(path), err)
+	}
+
+	// The file is stat'ed before being read, so that a change made while it
+	// is read is seen by the next poll.
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s.relative(path), err)
+	}
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s.relative(path), err)
+	}
+
+	newSourceTree := searchast.NewSourceTree
+	if s.parseCache != nil {
+		newSourceTree = s.parseCache.NewSourceTree
+	}
+
+	tree, err := newSourceTree(ctx, bytes.NewReader(source), path)
+	if err != nil {
+		return nil, err
+	}
+	tree.Release()
+
+	entry = &treeEntry{
+		tree:    tree,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+	}
+	entry.lastUse.Store(s.uses.Add(1))
+
+	s.mu.Lock()
+	s.trees[path] = entry
+	s.evict()
+	s.mu.Unlock()
+
+	return entry, nil
+}
+
+// evict drops the least recently used trees above the maximum number of
+// files. It must be called with the lock held.
+func (s *Server) evict() {
+	for s.maxFiles > 0 && len(s.trees) > s.maxFiles {
+		var oldest string
+		var oldestUse uint64
+		for path, entry := range s.trees {
+			if use := entry.lastUse.Load(); oldest == "" || use < oldestUse {
+				oldest, oldestUse = path, use
+			}
+		}
+		delete(s.trees, oldest)
+	}
+}
+
+// resolve returns the path on disk of a request path, which must be local to
+// the root.
+func (s *Server) resolve(path string) (string, error) {
+	path = filepath.FromSlash(path)
+	if !filepath.IsLocal(path) && path != "." {
+		return "", badRequest("path %s is not within the served directory", path)
+	}
+
+	return filepath.Join(s.root, path), nil
+}
+
+// relative returns the request path of a path on disk.
+func (s *Server) relative(path string) string {
+	rel, err := filepath.Rel(s.root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+// httpError is an error answered with a status code other than 500.
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
+func (e *httpError) Unwrap() error {
+	return e.err
+}
+
+func badRequest(format string, args ...any) error {
+	return &httpError{status: http.StatusBadRequest, err: fmt.Errorf(format, args...)}
+}
+
+// writeJSON writes v as the JSON body of a response.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(v) // the client is gone if this fails
+}
+
+// writeError writes err as a JSON error, with the status of an httpError, 404
+// for missing files, and 500 otherwise.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+
+	var httpErr *httpError
+	switch {
+	case errors.As(err, &httpErr):
+		status = httpErr.status
+	case errors.Is(err, fs.ErrNotExist):
+		status = http.StatusNotFound
+	}
+
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// boolParam returns the boolean query parameter name, or nil if it is not
+// set.
+func boolParam(r *http.Request, name string) (*bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, badRequest("invalid %s parameter %q, expected a boolean", name, value)
+	}
+
+	return &b, nil
+}
+
+// uintParam returns the unsigned integer query parameter name, or nil if it
+// is not set.
+func uintParam(r *http.Request, name string) (*uint32, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	n, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return nil, badRequest("invalid %s parameter %q, expected a non-negative integer", name, value)
+	}
+
+	n32 := uint32(n)
+	return &n32, nil
+}
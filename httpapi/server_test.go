@@ -0,0 +1,369 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const greetSource = `package main
+
+import "fmt"
+
+func greet(name string) {
+	if name == "" {
+		name = "world"
+	}
+	fmt.Println("hello", name)
+}
+
+func main() {
+	greet("")
+}
+`
+
+// writeFiles writes files relative to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// get sends a GET request to s and decodes the JSON response into v.
+func get(t *testing.T, s *Server, target string, v any) int {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+
+	if contentType := rec.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Fatalf("expected a JSON response, got %s: %s", contentType, rec.Body)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("failed to decode response %q: %v", rec.Body, err)
+	}
+
+	return rec.Code
+}
+
+// matches returns the matching lines of each file of a search result.
+func matches(result searchResult) map[string][]int {
+	lines := make(map[string][]int)
+	for _, file := range result.Files {
+		lines[file.Path] = []int{}
+		for _, line := range file.Lines {
+			if line.IsMatch {
+				lines[file.Path] = append(lines[file.Path], line.Line)
+			}
+		}
+	}
+
+	return lines
+}
+
+func TestServer_Search(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.go":         greetSource,
+		"lib/greet.go":    strings.ReplaceAll(greetSource, "package main", "package lib"),
+		"lib/notes.txt":   "hello\n",
+		".git/hooks/a.go": "package hooks\n\n// hello\n",
+	})
+	s := NewServer(dir)
+
+	testCases := []struct {
+		name     string
+		query    string
+		expected map[string][]int
+	}{
+		{
+			name:     "searches the supported files of the served directory",
+			query:    "pattern=hello",
+			expected: map[string][]int{"lib/greet.go": {9}, "main.go": {9}},
+		},
+		{
+			name:     "searches the given paths",
+			query:    "pattern=hello&path=lib",
+			expected: map[string][]int{"lib/greet.go": {9}},
+		},
+		{
+			name:     "searches any of the patterns",
+			query:    "pattern=world&pattern=main&path=main.go",
+			expected: map[string][]int{"main.go": {1, 7, 12}},
+		},
+		{
+			name:     "applies the search options",
+			query:    "pattern=HELLO&ignore_case=true&path=main.go",
+			expected: map[string][]int{"main.go": {9}},
+		},
+		{
+			name:     "returns no files without matches",
+			query:    "pattern=missing",
+			expected: map[string][]int{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var result searchResult
+			if status := get(t, s, "/search?"+tc.query, &result); status != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", status)
+			}
+
+			if got := matches(result); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected matches %v, got %v", tc.expected, got)
+			}
+		})
+	}
+
+	t.Run("returns the context of the matches", func(t *testing.T) {
+		var result searchResult
+		get(t, s, "/search?pattern=world&path=main.go&surrounding_lines=0&parent_context=false&expand_scopes=false", &result)
+
+		expected := searchResult{Files: []fileResult{{
+			Path:     "main.go",
+			Language: "go",
+			Lines:    []resultLine{{Line: 7, Text: "\t\tname = \"world\"", IsMatch: true}},
+		}}}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("expected %+v, got %+v", expected, result)
+		}
+
+		get(t, s, "/search?pattern=world&path=main.go", &result)
+		if first, last := result.Files[0].Lines[0].Line, result.Files[0].Lines[len(result.Files[0].Lines)-1].Line; first != 4 || last != 10 {
+			t.Errorf("expected the surrounding lines and greet to be returned, got lines %d to %d", first, last)
+		}
+	})
+}
+
+func TestServer_SearchSkippedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.go": greetSource, "notes.txt": "hello\n"})
+	if err := os.Symlink(filepath.Join(dir, "missing.go"), filepath.Join(dir, "broken.go")); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(dir)
+
+	var result searchResult
+	if status := get(t, s, "/search?pattern=hello&path=.&path=notes.txt", &result); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+
+	if got := matches(result); !reflect.DeepEqual(got, map[string][]int{"main.go": {9}}) {
+		t.Errorf("expected a match in main.go, got %v", got)
+	}
+
+	var skipped []string
+	for _, file := range result.SkippedFiles {
+		if file.Error == "" {
+			t.Errorf("expected an error for %s", file.Path)
+		}
+		skipped = append(skipped, file.Path)
+	}
+	if expected := []string{"broken.go", "notes.txt"}; !reflect.DeepEqual(skipped, expected) {
+		t.Errorf("expected skipped files %v, got %v", expected, skipped)
+	}
+}
+
+func TestServer_Errors(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.go": greetSource, "notes.txt": "hello\n"})
+	s := NewServer(dir)
+
+	testCases := []struct {
+		name   string
+		target string
+		status int
+	}{
+		{name: "missing pattern", target: "/search", status: http.StatusBadRequest},
+		{name: "invalid pattern", target: "/search?pattern=(", status: http.StatusBadRequest},
+		{name: "invalid pattern without searched files", target: "/search?pattern=(&path=notes.txt", status: http.StatusBadRequest},
+		{name: "invalid option", target: "/search?pattern=a&ignore_case=maybe", status: http.StatusBadRequest},
+		{name: "invalid context option", target: "/search?pattern=a&surrounding_lines=-1", status: http.StatusBadRequest},
+		{name: "path outside the served directory", target: "/search?pattern=a&path=../main.go", status: http.StatusBadRequest},
+		{name: "absolute path", target: "/overview?path=/etc/passwd", status: http.StatusBadRequest},
+		{name: "missing search path", target: "/search?pattern=a&path=missing", status: http.StatusNotFound},
+		{name: "missing overview path", target: "/overview", status: http.StatusBadRequest},
+		{name: "missing file", target: "/overview?path=missing.go", status: http.StatusNotFound},
+		{name: "unsupported file", target: "/overview?path=notes.txt", status: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var result struct {
+				Error string `json:"error"`
+			}
+			if status := get(t, s, tc.target, &result); status != tc.status {
+				t.Errorf("expected status %d, got %d: %s", tc.status, status, result.Error)
+			}
+			if result.Error == "" {
+				t.Error("expected an error message")
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/search?pattern=a", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected POST to be rejected, got status %d", rec.Code)
+	}
+}
+
+func TestServer_Overview(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"cmd/main.go": greetSource})
+
+	var result fileResult
+	if status := get(t, NewServer(dir), "/overview?path=cmd/main.go", &result); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+
+	if got := matches(searchResult{Files: []fileResult{result}}); !reflect.DeepEqual(got, map[string][]int{"cmd/main.go": {1, 3, 5, 12}}) {
+		t.Errorf("expected the top level lines of cmd/main.go, got %v", got)
+	}
+	if result.Language != "go" || len(result.Lines) != 14 {
+		t.Errorf("expected the 14 lines of a go file, got %s with %d lines", result.Language, len(result.Lines))
+	}
+}
+
+func TestServer_Languages(t *testing.T) {
+	var result []languageResult
+	if status := get(t, NewServer(t.TempDir()), "/languages", &result); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+
+	for _, lang := range result {
+		if lang.Name == "typescript" {
+			if expected := []string{".ts", ".tsx"}; !reflect.DeepEqual(lang.Extensions, expected) {
+				t.Errorf("expected typescript extensions %v, got %v", expected, lang.Extensions)
+			}
+			return
+		}
+	}
+	t.Errorf("expected typescript to be listed, got %v", result)
+}
+
+func TestServer_Poll(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.go": greetSource, "lib/lib.go": "package lib\n"})
+	s := NewServer(dir)
+
+	search := func() map[string][]int {
+		t.Helper()
+
+		var result searchResult
+		get(t, s, "/search?pattern=hello", &result)
+		return matches(result)
+	}
+
+	if got := search(); !reflect.DeepEqual(got, map[string][]int{"main.go": {9}}) {
+		t.Fatalf("expected a match in main.go, got %v", got)
+	}
+
+	// Until the next poll, the parsed file is used.
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n\n// hello\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, time.Time{}, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if got := search(); !reflect.DeepEqual(got, map[string][]int{"main.go": {9}}) {
+		t.Errorf("expected the parsed file to be used before polling, got %v", got)
+	}
+
+	s.poll()
+	if got := search(); !reflect.DeepEqual(got, map[string][]int{"main.go": {3}}) {
+		t.Errorf("expected the modified file to be parsed again, got %v", got)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	s.poll()
+	if _, ok := s.trees[path]; ok {
+		t.Error("expected the tree of the removed file to be dropped")
+	}
+	if got := search(); !reflect.DeepEqual(got, map[string][]int{}) {
+		t.Errorf("expected no matches once main.go is removed, got %v", got)
+	}
+}
+
+func TestServer_MaxFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.go": greetSource, "b.go": greetSource, "c.go": greetSource})
+	s := NewServer(dir, WithMaxFiles(2))
+
+	overview := func(name string) {
+		t.Helper()
+
+		var result fileResult
+		if status := get(t, s, "/overview?path="+name, &result); status != http.StatusOK {
+			t.Fatalf("expected status 200 for %s, got %d", name, status)
+		}
+	}
+
+	// b.go is the least recently used file when c.go is parsed.
+	overview("a.go")
+	overview("b.go")
+	overview("a.go")
+	overview("c.go")
+
+	for name, expected := range map[string]bool{"a.go": true, "b.go": false, "c.go": true} {
+		if _, ok := s.trees[filepath.Join(dir, name)]; ok != expected {
+			t.Errorf("expected %s to be kept in memory: %v, got %v", name, expected, ok)
+		}
+	}
+
+	var result searchResult
+	get(t, s, "/search?pattern=hello", &result)
+	if got := matches(result); !reflect.DeepEqual(got, map[string][]int{"a.go": {9}, "b.go": {9}, "c.go": {9}}) {
+		t.Errorf("expected every file to be searched, got %v", got)
+	}
+	if len(s.trees) != 2 {
+		t.Errorf("expected 2 files to be kept in memory, got %d", len(s.trees))
+	}
+}
+
+func TestServer_Concurrent(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.go": greetSource, "app.py": "def hello():\n    pass\n"})
+	s := NewServer(dir)
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	errs := make(chan error, 8)
+	for range cap(errs) {
+		go func() {
+			resp, err := http.Get(server.URL + "/search?pattern=hello")
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					err = fmt.Errorf("unexpected status %d", resp.StatusCode)
+				}
+			}
+			errs <- err
+		}()
+	}
+	for range cap(errs) {
+		if err := <-errs; err != nil {
+			t.Errorf("expected concurrent searches to succeed, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package searchast
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// hyperlinkAliases are the predefined hyperlink formats.
+var hyperlinkAliases = map[string]string{
+	"file":   "file://{host}{path}#L{line}",
+	"vscode": "vscode://file{path}:{line}:{column}",
+	"cursor": "cursor://file{path}:{line}:{column}",
+}
+
+// WithHyperlinkFormat makes the line numbers, and the paths printed by
+// WriteHeader, OSC 8 terminal hyperlinks. The format is either one of the
+// aliases "file", "vscode" and "cursor", or a URL template where {path} is
+// replaced with the absolute path of the file, {line} and {column} with the
+// 1-based position and {host} with the host name, e.g.
+// "vscode://file{path}:{line}". An empty format disables hyperlinks. Line
+// numbers are only linked when the file is set with WithHyperlinkPath.
+func WithHyperlinkFormat(format string) TextFormatterOption {
+	return func(tf *TextFormatter) {
+		if alias, exists := hyperlinkAliases[format]; exists {
+			format = alias
+		}
+		tf.hyperlinkFormat = format
+	}
+}
+
+// WithHyperlinkPath sets the file the line numbers link to.
+func WithHyperlinkPath(path string) TextFormatterOption {
+	return func(tf *TextFormatter) {
+		tf.hyperlinkPath = absolutePath(path)
+	}
+}
+
+// hyperlink wraps text in an OSC 8 hyperlink to the line of the file at the
+// absolute path. It returns text unchanged if hyperlinks are disabled.
+func (tf *TextFormatter) hyperlink(text string, path string, line int) string {
+	if tf.hyperlinkFormat == "" || path == "" {
+		return text
+	}
+
+	var host string
+	if strings.Contains(tf.hyperlinkFormat, "{host}") {
+		host, _ = os.Hostname()
+	}
+
+	link := strings.NewReplacer(
+		"{path}", (&url.URL{Path: path}).EscapedPath(),
+		"{line}", strconv.Itoa(line),
+		"{column}", "1",
+		"{host}", host,
+	).Replace(tf.hyperlinkFormat)
+
+	return "\033]8;;" + link + "\033\\" + text + "\033]8;;\033\\"
+}
+
+// absolutePath returns path as an absolute path with forward slashes, as used
+// in URLs.
+func absolutePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // Windows drive letters
+	}
+
+	return path
+}
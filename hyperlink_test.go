@@ -0,0 +1,62 @@
+package searchast
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTextFormatter_Hyperlinks(t *testing.T) {
+	source := `package main
+
+func main() {
+	fmt.Println("hello")
+}`
+
+	st := mustNewSourceTree(t, source)
+	linesOfInterest := NewSetFromSlice([]lineNumber{3})
+	linesToShow := NewSetFromSlice([]lineNumber{2, 3, 4})
+
+	testCases := []struct {
+		name     string
+		opts     []TextFormatterOption
+		expected string
+	}{
+		{
+			name:     "links line numbers with a URL template",
+			opts:     []TextFormatterOption{WithHyperlinkFormat("editor://open?file={path}&line={line}"), WithHyperlinkPath("/src/my app/main.go")},
+			expected: "\033]8;;editor://open?file=/src/my%20app/main.go&line=4\033\\4\033]8;;\033\\ █ \tfmt.Println(\"hello\")\n",
+		},
+		{
+			name:     "expands aliases",
+			opts:     []TextFormatterOption{WithHyperlinkFormat("vscode"), WithHyperlinkPath("/src/main.go")},
+			expected: "\033]8;;vscode://file/src/main.go:4:1\033\\4\033]8;;\033\\ █ \tfmt.Println(\"hello\")\n",
+		},
+		{
+			name:     "does not link without a path",
+			opts:     []TextFormatterOption{WithHyperlinkFormat("vscode")},
+			expected: "4 █ \tfmt.Println(\"hello\")\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output := NewTextFormatter(tc.opts...).Format(st.Lines(), linesToShow, linesOfInterest)
+
+			if !strings.Contains(output, tc.expected) {
+				t.Errorf("expected output to contain:\n%q\nbut got:\n%q", tc.expected, output)
+			}
+		})
+	}
+
+	t.Run("links file headers", func(t *testing.T) {
+		output := strings.Builder{}
+		if err := NewTextFormatter(WithHyperlinkFormat("file://{path}")).WriteHeader(&output, "/src/main.go", "go", 1); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+
+		expected := "\033]8;;file:///src/main.go\033\\/src/main.go\033]8;;\033\\ (go, 1 matching line)\n"
+		if output.String() != expected {
+			t.Errorf("expected %q, but got %q", expected, output.String())
+		}
+	})
+}
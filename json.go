@@ -0,0 +1,264 @@
+package searchast
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// rgData is an arbitrary piece of data, ripgrep reports text that isn't valid
+// UTF-8 as base64 encoded bytes instead.
+type rgData struct {
+	Text  *string `json:"text,omitempty"`
+	Bytes *string `json:"bytes,omitempty"`
+}
+
+func newRGData(s string) rgData {
+	if utf8.ValidString(s) {
+		return rgData{Text: &s}
+	}
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(s))
+	return rgData{Bytes: &encoded}
+}
+
+type rgMessage struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+type rgBegin struct {
+	Path rgData `json:"path"`
+}
+
+type rgLine struct {
+	Path           rgData       `json:"path"`
+	Lines          rgData       `json:"lines"`
+	LineNumber     int          `json:"line_number"`
+	AbsoluteOffset int64        `json:"absolute_offset"`
+	Submatches     []rgSubmatch `json:"submatches"`
+}
+
+type rgSubmatch struct {
+	Match rgData `json:"match"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+type rgEnd struct {
+	Path         rgData  `json:"path"`
+	BinaryOffset *int64  `json:"binary_offset"`
+	Stats        rgStats `json:"stats"`
+}
+
+type rgSummary struct {
+	ElapsedTotal rgDuration `json:"elapsed_total"`
+	Stats        rgStats    `json:"stats"`
+}
+
+type rgStats struct {
+	Elapsed           rgDuration `json:"elapsed"`
+	Searches          int        `json:"searches"`
+	SearchesWithMatch int        `json:"searches_with_match"`
+	BytesSearched     int64      `json:"bytes_searched"`
+	BytesPrinted      int64      `json:"bytes_printed"`
+	MatchedLines      int        `json:"matched_lines"`
+	Matches           int        `json:"matches"`
+}
+
+type rgDuration struct {
+	Secs  int64  `json:"secs"`
+	Nanos int64  `json:"nanos"`
+	Human string `json:"human"`
+}
+
+func newRGDuration(d time.Duration) rgDuration {
+	return rgDuration{
+		Secs:  int64(d / time.Second),
+		Nanos: int64(d % time.Second),
+		Human: fmt.Sprintf("%.6fs", d.Seconds()),
+	}
+}
+
+func newRGStats(s SearchStats) rgStats {
+	return rgStats{
+		Elapsed:           newRGDuration(s.Elapsed),
+		Searches:          s.Searches,
+		SearchesWithMatch: s.SearchesWithMatch,
+		BytesSearched:     s.BytesSearched,
+		BytesPrinted:      s.BytesPrinted,
+		MatchedLines:      s.MatchedLines,
+		Matches:           s.Matches,
+	}
+}
+
+// JSONFormatter emits the JSON Lines message stream of ripgrep's --json flag:
+// a begin message, a match message per highlighted line, a context message
+// per other line to show, and an end message with the stats of the file.
+type JSONFormatter struct {
+	filename string
+	matchers []*matcher
+	created  time.Time
+	stats    SearchStats
+}
+
+// NewJSONFormatter creates a formatter for the given file. The patterns are
+// only used to report the submatches of each line and are compiled with the
+// given search options, which should be the ones used to search the file.
+func NewJSONFormatter(filename string, patterns []string, opts ...SearchOption) (*JSONFormatter, error) {
+	matchers, err := newMatchers(patterns, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JSONFormatter{
+		filename: filename,
+		matchers: matchers,
+		created:  time.Now(),
+	}, nil
+}
+
+// Stats returns the stats of the last formatted file. Elapsed is measured
+// from the creation of the formatter.
+func (jf *JSONFormatter) Stats() SearchStats {
+	return jf.stats
+}
+
+func (jf *JSONFormatter) Format(lines []line, linesToShow Set[lineNumber], linesToHighlight Set[lineNumber]) string {
+	output := strings.Builder{}
+	_ = jf.FormatTo(&output, lines, linesToShow, linesToHighlight) // writing to a strings.Builder never fails
+
+	return output.String()
+}
+
+// FormatTo writes the messages of the file to w, one JSON object per line.
+// Nothing is written if no line is highlighted.
+func (jf *JSONFormatter) FormatTo(w io.Writer, lines []line, linesToShow Set[lineNumber], linesToHighlight Set[lineNumber]) error {
+	jf.stats = SearchStats{Searches: 1}
+	for i, line := range lines {
+		jf.stats.BytesSearched += int64(len(line.text))
+		if i < len(lines)-1 {
+			jf.stats.BytesSearched++ // the newline
+		}
+	}
+
+	if len(linesToHighlight) == 0 {
+		jf.stats.Elapsed = time.Since(jf.created)
+		return nil
+	}
+	jf.stats.SearchesWithMatch = 1
+
+	submatches := make(map[lineNumber][]rgSubmatch, len(linesToHighlight))
+	var matches []Match
+	for _, m := range jf.matchers {
+		matches = append(matches, findMatches(lines, m)...)
+	}
+	sortMatches(matches)
+
+	for _, match := range matches {
+		if !linesToHighlight.Has(match.StartLine) {
+			continue
+		}
+
+		text := lines[match.StartLine].text
+		end := len(text)
+		if match.EndLine == match.StartLine {
+			end = min(match.EndColumn, end)
+		}
+
+		submatches[match.StartLine] = append(submatches[match.StartLine], rgSubmatch{
+			Match: newRGData(text[match.StartColumn:end]),
+			Start: match.StartColumn,
+			End:   end,
+		})
+	}
+
+	counter := &countingWriter{w: w}
+	encoder := json.NewEncoder(counter)
+	encoder.SetEscapeHTML(false)
+	path := newRGData(jf.filename)
+
+	if err := encoder.Encode(rgMessage{Type: "begin", Data: rgBegin{Path: path}}); err != nil {
+		return err
+	}
+
+	var offset int64
+	for i, line := range lines {
+		lineOffset := offset
+		offset += int64(len(line.text)) + 1
+
+		if !linesToShow.Has(lineNumber(i)) && !linesToHighlight.Has(lineNumber(i)) {
+			continue
+		}
+
+		text := line.text
+		if i < len(lines)-1 {
+			text += "\n"
+		}
+
+		message := rgMessage{Type: "context"}
+		data := rgLine{
+			Path:           path,
+			Lines:          newRGData(text),
+			LineNumber:     i + 1,
+			AbsoluteOffset: lineOffset,
+			Submatches:     []rgSubmatch{},
+		}
+		if linesToHighlight.Has(lineNumber(i)) {
+			message.Type = "match"
+			if lineSubmatches := submatches[lineNumber(i)]; lineSubmatches != nil {
+				data.Submatches = lineSubmatches
+			}
+			jf.stats.MatchedLines++
+			jf.stats.Matches += len(data.Submatches)
+		}
+		message.Data = data
+
+		if err := encoder.Encode(message); err != nil {
+			return err
+		}
+	}
+
+	jf.stats.Elapsed = time.Since(jf.created)
+	jf.stats.BytesPrinted = counter.n
+
+	end := rgMessage{Type: "end", Data: rgEnd{Path: path, Stats: newRGStats(jf.stats)}}
+	if err := encoder.Encode(end); err != nil {
+		return err
+	}
+	jf.stats.BytesPrinted = counter.n
+
+	return nil
+}
+
+// WriteJSONSummary writes the summary message closing a ripgrep JSON stream,
+// with the stats of every searched file and the total elapsed time.
+func WriteJSONSummary(w io.Writer, stats SearchStats, elapsed time.Duration) error {
+	summary := rgMessage{
+		Type: "summary",
+		Data: rgSummary{
+			ElapsedTotal: newRGDuration(elapsed),
+			Stats:        newRGStats(stats),
+		},
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(summary)
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,97 @@
+package searchast
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONFormatter_Format(t *testing.T) {
+	source := "package main\n\nfunc main() {\n\tfmt.Println(\"<a>\"); fmt.Println(\"b\")\n}\n"
+
+	st := mustNewSourceTree(t, source)
+	linesOfInterest, err := st.Search("Println")
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	linesToShow := NewSetFromSlice([]lineNumber{2, 3, 4})
+
+	formatter, err := NewJSONFormatter("main.go", []string{"Println"})
+	if err != nil {
+		t.Fatalf("failed to create formatter: %v", err)
+	}
+	output := formatter.Format(st.Lines(), linesToShow, linesOfInterest)
+
+	var messages []map[string]any
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		var message map[string]any
+		if err := json.Unmarshal([]byte(line), &message); err != nil {
+			t.Fatalf("expected a JSON object per line, but got %q: %v", line, err)
+		}
+		messages = append(messages, message)
+	}
+
+	t.Run("emits the messages in order", func(t *testing.T) {
+		var types []string
+		for _, message := range messages {
+			types = append(types, message["type"].(string))
+		}
+
+		expected := "begin context match context end"
+		if got := strings.Join(types, " "); got != expected {
+			t.Errorf("expected messages %q, but got %q", expected, got)
+		}
+	})
+
+	t.Run("reports the match with its submatches", func(t *testing.T) {
+		expected := `{"type":"match","data":{"path":{"text":"main.go"},"lines":{"text":"\tfmt.Println(\"<a>\"); fmt.Println(\"b\")\n"},` +
+			`"line_number":4,"absolute_offset":28,"submatches":[{"match":{"text":"Println"},"start":5,"end":12},{"match":{"text":"Println"},"start":25,"end":32}]}}`
+
+		if line := strings.Split(output, "\n")[2]; line != expected {
+			t.Errorf("expected:\n%s\nbut got:\n%s", expected, line)
+		}
+	})
+
+	t.Run("reports context lines without submatches", func(t *testing.T) {
+		data := messages[1]["data"].(map[string]any)
+		if data["line_number"] != 3.0 || len(data["submatches"].([]any)) != 0 {
+			t.Errorf("unexpected context message: %v", data)
+		}
+	})
+
+	t.Run("reports the stats of the file", func(t *testing.T) {
+		stats := formatter.Stats()
+		if stats.Searches != 1 || stats.SearchesWithMatch != 1 || stats.MatchedLines != 1 || stats.Matches != 2 {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+		if stats.BytesSearched != int64(len(source)) {
+			t.Errorf("expected %d bytes searched, but got %d", len(source), stats.BytesSearched)
+		}
+		if stats.BytesPrinted != int64(len(output)) {
+			t.Errorf("expected %d bytes printed, but got %d", len(output), stats.BytesPrinted)
+		}
+	})
+
+	t.Run("writes nothing without matches", func(t *testing.T) {
+		if output := formatter.Format(st.Lines(), linesToShow, NewSet[lineNumber]()); output != "" {
+			t.Errorf("expected no output, but got %q", output)
+		}
+	})
+}
+
+func TestWriteJSONSummary(t *testing.T) {
+	var output bytes.Buffer
+	stats := SearchStats{Searches: 2, SearchesWithMatch: 1, MatchedLines: 3, Matches: 4}
+	if err := WriteJSONSummary(&output, stats, 1500*time.Millisecond); err != nil {
+		t.Fatalf("failed to write summary: %v", err)
+	}
+
+	expected := `{"type":"summary","data":{"elapsed_total":{"secs":1,"nanos":500000000,"human":"1.500000s"},` +
+		`"stats":{"elapsed":{"secs":0,"nanos":0,"human":"0.000000s"},"searches":2,"searches_with_match":1,` +
+		`"bytes_searched":0,"bytes_printed":0,"matched_lines":3,"matches":4}}}` + "\n"
+	if output.String() != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, output.String())
+	}
+}
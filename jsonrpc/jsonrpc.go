@@ -0,0 +1,103 @@
+// Package jsonrpc defines the JSON-RPC 2.0 messages shared by the language
+// server and the MCP server, which only differ in how messages are framed.
+package jsonrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Error codes defined by JSON-RPC.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+)
+
+// Message is a request, notification or response. Notifications have no ID.
+type Message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *ResponseError  `json:"error,omitempty"`
+}
+
+// Response is a successful response. Unlike Message, it always has a result,
+// which is null for requests without one.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result"`
+}
+
+// ErrorResponse is the response to a failed request.
+type ErrorResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   *ResponseError  `json:"error"`
+}
+
+// Notification is a message sent without expecting a response.
+type Notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+// ResponseError is the error of a failed request.
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// Reply returns the response to the request with the given ID, an
+// ErrorResponse if err is not nil. Errors other than a *ResponseError are
+// reported with the given code.
+func Reply(id json.RawMessage, result any, err error, code int) any {
+	if err == nil {
+		return Response{JSONRPC: "2.0", ID: id, Result: result}
+	}
+
+	var responseErr *ResponseError
+	if !errors.As(err, &responseErr) {
+		responseErr = &ResponseError{Code: code, Message: err.Error()}
+	}
+
+	return ErrorResponse{JSONRPC: "2.0", ID: id, Error: responseErr}
+}
+
+// ParseError returns the response to a message that is not valid JSON.
+func ParseError(err error) ErrorResponse {
+	return ErrorResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &ResponseError{Code: CodeParseError, Message: err.Error()}}
+}
+
+// DecodeParams decodes the params of a message into v, missing params
+// leaving it unchanged.
+func DecodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &ResponseError{Code: CodeInvalidParams, Message: err.Error()}
+	}
+
+	return nil
+}
+
+// DecodeAndRun decodes the params of a message and calls run with them.
+func DecodeAndRun[P any](params json.RawMessage, run func(P) (any, error)) (any, error) {
+	var p P
+	if err := DecodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	return run(p)
+}
@@ -0,0 +1,249 @@
+package searchast
+
+import sitter "github.com/smacker/go-tree-sitter"
+
+// Scope kind categories shared across languages.
+const (
+	ScopeKindFunction = "function"
+	ScopeKindMethod   = "method"
+	ScopeKindClass    = "class"
+)
+
+// scopeCategories maps the node types of each language, by language name, to
+// the scope kind categories. Node types without a category, such as literals
+// and modules, are left out. Functions nested directly in a class are methods,
+// see nodeCategory.
+var scopeCategories = map[string]map[string]string{
+	"ada": {
+		"subprogram_body":                 ScopeKindFunction,
+		"expression_function_declaration": ScopeKindFunction,
+	},
+	"bash": {
+		"function_definition": ScopeKindFunction,
+	},
+	"c": {
+		"function_definition": ScopeKindFunction,
+		"struct_specifier":    ScopeKindClass,
+		"union_specifier":     ScopeKindClass,
+	},
+	"commonlisp": {
+		"defun": ScopeKindFunction,
+	},
+	"cpp": {
+		"function_definition": ScopeKindFunction,
+		"lambda_expression":   ScopeKindFunction,
+		"class_specifier":     ScopeKindClass,
+		"struct_specifier":    ScopeKindClass,
+		"union_specifier":     ScopeKindClass,
+	},
+	"csharp": {
+		"local_function_statement":    ScopeKindFunction,
+		"lambda_expression":           ScopeKindFunction,
+		"anonymous_method_expression": ScopeKindFunction,
+		"method_declaration":          ScopeKindMethod,
+		"constructor_declaration":     ScopeKindMethod,
+		"destructor_declaration":      ScopeKindMethod,
+		"class_declaration":           ScopeKindClass,
+		"struct_declaration":          ScopeKindClass,
+		"interface_declaration":       ScopeKindClass,
+		"record_declaration":          ScopeKindClass,
+	},
+	"dart": {
+		"function_signature":    ScopeKindFunction,
+		"function_expression":   ScopeKindFunction,
+		"method_signature":      ScopeKindMethod,
+		"constructor_signature": ScopeKindMethod,
+		"class_definition":      ScopeKindClass,
+		"mixin_declaration":     ScopeKindClass,
+		"extension_declaration": ScopeKindClass,
+	},
+	"erlang": {
+		"fun_decl":      ScopeKindFunction,
+		"anonymous_fun": ScopeKindFunction,
+	},
+	"fortran": {
+		"function":                ScopeKindFunction,
+		"subroutine":              ScopeKindFunction,
+		"derived_type_definition": ScopeKindClass,
+	},
+	"fsharp": {
+		"function_declaration_left": ScopeKindFunction,
+		"method_or_prop_defn":       ScopeKindMethod,
+		"anon_type_defn":            ScopeKindClass,
+		"interface_type_defn":       ScopeKindClass,
+	},
+	"gdscript": {
+		"function_definition": ScopeKindFunction,
+		"lambda":              ScopeKindFunction,
+		"class_definition":    ScopeKindClass,
+	},
+	"gleam": {
+		"function":           ScopeKindFunction,
+		"anonymous_function": ScopeKindFunction,
+	},
+	"go": {
+		"function_declaration": ScopeKindFunction,
+		"func_literal":         ScopeKindFunction,
+		"method_declaration":   ScopeKindMethod,
+		"struct_type":          ScopeKindClass,
+		"interface_type":       ScopeKindClass,
+	},
+	"groovy": {
+		"function_definition":  ScopeKindFunction,
+		"function_declaration": ScopeKindFunction,
+		"closure":              ScopeKindFunction,
+		"class_definition":     ScopeKindClass,
+	},
+	"java": {
+		"lambda_expression":               ScopeKindFunction,
+		"method_declaration":              ScopeKindMethod,
+		"constructor_declaration":         ScopeKindMethod,
+		"compact_constructor_declaration": ScopeKindMethod,
+		"class_declaration":               ScopeKindClass,
+		"interface_declaration":           ScopeKindClass,
+		"enum_declaration":                ScopeKindClass,
+		"record_declaration":              ScopeKindClass,
+	},
+	"javascript": {
+		"function_declaration":           ScopeKindFunction,
+		"function_expression":            ScopeKindFunction,
+		"generator_function_declaration": ScopeKindFunction,
+		"generator_function":             ScopeKindFunction,
+		"arrow_function":                 ScopeKindFunction,
+		"method_definition":              ScopeKindMethod,
+		"class_declaration":              ScopeKindClass,
+		"class":                          ScopeKindClass,
+	},
+	"kotlin": {
+		"function_declaration":  ScopeKindFunction,
+		"anonymous_function":    ScopeKindFunction,
+		"lambda_literal":        ScopeKindFunction,
+		"secondary_constructor": ScopeKindMethod,
+		"class_declaration":     ScopeKindClass,
+		"object_declaration":    ScopeKindClass,
+	},
+	"lua": {
+		"function_declaration": ScopeKindFunction,
+		"function_definition":  ScopeKindFunction,
+	},
+	"matlab": {
+		"function_definition": ScopeKindFunction,
+		"class_definition":    ScopeKindClass,
+	},
+	"nix": {
+		"function_expression": ScopeKindFunction,
+	},
+	"ocaml": {
+		"function_expression": ScopeKindFunction,
+		"method_definition":   ScopeKindMethod,
+		"class_definition":    ScopeKindClass,
+	},
+	"pascal": {
+		"defProc":   ScopeKindFunction,
+		"declClass": ScopeKindClass,
+	},
+	"perl": {
+		"subroutine_declaration_statement": ScopeKindFunction,
+		"anonymous_subroutine_expression":  ScopeKindFunction,
+		"method_declaration_statement":     ScopeKindMethod,
+		"anonymous_method_expression":      ScopeKindMethod,
+		"class_statement":                  ScopeKindClass,
+	},
+	"php": {
+		"function_definition":   ScopeKindFunction,
+		"anonymous_function":    ScopeKindFunction,
+		"arrow_function":        ScopeKindFunction,
+		"method_declaration":    ScopeKindMethod,
+		"class_declaration":     ScopeKindClass,
+		"interface_declaration": ScopeKindClass,
+		"trait_declaration":     ScopeKindClass,
+		"enum_declaration":      ScopeKindClass,
+		"anonymous_class":       ScopeKindClass,
+	},
+	"powershell": {
+		"function_statement":      ScopeKindFunction,
+		"class_method_definition": ScopeKindMethod,
+		"class_statement":         ScopeKindClass,
+	},
+	"python": {
+		"function_definition": ScopeKindFunction,
+		"lambda":              ScopeKindFunction,
+		"class_definition":    ScopeKindClass,
+	},
+	"r": {
+		"function_definition": ScopeKindFunction,
+	},
+	"ruby": {
+		"lambda":           ScopeKindFunction,
+		"method":           ScopeKindMethod,
+		"singleton_method": ScopeKindMethod,
+		"class":            ScopeKindClass,
+		"singleton_class":  ScopeKindClass,
+	},
+	"rust": {
+		"function_item":      ScopeKindFunction,
+		"closure_expression": ScopeKindFunction,
+		"struct_item":        ScopeKindClass,
+		"union_item":         ScopeKindClass,
+		"trait_item":         ScopeKindClass,
+		"impl_item":          ScopeKindClass,
+	},
+	"scala": {
+		"function_definition": ScopeKindFunction,
+		"lambda_expression":   ScopeKindFunction,
+		"class_definition":    ScopeKindClass,
+		"object_definition":   ScopeKindClass,
+		"trait_definition":    ScopeKindClass,
+	},
+	"sql": {
+		"create_function": ScopeKindFunction,
+	},
+	"swift": {
+		"function_declaration": ScopeKindFunction,
+		"lambda_literal":       ScopeKindFunction,
+		"init_declaration":     ScopeKindMethod,
+		"deinit_declaration":   ScopeKindMethod,
+		"class_declaration":    ScopeKindClass,
+		"protocol_declaration": ScopeKindClass,
+	},
+	"typescript": {
+		"function_declaration":           ScopeKindFunction,
+		"function_expression":            ScopeKindFunction,
+		"generator_function_declaration": ScopeKindFunction,
+		"generator_function":             ScopeKindFunction,
+		"arrow_function":                 ScopeKindFunction,
+		"method_definition":              ScopeKindMethod,
+		"class_declaration":              ScopeKindClass,
+		"abstract_class_declaration":     ScopeKindClass,
+		"class":                          ScopeKindClass,
+		"interface_declaration":          ScopeKindClass,
+	},
+	"zig": {
+		"function_declaration": ScopeKindFunction,
+		"struct_declaration":   ScopeKindClass,
+		"union_declaration":    ScopeKindClass,
+	},
+}
+
+// nodeCategory returns the scope kind category of a node of the given
+// language, or an empty string if it has none. Functions whose closest
+// categorized ancestor is a class are methods.
+func nodeCategory(lang string, node *sitter.Node) string {
+	categories := scopeCategories[lang]
+
+	category := categories[node.Type()]
+	if category != ScopeKindFunction {
+		return category
+	}
+
+	for parent := node.Parent(); parent != nil; parent = parent.Parent() {
+		switch categories[parent.Type()] {
+		case ScopeKindClass:
+			return ScopeKindMethod
+		case ScopeKindFunction, ScopeKindMethod:
+			return ScopeKindFunction
+		}
+	}
+
+	return ScopeKindFunction
+}
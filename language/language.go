@@ -5,7 +5,12 @@ package language
 import (
 	"fmt"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"slices"
 	"strings"
+	"sync"
 	"unsafe"
 
 	// this language list is based on the top most popular programming, scripting, and markup languages
@@ -55,64 +60,106 @@ import (
 
 var langToFactory = make(map[string]func() unsafe.Pointer)
 var langCache = make(map[string]*sitter.Language)
+var langCacheMu sync.Mutex // guards langCache, filled by concurrent parses
+var extToName = make(map[string]string)
+var nameToFactory = make(map[string]func() unsafe.Pointer)
+
+// nameAliases maps former language names to their current name, so that
+// existing configurations keep working.
+var nameAliases = map[string]string{
+	"c_sharp": "csharp",
+}
 
 func init() {
 	supportedLangs := []struct {
+		name       string
 		factory    func() unsafe.Pointer
 		extensions []string
 	}{
-		{javascript.GetLanguage, []string{".js", ".mjs"}},
-		{typescript.GetLanguage, []string{".ts", ".tsx"}},
-		{python.GetLanguage, []string{".py"}},
-		{golang.GetLanguage, []string{".go"}},
-		{rust.GetLanguage, []string{".rs"}},
-		{java.GetLanguage, []string{".java"}},
-		{c.GetLanguage, []string{".c"}},
-		{cpp.GetLanguage, []string{".cpp", ".hpp", ".hxx", ".hh", ".cc", ".cxx"}},
-		{c_sharp.GetLanguage, []string{".cs"}},
-		{bash.GetLanguage, []string{".sh"}},
-		{html.GetLanguage, []string{".html", ".htm"}},
-		{css.GetLanguage, []string{".css"}},
-		{ruby.GetLanguage, []string{".rb"}},
-		{php.GetLanguage, []string{".php", ".php3", ".phtml"}},
-		{swift.GetLanguage, []string{".swift"}},
-		{kotlin.GetLanguage, []string{".kt", ".kts"}},
-		{scala.GetLanguage, []string{".scala"}},
-		{sql.GetLanguage, []string{".sql"}},
-		{lua.GetLanguage, []string{".lua"}},
-		{perl.GetLanguage, []string{".pl"}},
-		{powershell.GetLanguage, []string{".ps1"}},
-		{dart.GetLanguage, []string{".dart"}},
-		{r.GetLanguage, []string{".r"}},
-		{zig.GetLanguage, []string{".zig"}},
-		{ada.GetLanguage, []string{".adb", ".ads"}},
-		{asm.GetLanguage, []string{".asm", ".s"}},
-		{cobol.GetLanguage, []string{".cbl", ".cob"}},
-		{commonlisp.GetLanguage, []string{".lisp", ".cl"}},
-		{elixir.GetLanguage, []string{".ex", ".exs"}},
-		{erlang.GetLanguage, []string{".erl", ".hrl"}},
-		{fortran.GetLanguage, []string{".f", ".for", ".f90", ".f95", ".f03"}},
-		{fsharp.GetLanguage, []string{".fs", ".fsi"}},
-		{gdscript.GetLanguage, []string{".gd"}},
-		{gleam.GetLanguage, []string{".gleam"}},
-		{groovy.GetLanguage, []string{".groovy"}},
-		{matlab.GetLanguage, []string{".m"}},
-		{ocaml.GetLanguage, []string{".ml", ".mli"}},
-		{pascal.GetLanguage, []string{".pas", ".pp"}},
-		{prolog.GetLanguage, []string{".pro"}},
-		{nix.GetLanguage, []string{".nix"}},
+		{"javascript", javascript.GetLanguage, []string{".js", ".mjs"}},
+		{"typescript", typescript.GetLanguage, []string{".ts", ".tsx"}},
+		{"python", python.GetLanguage, []string{".py"}},
+		{"go", golang.GetLanguage, []string{".go"}},
+		{"rust", rust.GetLanguage, []string{".rs"}},
+		{"java", java.GetLanguage, []string{".java"}},
+		{"c", c.GetLanguage, []string{".c"}},
+		{"cpp", cpp.GetLanguage, []string{".cpp", ".hpp", ".hxx", ".hh", ".cc", ".cxx"}},
+		{"csharp", c_sharp.GetLanguage, []string{".cs"}},
+		{"bash", bash.GetLanguage, []string{".sh"}},
+		{"html", html.GetLanguage, []string{".html", ".htm"}},
+		{"css", css.GetLanguage, []string{".css"}},
+		{"ruby", ruby.GetLanguage, []string{".rb"}},
+		{"php", php.GetLanguage, []string{".php", ".php3", ".phtml"}},
+		{"swift", swift.GetLanguage, []string{".swift"}},
+		{"kotlin", kotlin.GetLanguage, []string{".kt", ".kts"}},
+		{"scala", scala.GetLanguage, []string{".scala"}},
+		{"sql", sql.GetLanguage, []string{".sql"}},
+		{"lua", lua.GetLanguage, []string{".lua"}},
+		{"perl", perl.GetLanguage, []string{".pl"}},
+		{"powershell", powershell.GetLanguage, []string{".ps1"}},
+		{"dart", dart.GetLanguage, []string{".dart"}},
+		{"r", r.GetLanguage, []string{".r"}},
+		{"zig", zig.GetLanguage, []string{".zig"}},
+		{"ada", ada.GetLanguage, []string{".adb", ".ads"}},
+		{"asm", asm.GetLanguage, []string{".asm", ".s"}},
+		{"cobol", cobol.GetLanguage, []string{".cbl", ".cob"}},
+		{"commonlisp", commonlisp.GetLanguage, []string{".lisp", ".cl"}},
+		{"elixir", elixir.GetLanguage, []string{".ex", ".exs"}},
+		{"erlang", erlang.GetLanguage, []string{".erl", ".hrl"}},
+		{"fortran", fortran.GetLanguage, []string{".f", ".for", ".f90", ".f95", ".f03"}},
+		{"fsharp", fsharp.GetLanguage, []string{".fs", ".fsi"}},
+		{"gdscript", gdscript.GetLanguage, []string{".gd"}},
+		{"gleam", gleam.GetLanguage, []string{".gleam"}},
+		{"groovy", groovy.GetLanguage, []string{".groovy"}},
+		{"matlab", matlab.GetLanguage, []string{".m"}},
+		{"ocaml", ocaml.GetLanguage, []string{".ml", ".mli"}},
+		{"pascal", pascal.GetLanguage, []string{".pas", ".pp"}},
+		{"prolog", prolog.GetLanguage, []string{".pro"}},
+		{"nix", nix.GetLanguage, []string{".nix"}},
 	}
 
 	for _, lang := range supportedLangs {
+		nameToFactory[lang.name] = lang.factory
 		for _, ext := range lang.extensions {
 			langToFactory[ext] = lang.factory
+			extToName[ext] = lang.name
 		}
 	}
 }
 
+// Register maps a file extension (including the leading dot) to one of the
+// supported languages, identified by its name (e.g. "go", "python").
+// Existing mappings for the extension are replaced.
+func Register(ext string, name string) error {
+	if alias, ok := nameAliases[name]; ok {
+		name = alias
+	}
+
+	factory, exists := nameToFactory[name]
+	if !exists {
+		return fmt.Errorf("unknown language %s", name)
+	}
+
+	ext = strings.ToLower(ext)
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+
+	langToFactory[ext] = factory
+	extToName[ext] = name
+	langCacheMu.Lock()
+	delete(langCache, ext)
+	langCacheMu.Unlock()
+
+	return nil
+}
+
 func FromFilename(filename string) (*sitter.Language, error) {
 	ext := strings.ToLower(filepath.Ext(filename))
 
+	langCacheMu.Lock()
+	defer langCacheMu.Unlock()
+
 	if lang, exists := langCache[ext]; exists {
 		return lang, nil
 	}
@@ -127,3 +174,71 @@ func FromFilename(filename string) (*sitter.Language, error) {
 
 	return lang, nil
 }
+
+// NameFromFilename returns the name of the language used for the given file,
+// e.g. "go" or "python".
+func NameFromFilename(filename string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+
+	name, exists := extToName[ext]
+	if !exists {
+		return "", fmt.Errorf("no language found for file extension %s", ext)
+	}
+
+	return name, nil
+}
+
+// Language is a supported language and the file extensions mapped to it.
+type Language struct {
+	Name       string
+	Extensions []string
+}
+
+// Languages returns the supported languages ordered by name, with the
+// extensions currently mapped to each of them, including registered ones.
+func Languages() []Language {
+	extensions := make(map[string][]string)
+	for ext, name := range extToName {
+		extensions[name] = append(extensions[name], ext)
+	}
+
+	languages := make([]Language, 0, len(nameToFactory))
+	for name := range nameToFactory {
+		slices.Sort(extensions[name])
+		languages = append(languages, Language{Name: name, Extensions: extensions[name]})
+	}
+	slices.SortFunc(languages, func(a, b Language) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return languages
+}
+
+// GrammarVersion returns the version of the module providing the grammar of
+// the named language, e.g. "v1.9.4", or an empty string if it is unknown.
+func GrammarVersion(name string) string {
+	factory, exists := nameToFactory[name]
+	if !exists {
+		return ""
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+
+	// The grammar packages are the root of their modules and name their
+	// factory function GetLanguage.
+	function := runtime.FuncForPC(reflect.ValueOf(factory).Pointer()).Name()
+	path := strings.TrimSuffix(function, ".GetLanguage")
+	for _, dep := range info.Deps {
+		if dep.Path == path {
+			if dep.Replace != nil {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,99 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/andersonjoseph/searchast/jsonrpc"
+)
+
+// Error codes defined by the LSP specification, in addition to the JSON-RPC
+// ones.
+const (
+	codeServerNotInitialized = -32002
+	codeRequestFailed        = -32803
+)
+
+// maxMessageSize is the size above which a message is rejected, so that an
+// invalid Content-Length header can't exhaust the memory of the server.
+const maxMessageSize = 256 << 20
+
+// ResponseError is the error of a failed request.
+type ResponseError = jsonrpc.ResponseError
+
+// The messages are framed by headers, as defined by the LSP specification.
+// Unlike the types of the messages, shared with the MCP server through the
+// jsonrpc package, the framing stays here: the MCP server reads messages
+// delimited by newlines instead.
+
+// readMessage reads the body of the next message, framed by a Content-Length
+// header. It returns io.EOF if r ends before a message starts.
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for first := true; ; first = false {
+		header, err := r.ReadString('\n')
+		if errors.Is(err, io.EOF) && first && header == "" {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read header: %w", noEOF(err))
+		}
+
+		header = strings.TrimRight(header, "\r\n")
+		if header == "" {
+			break
+		}
+
+		name, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid header %q", header)
+		}
+		if strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil || length < 0 {
+				return nil, fmt.Errorf("invalid Content-Length %q", value)
+			}
+		}
+	}
+
+	if length < 0 {
+		return nil, errors.New("missing Content-Length header")
+	}
+	if length > maxMessageSize {
+		return nil, fmt.Errorf("message of %d bytes is larger than the limit of %d bytes", length, maxMessageSize)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("failed to read message: %w", noEOF(err))
+	}
+
+	return body, nil
+}
+
+// writeMessage writes v encoded as JSON, framed by a Content-Length header.
+func writeMessage(w io.Writer, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+
+	return nil
+}
+
+// noEOF turns io.EOF into io.ErrUnexpectedEOF, for reads in the middle of a
+// message.
+func noEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,55 @@
+package lsp
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestReadMessage(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+		fails    bool
+	}{
+		{
+			name:     "reads the body of a message",
+			input:    "Content-Length: 2\r\nContent-Type: application/vscode-jsonrpc\r\n\r\n{}",
+			expected: "{}",
+		},
+		{
+			name:  "rejects a missing Content-Length",
+			input: "Content-Type: application/vscode-jsonrpc\r\n\r\n{}",
+			fails: true,
+		},
+		{
+			name:  "rejects a Content-Length above the limit",
+			input: "Content-Length: 99999999999\r\n\r\n{}",
+			fails: true,
+		},
+		{
+			name:  "rejects a truncated body",
+			input: "Content-Length: 10\r\n\r\n{}",
+			fails: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body, err := readMessage(bufio.NewReader(strings.NewReader(tc.input)))
+			if tc.fails {
+				if err == nil {
+					t.Errorf("expected an error, got body %q", body)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to read message: %v", err)
+			}
+			if string(body) != tc.expected {
+				t.Errorf("expected body %q, got %q", tc.expected, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package lsp
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+)
+
+// character returns the character offset of the byte column of a line in
+// the position encoding of the server.
+func (s *Server) character(line string, column int) uint32 {
+	column = min(column, len(line))
+	if s.encoding == PositionEncodingUTF8 {
+		return uint32(column)
+	}
+
+	var units uint32
+	for _, r := range line[:column] {
+		units += uint32(utf16Len(r))
+	}
+
+	return units
+}
+
+// column returns the byte column of a character offset of a line. Offsets
+// past the end of the line are moved to its end.
+func (s *Server) column(line []byte, character uint32) int {
+	if s.encoding == PositionEncodingUTF8 {
+		return min(int(character), len(line))
+	}
+
+	var units uint32
+	for column := 0; column < len(line); {
+		if units >= character {
+			return column
+		}
+		r, size := utf8.DecodeRune(line[column:])
+		units += uint32(utf16Len(r))
+		column += size
+	}
+
+	return len(line)
+}
+
+// offset returns the byte offset of a position in text. Positions past the
+// end of a line are moved to its end, and positions past the last line to
+// the end of text.
+func (s *Server) offset(text []byte, pos Position) int {
+	start := 0
+	for range pos.Line {
+		i := bytes.IndexByte(text[start:], '\n')
+		if i < 0 {
+			return len(text)
+		}
+		start += i + 1
+	}
+
+	line := text[start:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+
+	return start + s.column(line, pos.Character)
+}
+
+// linesRange returns the range from the start of the first line to the end
+// of the last one.
+func (s *Server) linesRange(lines []string, first uint32, last uint32) Range {
+	return Range{
+		Start: Position{Line: first},
+		End:   Position{Line: last, Character: s.character(lines[last], len(lines[last]))},
+	}
+}
+
+// nameRange returns the range of a line without its indentation.
+func (s *Server) nameRange(lines []string, line uint32) Range {
+	text := strings.TrimRight(lines[line], " \t\r")
+	indent := len(text) - len(strings.TrimLeft(text, " \t"))
+
+	return Range{
+		Start: Position{Line: line, Character: s.character(text, indent)},
+		End:   Position{Line: line, Character: s.character(text, len(text))},
+	}
+}
+
+// utf16Len returns the number of UTF-16 code units encoding r.
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
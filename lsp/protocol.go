@@ -0,0 +1,188 @@
+package lsp
+
+// The types below are the subset of the LSP specification used by the
+// server, see https://microsoft.github.io/language-server-protocol/.
+
+// Position is a 0-based line and character offset. Characters are counted
+// in the position encoding negotiated on initialization, UTF-16 code units
+// by default.
+type Position struct {
+	Line      uint32 `json:"line"`
+	Character uint32 `json:"character"`
+}
+
+// Range is the text between two positions, the end is exclusive.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+type WorkspaceFolder struct {
+	URI  string `json:"uri"`
+	Name string `json:"name"`
+}
+
+type ClientCapabilities struct {
+	General struct {
+		PositionEncodings []string `json:"positionEncodings"`
+	} `json:"general"`
+}
+
+type InitializeParams struct {
+	RootPath         string             `json:"rootPath"`
+	RootURI          string             `json:"rootUri"`
+	WorkspaceFolders []WorkspaceFolder  `json:"workspaceFolders"`
+	Capabilities     ClientCapabilities `json:"capabilities"`
+}
+
+type InitializeResult struct {
+	Capabilities ServerCapabilities `json:"capabilities"`
+	ServerInfo   ServerInfo         `json:"serverInfo"`
+}
+
+type ServerCapabilities struct {
+	PositionEncoding        string `json:"positionEncoding"`
+	TextDocumentSync        int    `json:"textDocumentSync"`
+	DocumentSymbolProvider  bool   `json:"documentSymbolProvider"`
+	WorkspaceSymbolProvider bool   `json:"workspaceSymbolProvider"`
+	FoldingRangeProvider    bool   `json:"foldingRangeProvider"`
+}
+
+type ServerInfo struct {
+	Name string `json:"name"`
+}
+
+// Text document synchronization kinds.
+const (
+	TextDocumentSyncFull        = 1
+	TextDocumentSyncIncremental = 2
+)
+
+// Position encodings.
+const (
+	PositionEncodingUTF8  = "utf-8"
+	PositionEncodingUTF16 = "utf-16"
+)
+
+type TextDocumentItem struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Version    int    `json:"version"`
+	Text       string `json:"text"`
+}
+
+type TextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type VersionedTextDocumentIdentifier struct {
+	URI     string `json:"uri"`
+	Version int    `json:"version"`
+}
+
+// TextDocumentContentChangeEvent replaces the text of Range, or the whole
+// document if Range is nil.
+type TextDocumentContentChangeEvent struct {
+	Range *Range `json:"range,omitempty"`
+	Text  string `json:"text"`
+}
+
+type DidOpenTextDocumentParams struct {
+	TextDocument TextDocumentItem `json:"textDocument"`
+}
+
+type DidChangeTextDocumentParams struct {
+	TextDocument   VersionedTextDocumentIdentifier  `json:"textDocument"`
+	ContentChanges []TextDocumentContentChangeEvent `json:"contentChanges"`
+}
+
+type DidCloseTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type SymbolKind int
+
+// Symbol kinds used by the server.
+const (
+	SymbolKindModule    SymbolKind = 2
+	SymbolKindClass     SymbolKind = 5
+	SymbolKindMethod    SymbolKind = 6
+	SymbolKindEnum      SymbolKind = 10
+	SymbolKindInterface SymbolKind = 11
+	SymbolKindFunction  SymbolKind = 12
+	SymbolKindObject    SymbolKind = 19
+	SymbolKindStruct    SymbolKind = 23
+)
+
+type DocumentSymbolParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// DocumentSymbol is a scope of a document. Detail is the tree-sitter node
+// type of the scope.
+type DocumentSymbol struct {
+	Name           string           `json:"name"`
+	Detail         string           `json:"detail,omitempty"`
+	Kind           SymbolKind       `json:"kind"`
+	Range          Range            `json:"range"`
+	SelectionRange Range            `json:"selectionRange"`
+	Children       []DocumentSymbol `json:"children,omitempty"`
+}
+
+type WorkspaceSymbolParams struct {
+	Query string `json:"query"`
+}
+
+type SymbolInformation struct {
+	Name          string     `json:"name"`
+	Kind          SymbolKind `json:"kind"`
+	Location      Location   `json:"location"`
+	ContainerName string     `json:"containerName,omitempty"`
+}
+
+type FoldingRangeParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// Folding range kinds.
+const (
+	FoldingRangeKindComment = "comment"
+	FoldingRangeKindImports = "imports"
+)
+
+type FoldingRange struct {
+	StartLine uint32 `json:"startLine"`
+	EndLine   uint32 `json:"endLine"`
+	Kind      string `json:"kind,omitempty"`
+}
+
+// SearchParams are the parameters of the searchast/search request. Without
+// a URI, every file of the workspace is searched.
+type SearchParams struct {
+	Pattern      string `json:"pattern"`
+	URI          string `json:"uri,omitempty"`
+	FixedStrings bool   `json:"fixedStrings,omitempty"`
+	IgnoreCase   bool   `json:"ignoreCase,omitempty"`
+	WholeWord    bool   `json:"wholeWord,omitempty"`
+}
+
+// SearchResult holds the matches of a document and the lines to show them
+// with, which are the lines searchast prints for them. Lines are ordered and
+// hidden lines are left out, so a gap in the line numbers is a gap in the
+// output.
+type SearchResult struct {
+	URI     string       `json:"uri"`
+	Matches []Range      `json:"matches"`
+	Lines   []SearchLine `json:"lines"`
+}
+
+type SearchLine struct {
+	Line    uint32 `json:"line"`
+	Text    string `json:"text"`
+	IsMatch bool   `json:"isMatch"`
+}
@@ -0,0 +1,695 @@
+// Package lsp implements a language server exposing the scopes and the search
+// of searchast over the Language Server Protocol.
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/andersonjoseph/searchast"
+	"github.com/andersonjoseph/searchast/jsonrpc"
+	"github.com/andersonjoseph/searchast/language"
+	"github.com/andersonjoseph/searchast/walk"
+)
+
+// SearchMethod is the custom request searching documents, see SearchParams.
+const SearchMethod = "searchast/search"
+
+// defaultMaxFiles is the default number of workspace files kept parsed.
+const defaultMaxFiles = 5000
+
+// Server is a language server. It handles messages one at a time, in the
+// order they are received.
+type Server struct {
+	searchOpts  []searchast.SearchOption
+	contextOpts []searchast.Option
+	parseCache  *searchast.ParseCache
+	listFiles   func(root string) ([]string, error)
+	maxFiles    int
+
+	initialized bool
+	shutdown    bool
+	encoding    string
+	roots       []string
+	documents   map[string]*document
+	files       map[string]*fileEntry
+
+	// uses counts the accesses to the files, to evict the least recently
+	// used ones.
+	uses uint64
+}
+
+// document is an open document, or a file of the workspace. tree is nil for
+// files without a supported language.
+type document struct {
+	uri  string
+	text []byte
+	tree *searchast.SourceTree
+}
+
+// fileEntry is a file of the workspace parsed from disk, along with the
+// modification time and size it had before being read. Files are parsed
+// again when they change, or when they are closed in the editor. Their trees
+// are released, as they are never edited.
+type fileEntry struct {
+	doc     *document
+	modTime time.Time
+	size    int64
+
+	// lastUse is the value of the uses counter of the server when the file
+	// was last accessed.
+	lastUse uint64
+}
+
+type Option func(*Server)
+
+// NewServer returns a server, which is started with Serve.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		listFiles: func(root string) ([]string, error) { return walk.Files([]string{root}, nil) },
+		maxFiles:  defaultMaxFiles,
+		encoding:  PositionEncodingUTF16,
+		documents: make(map[string]*document),
+		files:     make(map[string]*fileEntry),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// WithSearchOptions sets the options of every search, which requests can
+// only add to.
+func WithSearchOptions(opts ...searchast.SearchOption) Option {
+	return func(s *Server) {
+		s.searchOpts = opts
+	}
+}
+
+// WithContextOptions sets the options of the context builder used to
+// select the lines shown with search matches.
+func WithContextOptions(opts ...searchast.Option) Option {
+	return func(s *Server) {
+		s.contextOpts = opts
+	}
+}
+
+// WithParseCache parses the files read from the workspace through a parse
+// cache.
+func WithParseCache(pc *searchast.ParseCache) Option {
+	return func(s *Server) {
+		s.parseCache = pc
+	}
+}
+
+// WithFileLister sets the function listing the files of a workspace folder.
+// By default, every file with a supported language is listed, except for the
+// content of .git directories.
+func WithFileLister(listFiles func(root string) ([]string, error)) Option {
+	return func(s *Server) {
+		s.listFiles = listFiles
+	}
+}
+
+// WithMaxFiles sets the number of workspace files kept parsed, above which
+// the least recently used ones are dropped. Zero disables the limit.
+func WithMaxFiles(n int) Option {
+	return func(s *Server) {
+		s.maxFiles = n
+	}
+}
+
+// Serve reads messages from r and writes responses and notifications to w
+// until the exit notification is received or r ends. It returns an error if
+// the exit notification is received before the shutdown request, as well as
+// for broken streams.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	br := bufio.NewReader(r)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		body, err := readMessage(br)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var msg jsonrpc.Message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			if err := writeMessage(w, jsonrpc.ParseError(err)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit notification received before shutdown request")
+			}
+			return nil
+		}
+
+		result, err := s.handle(ctx, &msg)
+
+		switch {
+		case msg.ID == nil && err != nil:
+			// Notifications have no response, errors are shown to the user.
+			err = writeMessage(w, jsonrpc.Notification{JSONRPC: "2.0", Method: "window/logMessage", Params: map[string]any{
+				"type":    1,
+				"message": fmt.Sprintf("%s: %v", msg.Method, err),
+			}})
+		case msg.ID == nil:
+		default:
+			err = writeMessage(w, jsonrpc.Reply(msg.ID, result, err, codeRequestFailed))
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// handle runs the handler of a message and returns its result.
+func (s *Server) handle(ctx context.Context, msg *jsonrpc.Message) (any, error) {
+	isNotification := msg.ID == nil
+
+	switch {
+	case !s.initialized && msg.Method != "initialize":
+		if isNotification {
+			return nil, nil // notifications are dropped until initialization
+		}
+		return nil, &ResponseError{Code: codeServerNotInitialized, Message: "server not initialized"}
+	case s.shutdown:
+		return nil, &ResponseError{Code: jsonrpc.CodeInvalidRequest, Message: "server is shutting down"}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		return jsonrpc.DecodeAndRun(msg.Params, func(p InitializeParams) (any, error) { return s.initialize(p) })
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		return jsonrpc.DecodeAndRun(msg.Params, func(p DidOpenTextDocumentParams) (any, error) { return nil, s.didOpen(ctx, p) })
+	case "textDocument/didChange":
+		return jsonrpc.DecodeAndRun(msg.Params, func(p DidChangeTextDocumentParams) (any, error) { return nil, s.didChange(ctx, p) })
+	case "textDocument/didClose":
+		return jsonrpc.DecodeAndRun(msg.Params, func(p DidCloseTextDocumentParams) (any, error) {
+			delete(s.documents, p.TextDocument.URI)
+			delete(s.files, p.TextDocument.URI)
+			return nil, nil
+		})
+	case "textDocument/documentSymbol":
+		return jsonrpc.DecodeAndRun(msg.Params, func(p DocumentSymbolParams) (any, error) { return s.documentSymbol(ctx, p) })
+	case "textDocument/foldingRange":
+		return jsonrpc.DecodeAndRun(msg.Params, func(p FoldingRangeParams) (any, error) { return s.foldingRange(ctx, p) })
+	case "workspace/symbol":
+		return jsonrpc.DecodeAndRun(msg.Params, func(p WorkspaceSymbolParams) (any, error) { return s.workspaceSymbol(ctx, p) })
+	case SearchMethod:
+		return jsonrpc.DecodeAndRun(msg.Params, func(p SearchParams) (any, error) { return s.search(ctx, p) })
+	}
+
+	if isNotification {
+		return nil, nil // unknown notifications, such as $/cancelRequest, are ignored
+	}
+	return nil, &ResponseError{Code: jsonrpc.CodeMethodNotFound, Message: fmt.Sprintf("method %q not found", msg.Method)}
+}
+
+func (s *Server) initialize(p InitializeParams) (any, error) {
+	if s.initialized {
+		return nil, &ResponseError{Code: jsonrpc.CodeInvalidRequest, Message: "server already initialized"}
+	}
+	s.initialized = true
+
+	if slices.Contains(p.Capabilities.General.PositionEncodings, PositionEncodingUTF8) {
+		s.encoding = PositionEncodingUTF8
+	}
+
+	switch {
+	case len(p.WorkspaceFolders) > 0:
+		for _, folder := range p.WorkspaceFolders {
+			if path, err := uriToPath(folder.URI); err == nil {
+				s.roots = append(s.roots, path)
+			}
+		}
+	case p.RootURI != "":
+		if path, err := uriToPath(p.RootURI); err == nil {
+			s.roots = append(s.roots, path)
+		}
+	case p.RootPath != "":
+		s.roots = append(s.roots, p.RootPath)
+	}
+
+	return InitializeResult{
+		Capabilities: ServerCapabilities{
+			PositionEncoding:        s.encoding,
+			TextDocumentSync:        TextDocumentSyncIncremental,
+			DocumentSymbolProvider:  true,
+			WorkspaceSymbolProvider: true,
+			FoldingRangeProvider:    true,
+		},
+		ServerInfo: ServerInfo{Name: "searchast"},
+	}, nil
+}
+
+func (s *Server) didOpen(ctx context.Context, p DidOpenTextDocumentParams) error {
+	doc, err := s.parse(ctx, p.TextDocument.URI, []byte(p.TextDocument.Text), false)
+	if err != nil {
+		return err
+	}
+	s.documents[p.TextDocument.URI] = doc
+	delete(s.files, p.TextDocument.URI)
+
+	return nil
+}
+
+// didChange applies the changes to an open document. Ranged changes update
+// the syntax tree incrementally.
+func (s *Server) didChange(ctx context.Context, p DidChangeTextDocumentParams) error {
+	doc, ok := s.documents[p.TextDocument.URI]
+	if !ok {
+		return fmt.Errorf("document %s is not open", p.TextDocument.URI)
+	}
+	delete(s.files, p.TextDocument.URI)
+
+	for _, change := range p.ContentChanges {
+		if change.Range == nil || doc.tree == nil {
+			text := []byte(change.Text)
+			if change.Range != nil {
+				text = s.applyChange(doc.text, change)
+			}
+
+			parsed, err := s.parse(ctx, doc.uri, text, false)
+			if err != nil {
+				return err
+			}
+			*doc = *parsed
+			continue
+		}
+
+		start := s.offset(doc.text, change.Range.Start)
+		end := max(start, s.offset(doc.text, change.Range.End))
+		text := s.applyChange(doc.text, change)
+
+		if err := doc.tree.Edit(uint32(start), uint32(end), uint32(start+len(change.Text)), text); err != nil {
+			return fmt.Errorf("failed to update %s: %w", doc.uri, err)
+		}
+		doc.text = text
+	}
+
+	return nil
+}
+
+// applyChange returns text with the range of change replaced.
+func (s *Server) applyChange(text []byte, change TextDocumentContentChangeEvent) []byte {
+	start := s.offset(text, change.Range.Start)
+	end := max(start, s.offset(text, change.Range.End))
+
+	changed := make([]byte, 0, len(text)-(end-start)+len(change.Text))
+	changed = append(changed, text[:start]...)
+	changed = append(changed, change.Text...)
+
+	return append(changed, text[end:]...)
+}
+
+func (s *Server) documentSymbol(ctx context.Context, p DocumentSymbolParams) (any, error) {
+	doc, err := s.document(ctx, p.TextDocument.URI)
+	if err != nil || doc.tree == nil {
+		return []DocumentSymbol{}, err
+	}
+
+	lines := doc.lines()
+
+	var convert func(symbols []searchast.Symbol) []DocumentSymbol
+	convert = func(symbols []searchast.Symbol) []DocumentSymbol {
+		converted := make([]DocumentSymbol, 0, len(symbols))
+		for _, symbol := range symbols {
+			converted = append(converted, DocumentSymbol{
+				Name:           symbol.Name,
+				Detail:         symbol.Kind,
+				Kind:           symbolKind(symbol),
+				Range:          s.linesRange(lines, symbol.StartLine, symbol.EndLine),
+				SelectionRange: s.nameRange(lines, symbol.StartLine),
+				Children:       convert(symbol.Children),
+			})
+		}
+		return converted
+	}
+
+	return convert(doc.tree.Symbols()), nil
+}
+
+func (s *Server) foldingRange(ctx context.Context, p FoldingRangeParams) (any, error) {
+	ranges := []FoldingRange{}
+
+	doc, err := s.document(ctx, p.TextDocument.URI)
+	if err != nil || doc.tree == nil {
+		return ranges, err
+	}
+
+	var add func(symbols []searchast.Symbol)
+	add = func(symbols []searchast.Symbol) {
+		for _, symbol := range symbols {
+			r := FoldingRange{StartLine: symbol.StartLine, EndLine: symbol.EndLine}
+			switch {
+			case strings.Contains(symbol.Kind, "comment"):
+				r.Kind = FoldingRangeKindComment
+			case strings.Contains(symbol.Kind, "import"):
+				r.Kind = FoldingRangeKindImports
+			}
+			ranges = append(ranges, r)
+			add(symbol.Children)
+		}
+	}
+	add(doc.tree.Symbols())
+
+	return ranges, nil
+}
+
+// workspaceSymbol returns the functions, methods and classes of the
+// workspace whose name contains the query, ignoring case.
+func (s *Server) workspaceSymbol(ctx context.Context, p WorkspaceSymbolParams) (any, error) {
+	query := strings.ToLower(p.Query)
+	symbols := []SymbolInformation{}
+
+	err := s.eachDocument(ctx, func(doc *document) {
+		lines := doc.lines()
+
+		var add func(children []searchast.Symbol, container string)
+		add = func(children []searchast.Symbol, container string) {
+			for _, symbol := range children {
+				childContainer := container
+				if symbol.Category != "" {
+					if strings.Contains(strings.ToLower(symbol.Name), query) {
+						symbols = append(symbols, SymbolInformation{
+							Name:          symbol.Name,
+							Kind:          symbolKind(symbol),
+							Location:      Location{URI: doc.uri, Range: s.linesRange(lines, symbol.StartLine, symbol.EndLine)},
+							ContainerName: container,
+						})
+					}
+					childContainer = symbol.Name
+				}
+				add(symbol.Children, childContainer)
+			}
+		}
+		add(doc.tree.Symbols(), "")
+	})
+
+	return symbols, err
+}
+
+// search implements SearchMethod.
+func (s *Server) search(ctx context.Context, p SearchParams) (any, error) {
+	if p.Pattern == "" {
+		return nil, &ResponseError{Code: jsonrpc.CodeInvalidParams, Message: "missing pattern"}
+	}
+
+	opts := slices.Clone(s.searchOpts)
+	if p.FixedStrings {
+		opts = append(opts, searchast.WithFixedStrings(true))
+	}
+	if p.IgnoreCase {
+		opts = append(opts, searchast.WithIgnoreCase(true))
+	}
+	if p.WholeWord {
+		opts = append(opts, searchast.WithWholeWord(true))
+	}
+
+	results := []SearchResult{}
+	var searchErr error
+
+	searchDocument := func(doc *document) {
+		if searchErr != nil {
+			return
+		}
+
+		matches, err := doc.tree.Matches([]string{p.Pattern}, opts...)
+		if err != nil {
+			searchErr = &ResponseError{Code: jsonrpc.CodeInvalidParams, Message: err.Error()}
+			return
+		}
+		if len(matches) == 0 {
+			return
+		}
+
+		lines := doc.lines()
+		result := SearchResult{URI: doc.uri}
+
+		matchingLines := searchast.NewSet[uint32]()
+		for _, match := range matches {
+			result.Matches = append(result.Matches, Range{
+				Start: Position{Line: match.StartLine, Character: s.character(lines[match.StartLine], match.StartColumn)},
+				End:   Position{Line: match.EndLine, Character: s.character(lines[match.EndLine], match.EndColumn)},
+			})
+
+			last := match.EndLine
+			if match.EndColumn == 0 && last > match.StartLine {
+				last-- // the match ends with a line break
+			}
+			for line := match.StartLine; line <= last; line++ {
+				matchingLines.Add(line)
+			}
+		}
+
+		linesToShow := searchast.NewContextBuilder(s.contextOpts...).AddContext(doc.tree, matchingLines).ToSlice()
+		slices.Sort(linesToShow)
+		for _, line := range linesToShow {
+			result.Lines = append(result.Lines, SearchLine{
+				Line:    line,
+				Text:    strings.TrimSuffix(lines[line], "\r"),
+				IsMatch: matchingLines.Has(line),
+			})
+		}
+
+		results = append(results, result)
+	}
+
+	if p.URI != "" {
+		doc, err := s.document(ctx, p.URI)
+		if err != nil {
+			return nil, err
+		}
+		if doc.tree != nil {
+			searchDocument(doc)
+		}
+	} else if err := s.eachDocument(ctx, searchDocument); err != nil {
+		return nil, err
+	}
+
+	if searchErr != nil {
+		return nil, searchErr
+	}
+
+	return results, nil
+}
+
+// document returns the open document of uri, or the file it refers to,
+// which is only read and parsed again if it changed since the last time.
+func (s *Server) document(ctx context.Context, uri string) (*document, error) {
+	if doc, ok := s.documents[uri]; ok {
+		return doc, nil
+	}
+
+	path, err := uriToPath(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	// The file is stat'ed before being read, so that a change made while it
+	// is read is seen by the next request.
+	info, err := os.Stat(path)
+	if err != nil {
+		delete(s.files, uri)
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if entry, ok := s.files[uri]; ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		s.uses++
+		entry.lastUse = s.uses
+		return entry.doc, nil
+	}
+
+	text, err := os.ReadFile(path)
+	if err != nil {
+		delete(s.files, uri)
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	doc, err := s.parse(ctx, uri, text, true)
+	if err != nil {
+		delete(s.files, uri)
+		return nil, err
+	}
+	if doc.tree != nil {
+		doc.tree.Release()
+	}
+	s.uses++
+	s.files[uri] = &fileEntry{doc: doc, modTime: info.ModTime(), size: info.Size(), lastUse: s.uses}
+	s.evict()
+
+	return doc, nil
+}
+
+// evict drops the least recently used files above the maximum number of
+// files.
+func (s *Server) evict() {
+	for s.maxFiles > 0 && len(s.files) > s.maxFiles {
+		var oldest string
+		var oldestUse uint64
+		for uri, entry := range s.files {
+			if oldest == "" || entry.lastUse < oldestUse {
+				oldest, oldestUse = uri, entry.lastUse
+			}
+		}
+		delete(s.files, oldest)
+	}
+}
+
+// eachDocument calls f with every document of the workspace with a supported
+// language, preferring open documents to their files. Files that can't be
+// read or parsed are skipped, and the parsed files which are no longer part
+// of the workspace are dropped.
+func (s *Server) eachDocument(ctx context.Context, f func(*document)) error {
+	seen := make(map[string]bool)
+
+	for _, root := range s.roots {
+		files, err := s.listFiles(root)
+		if err != nil {
+			return fmt.Errorf("failed to list files of %s: %w", root, err)
+		}
+
+		for _, file := range files {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			uri := pathToURI(file)
+			if seen[uri] {
+				continue
+			}
+			seen[uri] = true
+
+			doc, err := s.document(ctx, uri)
+			if err != nil || doc.tree == nil {
+				continue
+			}
+			f(doc)
+		}
+	}
+
+	for uri := range s.files {
+		if !seen[uri] {
+			delete(s.files, uri)
+		}
+	}
+
+	return nil
+}
+
+// parse returns the document of uri with the given text. The parse cache is
+// only used for files read from disk, as open documents change too often.
+func (s *Server) parse(ctx context.Context, uri string, text []byte, fromDisk bool) (*document, error) {
+	doc := &document{uri: uri, text: text}
+
+	filename := uri
+	if path, err := uriToPath(uri); err == nil {
+		filename = path
+	}
+	if _, err := language.FromFilename(filename); err != nil {
+		return doc, nil
+	}
+
+	newSourceTree := searchast.NewSourceTree
+	if s.parseCache != nil && fromDisk {
+		newSourceTree = s.parseCache.NewSourceTree
+	}
+
+	tree, err := newSourceTree(ctx, bytes.NewReader(text), filename)
+	if err != nil {
+		return nil, err
+	}
+	doc.tree = tree
+
+	return doc, nil
+}
+
+// lines returns the lines of the document, as split by searchast.
+func (d *document) lines() []string {
+	return strings.Split(string(d.text), "\n")
+}
+
+// symbolKind returns the LSP kind of a symbol.
+func symbolKind(symbol searchast.Symbol) SymbolKind {
+	switch {
+	case strings.Contains(symbol.Kind, "interface"), strings.Contains(symbol.Kind, "trait"):
+		return SymbolKindInterface
+	case strings.Contains(symbol.Kind, "struct"):
+		return SymbolKindStruct
+	case strings.Contains(symbol.Kind, "enum"):
+		return SymbolKindEnum
+	case strings.Contains(symbol.Kind, "namespace"), strings.Contains(symbol.Kind, "module"):
+		return SymbolKindModule
+	}
+
+	switch symbol.Category {
+	case searchast.ScopeKindFunction:
+		return SymbolKindFunction
+	case searchast.ScopeKindMethod:
+		return SymbolKindMethod
+	case searchast.ScopeKindClass:
+		return SymbolKindClass
+	default:
+		return SymbolKindObject
+	}
+}
+
+// uriToPath returns the path of a file URI.
+func uriToPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", fmt.Errorf("invalid URI %q: %w", uri, err)
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported URI %q: not a file URI", uri)
+	}
+
+	path := u.Path
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:] // Windows drive letter
+	}
+
+	return filepath.FromSlash(path), nil
+}
+
+// pathToURI returns the file URI of a path.
+func pathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
@@ -0,0 +1,546 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andersonjoseph/searchast/jsonrpc"
+)
+
+// testClient is an LSP client talking to a server running in the test.
+type testClient struct {
+	t      *testing.T
+	r      *bufio.Reader
+	w      io.Writer
+	nextID int
+	// served receives the result of Serve.
+	served chan error
+}
+
+func newTestClient(t *testing.T, opts ...Option) *testClient {
+	t.Helper()
+
+	serverR, clientW := io.Pipe()
+	clientR, serverW := io.Pipe()
+
+	c := &testClient{t: t, r: bufio.NewReader(clientR), w: clientW, served: make(chan error, 1)}
+	go func() {
+		err := NewServer(opts...).Serve(t.Context(), serverR, serverW)
+		serverW.Close()
+		c.served <- err
+	}()
+
+	t.Cleanup(func() {
+		clientW.Close()
+		clientR.Close()
+	})
+
+	return c
+}
+
+// initialize initializes the server with a workspace folder.
+func (c *testClient) initialize(root string) InitializeResult {
+	c.t.Helper()
+
+	var result InitializeResult
+	if err := c.call("initialize", InitializeParams{RootURI: pathToURI(root)}, &result); err != nil {
+		c.t.Fatalf("failed to initialize: %v", err)
+	}
+	c.notify("initialized", struct{}{})
+
+	return result
+}
+
+// call sends a request and decodes its result. Notifications received
+// before the response are skipped.
+func (c *testClient) call(method string, params any, result any) *ResponseError {
+	c.t.Helper()
+
+	c.nextID++
+	id, _ := json.Marshal(c.nextID)
+	paramsJSON, _ := json.Marshal(params)
+	if err := writeMessage(c.w, jsonrpc.Message{JSONRPC: "2.0", ID: id, Method: method, Params: paramsJSON}); err != nil {
+		c.t.Fatalf("failed to send %s: %v", method, err)
+	}
+
+	for {
+		body, err := readMessage(c.r)
+		if err != nil {
+			c.t.Fatalf("failed to read response to %s: %v", method, err)
+		}
+
+		var msg jsonrpc.Message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			c.t.Fatalf("failed to decode response to %s: %v", method, err)
+		}
+		if msg.ID == nil {
+			continue
+		}
+		if string(msg.ID) != string(id) {
+			c.t.Fatalf("expected response %s, got %s", id, msg.ID)
+		}
+
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("failed to decode result of %s: %v", method, err)
+			}
+		}
+		return nil
+	}
+}
+
+// notify sends a notification.
+func (c *testClient) notify(method string, params any) {
+	c.t.Helper()
+
+	paramsJSON, _ := json.Marshal(params)
+	if err := writeMessage(c.w, jsonrpc.Message{JSONRPC: "2.0", Method: method, Params: paramsJSON}); err != nil {
+		c.t.Fatalf("failed to send %s: %v", method, err)
+	}
+}
+
+// open opens a document with the given text.
+func (c *testClient) open(uri string, text string) {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: uri, LanguageID: "go", Version: 1, Text: text}})
+}
+
+const testSource = `package main
+
+import (
+	"fmt"
+)
+
+type greeter struct {
+	name string
+}
+
+// greet prints a greeting, "héllo 🌍".
+func (g greeter) greet() {
+	if g.name != "" {
+		fmt.Println("héllo 🌍", g.name)
+	}
+}
+
+func main() {
+	greeter{name: "world"}.greet()
+}
+`
+
+func TestServer_Lifecycle(t *testing.T) {
+	t.Run("initializes and shuts down", func(t *testing.T) {
+		c := newTestClient(t)
+
+		if err := c.call("workspace/symbol", WorkspaceSymbolParams{}, nil); err == nil || err.Code != codeServerNotInitialized {
+			t.Errorf("expected a server not initialized error, got %v", err)
+		}
+
+		result := c.initialize(t.TempDir())
+		expected := ServerCapabilities{
+			PositionEncoding:        PositionEncodingUTF16,
+			TextDocumentSync:        TextDocumentSyncIncremental,
+			DocumentSymbolProvider:  true,
+			WorkspaceSymbolProvider: true,
+			FoldingRangeProvider:    true,
+		}
+		if result.Capabilities != expected {
+			t.Errorf("expected capabilities %+v, got %+v", expected, result.Capabilities)
+		}
+
+		if err := c.call("textDocument/hover", struct{}{}, nil); err == nil || err.Code != jsonrpc.CodeMethodNotFound {
+			t.Errorf("expected a method not found error, got %v", err)
+		}
+
+		if err := c.call("shutdown", nil, nil); err != nil {
+			t.Fatalf("failed to shut down: %v", err)
+		}
+		c.notify("exit", nil)
+		if err := <-c.served; err != nil {
+			t.Errorf("expected Serve to succeed, got %v", err)
+		}
+	})
+
+	t.Run("fails on exit without shutdown", func(t *testing.T) {
+		c := newTestClient(t)
+		c.initialize(t.TempDir())
+
+		c.notify("exit", nil)
+		if err := <-c.served; err == nil {
+			t.Error("expected an error, but got none")
+		}
+	})
+
+	t.Run("negotiates the position encoding", func(t *testing.T) {
+		c := newTestClient(t)
+
+		var params InitializeParams
+		params.Capabilities.General.PositionEncodings = []string{PositionEncodingUTF8, PositionEncodingUTF16}
+
+		var result InitializeResult
+		if err := c.call("initialize", params, &result); err != nil {
+			t.Fatalf("failed to initialize: %v", err)
+		}
+		if result.Capabilities.PositionEncoding != PositionEncodingUTF8 {
+			t.Errorf("expected %s, got %s", PositionEncodingUTF8, result.Capabilities.PositionEncoding)
+		}
+	})
+}
+
+func TestServer_DocumentSymbol(t *testing.T) {
+	c := newTestClient(t)
+	c.initialize(t.TempDir())
+	c.open("file:///test.go", testSource)
+
+	var symbols []DocumentSymbol
+	if err := c.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: "file:///test.go"}}, &symbols); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	lineRange := func(first, firstCharacter, last, lastCharacter uint32) Range {
+		return Range{Start: Position{Line: first, Character: firstCharacter}, End: Position{Line: last, Character: lastCharacter}}
+	}
+	expected := []DocumentSymbol{
+		{Name: "import", Detail: "import_declaration", Kind: SymbolKindObject, Range: lineRange(2, 0, 4, 1), SelectionRange: lineRange(2, 0, 2, 8)},
+		{Name: "type greeter struct", Detail: "type_declaration", Kind: SymbolKindClass, Range: lineRange(6, 0, 8, 1), SelectionRange: lineRange(6, 0, 6, 21)},
+		{Name: "func (g greeter) greet()", Detail: "method_declaration", Kind: SymbolKindMethod, Range: lineRange(11, 0, 15, 1), SelectionRange: lineRange(11, 0, 11, 26), Children: []DocumentSymbol{
+			{Name: "if g.name != \"\"", Detail: "if_statement", Kind: SymbolKindObject, Range: lineRange(12, 0, 14, 2), SelectionRange: lineRange(12, 1, 12, 18)},
+		}},
+		{Name: "func main()", Detail: "function_declaration", Kind: SymbolKindFunction, Range: lineRange(17, 0, 19, 1), SelectionRange: lineRange(17, 0, 17, 13)},
+	}
+	if !reflect.DeepEqual(symbols, expected) {
+		t.Errorf("expected %+v, got %+v", expected, symbols)
+	}
+}
+
+func TestServer_FoldingRange(t *testing.T) {
+	c := newTestClient(t)
+	c.initialize(t.TempDir())
+	c.open("file:///test.go", testSource)
+
+	var ranges []FoldingRange
+	if err := c.call("textDocument/foldingRange", FoldingRangeParams{TextDocument: TextDocumentIdentifier{URI: "file:///test.go"}}, &ranges); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	expected := []FoldingRange{
+		{StartLine: 2, EndLine: 4, Kind: FoldingRangeKindImports},
+		{StartLine: 6, EndLine: 8},
+		{StartLine: 11, EndLine: 15},
+		{StartLine: 12, EndLine: 14},
+		{StartLine: 17, EndLine: 19},
+	}
+	if !reflect.DeepEqual(ranges, expected) {
+		t.Errorf("expected %+v, got %+v", expected, ranges)
+	}
+}
+
+func TestServer_DidChange(t *testing.T) {
+	testCases := []struct {
+		name    string
+		changes []TextDocumentContentChangeEvent
+	}{
+		{
+			name: "applies ranged changes after multi-byte characters",
+			changes: []TextDocumentContentChangeEvent{
+				// replaces g.name after "héllo 🌍", which is 8 UTF-16 code units
+				{Range: &Range{Start: Position{Line: 13, Character: 26}, End: Position{Line: 13, Character: 32}}, Text: "\"!\")\n\t\tfmt.Println(g.name"},
+				{Range: &Range{Start: Position{Line: 6, Character: 0}, End: Position{Line: 9, Character: 0}}, Text: ""},
+			},
+		},
+		{
+			name: "replaces the whole document",
+			changes: []TextDocumentContentChangeEvent{
+				{Text: strings.Replace(testSource, "func main() {\n\tgreeter{name: \"world\"}.greet()\n}\n", "", 1)},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestClient(t)
+			c.initialize(t.TempDir())
+			c.open("file:///test.go", testSource)
+
+			c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+				TextDocument:   VersionedTextDocumentIdentifier{URI: "file:///test.go", Version: 2},
+				ContentChanges: tc.changes,
+			})
+
+			// the server applies the changes to its copy of the document
+			s := NewServer()
+			text := []byte(testSource)
+			for _, change := range tc.changes {
+				if change.Range == nil {
+					text = []byte(change.Text)
+				} else {
+					text = s.applyChange(text, change)
+				}
+			}
+			c.open("file:///expected.go", string(text))
+
+			var symbols, expected []DocumentSymbol
+			if err := c.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: "file:///test.go"}}, &symbols); err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if err := c.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: "file:///expected.go"}}, &expected); err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if !reflect.DeepEqual(symbols, expected) {
+				t.Errorf("expected %+v, got %+v", expected, symbols)
+			}
+		})
+	}
+
+	t.Run("counts characters in UTF-16 code units", func(t *testing.T) {
+		s := NewServer()
+		change := TextDocumentContentChangeEvent{Range: &Range{Start: Position{Line: 13, Character: 26}, End: Position{Line: 13, Character: 32}}, Text: "name"}
+
+		text := string(s.applyChange([]byte(testSource), change))
+		if !strings.Contains(text, "fmt.Println(\"héllo 🌍\", name)") {
+			t.Errorf("expected g.name to be replaced, got:\n%s", text)
+		}
+	})
+}
+
+func TestServer_WorkspaceSymbol(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"main.go":           testSource,
+		"web/greet.js":      "// Greeter greets.\nclass Greeter {\n  greet() {\n    console.log('hello');\n  }\n}\n",
+		"notes.txt":         "func greet() {\n}\n",
+		".git/hooks/pre.go": "package hooks\n\nfunc greet() {\n}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := newTestClient(t)
+	c.initialize(root)
+
+	testCases := []struct {
+		name     string
+		query    string
+		expected []SymbolInformation
+	}{
+		{
+			name:  "finds matching functions, methods and classes",
+			query: "GREET",
+			expected: []SymbolInformation{
+				{Name: "type greeter struct", Kind: SymbolKindClass, Location: Location{URI: pathToURI(filepath.Join(root, "main.go")), Range: Range{Start: Position{Line: 6}, End: Position{Line: 8, Character: 1}}}},
+				{Name: "func (g greeter) greet()", Kind: SymbolKindMethod, Location: Location{URI: pathToURI(filepath.Join(root, "main.go")), Range: Range{Start: Position{Line: 11}, End: Position{Line: 15, Character: 1}}}},
+				{Name: "class Greeter", Kind: SymbolKindClass, Location: Location{URI: pathToURI(filepath.Join(root, "web/greet.js")), Range: Range{Start: Position{Line: 1}, End: Position{Line: 5, Character: 1}}}},
+				{Name: "greet()", Kind: SymbolKindMethod, Location: Location{URI: pathToURI(filepath.Join(root, "web/greet.js")), Range: Range{Start: Position{Line: 2}, End: Position{Line: 4, Character: 3}}}, ContainerName: "class Greeter"},
+			},
+		},
+		{
+			name:     "returns no symbols without matches",
+			query:    "missing",
+			expected: []SymbolInformation{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var symbols []SymbolInformation
+			if err := c.call("workspace/symbol", WorkspaceSymbolParams{Query: tc.query}, &symbols); err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if !reflect.DeepEqual(symbols, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, symbols)
+			}
+		})
+	}
+}
+
+func TestServer_WorkspaceFiles(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "main.go")
+	write := func(content string, modTime time.Time) {
+		t.Helper()
+
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := newTestClient(t)
+	symbols := func() []string {
+		t.Helper()
+
+		var symbols []SymbolInformation
+		if err := c.call("workspace/symbol", WorkspaceSymbolParams{Query: ""}, &symbols); err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+
+		names := []string{}
+		for _, symbol := range symbols {
+			names = append(names, symbol.Name)
+		}
+		return names
+	}
+
+	modTime := time.Now().Add(-time.Hour)
+	write("package main\n\nfunc one() {\n}\n", modTime)
+	c.initialize(root)
+
+	if got := symbols(); !reflect.DeepEqual(got, []string{"func one()"}) {
+		t.Fatalf("expected the symbols of main.go, got %v", got)
+	}
+
+	// Files are only parsed again when their modification time or size
+	// change.
+	write("package main\n\nfunc two() {\n}\n", modTime)
+	if got := symbols(); !reflect.DeepEqual(got, []string{"func one()"}) {
+		t.Errorf("expected the parsed file to be reused, got %v", got)
+	}
+
+	write("package main\n\nfunc two() {\n}\n", modTime.Add(time.Minute))
+	if got := symbols(); !reflect.DeepEqual(got, []string{"func two()"}) {
+		t.Errorf("expected the modified file to be parsed again, got %v", got)
+	}
+
+	// Closing a document drops its file, which may have been saved.
+	uri := pathToURI(path)
+	c.open(uri, "package main\n\nfunc three() {\n}\n")
+	write("package main\n\nfunc four() {\n}\n", modTime.Add(time.Minute))
+	if got := symbols(); !reflect.DeepEqual(got, []string{"func three()"}) {
+		t.Errorf("expected the open document to be used, got %v", got)
+	}
+	c.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}})
+	if got := symbols(); !reflect.DeepEqual(got, []string{"func four()"}) {
+		t.Errorf("expected the closed document to be read again, got %v", got)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := symbols(); !reflect.DeepEqual(got, []string{}) {
+		t.Errorf("expected no symbols once main.go is removed, got %v", got)
+	}
+}
+
+func TestServer_MaxFiles(t *testing.T) {
+	root := t.TempDir()
+	uris := make(map[string]string)
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		path := filepath.Join(root, name)
+		if err := os.WriteFile(path, []byte("package main\n\nfunc main() {\n}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		uris[name] = pathToURI(path)
+	}
+	s := NewServer(WithMaxFiles(2))
+
+	// b.go is the least recently used file when c.go is parsed.
+	for _, name := range []string{"a.go", "b.go", "a.go", "c.go"} {
+		doc, err := s.document(t.Context(), uris[name])
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		if err := doc.tree.Edit(0, 0, 0, doc.text); err == nil {
+			t.Errorf("expected the tree of %s to be released", name)
+		}
+	}
+
+	for name, expected := range map[string]bool{"a.go": true, "b.go": false, "c.go": true} {
+		if _, ok := s.files[uris[name]]; ok != expected {
+			t.Errorf("expected %s to be kept parsed: %v, got %v", name, expected, ok)
+		}
+	}
+}
+
+func TestServer_Search(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte(testSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	uri := pathToURI(filepath.Join(root, "main.go"))
+
+	c := newTestClient(t)
+	c.initialize(root)
+
+	matchLine := func(line uint32) SearchLine {
+		return SearchLine{Line: line, Text: strings.Split(testSource, "\n")[line], IsMatch: true}
+	}
+	contextLine := func(line uint32) SearchLine {
+		return SearchLine{Line: line, Text: strings.Split(testSource, "\n")[line]}
+	}
+
+	testCases := []struct {
+		name   string
+		params SearchParams
+		// open is the text of the document opened before searching, if any.
+		open     string
+		expected []SearchResult
+	}{
+		{
+			name:   "returns matches with their context",
+			params: SearchParams{Pattern: "g.name\\)", URI: uri},
+			expected: []SearchResult{{
+				URI:     uri,
+				Matches: []Range{{Start: Position{Line: 13, Character: 26}, End: Position{Line: 13, Character: 33}}},
+				Lines:   []SearchLine{contextLine(10), contextLine(11), contextLine(12), matchLine(13), contextLine(14), contextLine(15), contextLine(16)},
+			}},
+		},
+		{
+			name:   "searches the workspace",
+			params: SearchParams{Pattern: "WORLD", IgnoreCase: true},
+			expected: []SearchResult{{
+				URI:     uri,
+				Matches: []Range{{Start: Position{Line: 18, Character: 16}, End: Position{Line: 18, Character: 21}}},
+				Lines:   []SearchLine{contextLine(11), contextLine(12), contextLine(13), contextLine(14), contextLine(15), contextLine(16), contextLine(17), matchLine(18), contextLine(19), contextLine(20)},
+			}},
+		},
+		{
+			name:   "searches open documents instead of their file",
+			params: SearchParams{Pattern: "unsaved", FixedStrings: true},
+			open:   "// unsaved\npackage main\n",
+			expected: []SearchResult{{
+				URI:     uri,
+				Matches: []Range{{Start: Position{Line: 0, Character: 3}, End: Position{Line: 0, Character: 10}}},
+				Lines:   []SearchLine{{Line: 0, Text: "// unsaved", IsMatch: true}, {Line: 1, Text: "package main"}, {Line: 2, Text: ""}},
+			}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.open != "" {
+				c.open(uri, tc.open)
+				defer c.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}})
+			}
+
+			var results []SearchResult
+			if err := c.call(SearchMethod, tc.params, &results); err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if !reflect.DeepEqual(results, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, results)
+			}
+		})
+	}
+
+	t.Run("returns an error for invalid patterns", func(t *testing.T) {
+		if err := c.call(SearchMethod, SearchParams{Pattern: "("}, nil); err == nil || err.Code != jsonrpc.CodeInvalidParams {
+			t.Errorf("expected an invalid params error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package searchast
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MarkdownFormatter renders the lines to show as a Markdown section: a heading
+// with the file path followed by a fenced code block tagged with the language.
+// Matched lines are marked with a prefix inside the code block, so the marks
+// survive Markdown rendering.
+type MarkdownFormatter struct {
+	filename        string
+	language        string
+	lineNumbers     bool
+	highlightSymbol string
+	gapSymbol       string
+}
+
+type MarkdownFormatterOption func(*MarkdownFormatter)
+
+// NewMarkdownFormatter creates a formatter for the given file. language is used
+// as the info string of the code block and may be empty.
+func NewMarkdownFormatter(filename string, language string, opts ...MarkdownFormatterOption) *MarkdownFormatter {
+	formatter := &MarkdownFormatter{
+		filename:        filename,
+		language:        language,
+		lineNumbers:     true,
+		highlightSymbol: ">",
+		gapSymbol:       "⋮",
+	}
+
+	for _, opt := range opts {
+		opt(formatter)
+	}
+
+	return formatter
+}
+
+func WithMarkdownLineNumbers(lineNumbers bool) MarkdownFormatterOption {
+	return func(mf *MarkdownFormatter) {
+		mf.lineNumbers = lineNumbers
+	}
+}
+
+func WithMarkdownHighlightSymbol(symbol string) MarkdownFormatterOption {
+	return func(mf *MarkdownFormatter) {
+		mf.highlightSymbol = symbol
+	}
+}
+
+func WithMarkdownGapSymbol(symbol string) MarkdownFormatterOption {
+	return func(mf *MarkdownFormatter) {
+		mf.gapSymbol = symbol
+	}
+}
+
+func (mf *MarkdownFormatter) Format(lines []line, linesToShow Set[lineNumber], linesToHighlight Set[lineNumber]) string {
+	output := strings.Builder{}
+	_ = mf.FormatTo(&output, lines, linesToShow, linesToHighlight) // writing to a strings.Builder never fails
+
+	return output.String()
+}
+
+// FormatTo writes the Markdown section to w as it is produced.
+func (mf *MarkdownFormatter) FormatTo(w io.Writer, lines []line, linesToShow Set[lineNumber], linesToHighlight Set[lineNumber]) error {
+	if len(linesToShow) == 0 || len(linesToHighlight) == 0 {
+		return nil
+	}
+
+	fence := codeFence(lines, linesToShow)
+	if _, err := fmt.Fprintf(w, "### %s\n\n%s%s\n", codeSpan(mf.filename), fence, mf.language); err != nil {
+		return err
+	}
+
+	lineNumberWidth := maxLineNumber(linesToShow)
+	markerWidth := len([]rune(mf.highlightSymbol))
+	gapIndent := markerWidth + 1
+	if mf.lineNumbers {
+		gapIndent += lineNumberWidth
+	}
+	isGapPrinted := false
+
+	for i, line := range lines {
+		if !linesToShow.Has(lineNumber(i)) {
+			if !isGapPrinted {
+				if _, err := fmt.Fprintf(w, "%*s%s\n", gapIndent, "", mf.gapSymbol); err != nil {
+					return err
+				}
+				isGapPrinted = true
+			}
+
+			continue
+		}
+
+		isGapPrinted = false
+		marker := strings.Repeat(" ", markerWidth)
+		if linesToHighlight.Has(lineNumber(i)) {
+			marker = mf.highlightSymbol
+		}
+
+		var err error
+		if mf.lineNumbers {
+			_, err = fmt.Fprintf(w, "%s %*d | %s\n", marker, lineNumberWidth, i+1, line.text)
+		} else {
+			_, err = fmt.Fprintf(w, "%s %s\n", marker, line.text)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "%s\n\n", fence)
+	return err
+}
+
+// codeFence returns a backtick fence longer than any backtick run in the
+// lines to show, so the code block can't be closed early.
+func codeFence(lines []line, linesToShow Set[lineNumber]) string {
+	longest := 0
+	for line := range linesToShow {
+		if int(line) < len(lines) {
+			longest = max(longest, longestBacktickRun(lines[line].text))
+		}
+	}
+
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// codeSpan returns text as an inline code span, delimited by backticks longer
+// than any backtick run in text. Text starting or ending with a backtick, or
+// with spaces on both sides, is padded with a space on each side, which
+// renderers strip, so that it is shown as is.
+func codeSpan(text string) string {
+	delimiter := strings.Repeat("`", longestBacktickRun(text)+1)
+	isSpaced := len(text) > 1 && text[0] == ' ' && text[len(text)-1] == ' ' && strings.Trim(text, " ") != ""
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") || isSpaced {
+		text = " " + text + " "
+	}
+
+	return delimiter + text + delimiter
+}
+
+// longestBacktickRun returns the length of the longest run of backticks in text.
+func longestBacktickRun(text string) int {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r != '`' {
+			run = 0
+			continue
+		}
+		run++
+		longest = max(longest, run)
+	}
+
+	return longest
+}
@@ -0,0 +1,90 @@
+package searchast
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdownFormatter_Format(t *testing.T) {
+	source := `package main
+
+func main() {
+	fmt.Println("hello")
+}`
+
+	st := mustNewSourceTree(t, source)
+	linesOfInterest, err := st.Search("Println")
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	linesToShow := NewContextBuilder(WithSurroundingLines(0)).AddContext(st, linesOfInterest)
+
+	t.Run("renders a heading and a tagged code block", func(t *testing.T) {
+		output := NewMarkdownFormatter("main.go", "go").Format(st.Lines(), linesToShow, linesOfInterest)
+
+		expected := "### `main.go`\n\n```go\n" +
+			"   ⋮\n" +
+			"  3 | func main() {\n" +
+			"> 4 | \tfmt.Println(\"hello\")\n" +
+			"  5 | }\n" +
+			"```\n\n"
+		if output != expected {
+			t.Errorf("expected output:\n%q\ngot:\n%q", expected, output)
+		}
+	})
+
+	t.Run("omits line numbers when disabled", func(t *testing.T) {
+		formatter := NewMarkdownFormatter("main.go", "go", WithMarkdownLineNumbers(false))
+		output := formatter.Format(st.Lines(), linesToShow, linesOfInterest)
+
+		if !strings.Contains(output, "> \tfmt.Println(\"hello\")\n") {
+			t.Errorf("expected matched line without line number, got:\n%s", output)
+		}
+		if !strings.Contains(output, "```go\n  ⋮\n") {
+			t.Errorf("expected gap without line number padding, got:\n%s", output)
+		}
+	})
+
+	t.Run("pads gaps to the line number width", func(t *testing.T) {
+		st := mustNewSourceTree(t, strings.Repeat("\n", 9)+source)
+		linesOfInterest, err := st.Search("Println")
+		if err != nil {
+			t.Fatalf("failed to search: %v", err)
+		}
+		linesToShow := NewContextBuilder(WithSurroundingLines(0)).AddContext(st, linesOfInterest)
+		output := NewMarkdownFormatter("main.go", "go").Format(st.Lines(), linesToShow, linesOfInterest)
+
+		if !strings.Contains(output, "```go\n    ⋮\n  10 | package main\n") {
+			t.Errorf("expected gap padded to two digits, got:\n%s", output)
+		}
+	})
+
+	t.Run("uses longer delimiters for file names with backticks", func(t *testing.T) {
+		testCases := []struct {
+			filename string
+			expected string
+		}{
+			{"main.go", "### `main.go`\n"},
+			{"a`b.go", "### ``a`b.go``\n"},
+			{"``main.go", "### ``` ``main.go ```\n"},
+			{" main.go ", "### `  main.go  `\n"},
+		}
+
+		for _, tc := range testCases {
+			output := NewMarkdownFormatter(tc.filename, "go").Format(st.Lines(), linesToShow, linesOfInterest)
+			if !strings.HasPrefix(output, tc.expected) {
+				t.Errorf("expected heading %q for %q, got:\n%s", tc.expected, tc.filename, output)
+			}
+		}
+	})
+
+	t.Run("uses a longer fence when the code contains backticks", func(t *testing.T) {
+		st := mustNewSourceTree(t, "package main\n\nvar s = ```\n")
+		lines := NewSetFromSlice([]lineNumber{2})
+		output := NewMarkdownFormatter("main.go", "go").Format(st.Lines(), lines, lines)
+
+		if !strings.Contains(output, "````go\n") || !strings.HasSuffix(output, "````\n\n") {
+			t.Errorf("expected a four backtick fence, got:\n%s", output)
+		}
+	})
+}
@@ -0,0 +1,176 @@
+// Package mcp implements a Model Context Protocol server exposing the search
+// and overview of searchast as tools, for coding agents.
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/andersonjoseph/searchast"
+	"github.com/andersonjoseph/searchast/jsonrpc"
+	"github.com/andersonjoseph/searchast/walk"
+)
+
+// protocolVersions are the supported versions of the protocol, the last one
+// being the preferred one.
+var protocolVersions = []string{"2024-11-05", "2025-03-26", "2025-06-18"}
+
+// ResponseError is the error of a failed request. Failures of a tool are
+// reported in its result instead, see CallToolResult.
+type ResponseError = jsonrpc.ResponseError
+
+// Server is an MCP server. It handles messages one at a time, in the order
+// they are received.
+type Server struct {
+	searchOpts  []searchast.SearchOption
+	contextOpts []searchast.Option
+	parseCache  *searchast.ParseCache
+	listFiles   func(paths []string) ([]string, error)
+}
+
+type Option func(*Server)
+
+// NewServer returns a server, which is started with Serve.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		listFiles: func(paths []string) ([]string, error) { return walk.Files(paths, nil) },
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// WithSearchOptions sets the options of every search, which tool calls can
+// only add to.
+func WithSearchOptions(opts ...searchast.SearchOption) Option {
+	return func(s *Server) {
+		s.searchOpts = opts
+	}
+}
+
+// WithContextOptions sets the default options of the context builder used
+// to select the lines shown with search matches.
+func WithContextOptions(opts ...searchast.Option) Option {
+	return func(s *Server) {
+		s.contextOpts = opts
+	}
+}
+
+// WithParseCache parses files through a parse cache.
+func WithParseCache(pc *searchast.ParseCache) Option {
+	return func(s *Server) {
+		s.parseCache = pc
+	}
+}
+
+// WithFileLister sets the function expanding the paths of a search into the
+// files to search. By default, directories are walked for files with a
+// supported language, skipping .git directories.
+func WithFileLister(listFiles func(paths []string) ([]string, error)) Option {
+	return func(s *Server) {
+		s.listFiles = listFiles
+	}
+}
+
+// Serve reads messages from r, one JSON-RPC message per line, and writes the
+// responses to w until r ends.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	br := bufio.NewReader(r)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		line, err := br.ReadBytes('\n')
+		if errors.Is(err, io.EOF) && len(bytes.TrimSpace(line)) == 0 {
+			return nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read message: %w", err)
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var msg jsonrpc.Message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			if err := writeMessage(w, jsonrpc.ParseError(err)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if msg.ID == nil || msg.Method == "" {
+			continue // notifications and responses need no response
+		}
+
+		result, err := s.handle(ctx, &msg)
+		if err := writeMessage(w, jsonrpc.Reply(msg.ID, result, err, jsonrpc.CodeInvalidParams)); err != nil {
+			return err
+		}
+	}
+}
+
+// handle runs the handler of a request and returns its result.
+func (s *Server) handle(ctx context.Context, msg *jsonrpc.Message) (any, error) {
+	switch msg.Method {
+	case "initialize":
+		var p struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		if err := jsonrpc.DecodeParams(msg.Params, &p); err != nil {
+			return nil, err
+		}
+
+		version := protocolVersions[len(protocolVersions)-1]
+		if slices.Contains(protocolVersions, p.ProtocolVersion) {
+			version = p.ProtocolVersion
+		}
+
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "searchast"},
+		}, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return map[string]any{"tools": tools}, nil
+	case "tools/call":
+		var p struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := jsonrpc.DecodeParams(msg.Params, &p); err != nil {
+			return nil, err
+		}
+
+		return s.callTool(ctx, p.Name, p.Arguments)
+	}
+
+	return nil, &ResponseError{Code: jsonrpc.CodeMethodNotFound, Message: fmt.Sprintf("method %q not found", msg.Method)}
+}
+
+// writeMessage writes v encoded as JSON on a line.
+func writeMessage(w io.Writer, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	if _, err := w.Write(append(body, '\n')); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,351 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/andersonjoseph/searchast/jsonrpc"
+)
+
+// runScript serves the given messages, one per line as a client writes them
+// on stdin, and returns the responses by ID.
+func runScript(t *testing.T, s *Server, messages ...string) map[string]jsonrpc.Message {
+	t.Helper()
+
+	var output bytes.Buffer
+	if err := s.Serve(t.Context(), strings.NewReader(strings.Join(messages, "\n")+"\n"), &output); err != nil {
+		t.Fatalf("failed to serve: %v", err)
+	}
+
+	responses := make(map[string]jsonrpc.Message)
+	for line := range strings.Lines(output.String()) {
+		var msg jsonrpc.Message
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			t.Fatalf("failed to decode response %q: %v", line, err)
+		}
+		responses[string(msg.ID)] = msg
+	}
+
+	return responses
+}
+
+// callTool returns the result of a tool call.
+func callTool(t *testing.T, s *Server, name string, arguments string) CallToolResult {
+	t.Helper()
+
+	responses := runScript(t, s,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"`+name+`","arguments":`+arguments+`}}`,
+	)
+
+	response, ok := responses["2"]
+	if !ok {
+		t.Fatalf("expected a response to the tool call, got %v", responses)
+	}
+	if response.Error != nil {
+		t.Fatalf("tool call failed: %v", response.Error)
+	}
+
+	var result CallToolResult
+	if err := json.Unmarshal(response.Result, &result); err != nil {
+		t.Fatalf("failed to decode result: %v", err)
+	}
+	if len(result.Content) != 1 || result.Content[0].Type != "text" {
+		t.Fatalf("expected a single text content, got %+v", result.Content)
+	}
+
+	return result
+}
+
+// writeFiles writes files relative to the current directory.
+func writeFiles(t *testing.T, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+const greetSource = `package main
+
+import "fmt"
+
+func greet(name string) {
+	if name == "" {
+		name = "world"
+	}
+	fmt.Println("hello", name)
+}
+
+func main() {
+	greet("")
+}
+`
+
+func TestServer_Protocol(t *testing.T) {
+	responses := runScript(t, NewServer(),
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		``,
+		`{"jsonrpc":"2.0","id":2,"method":"ping"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":4,"method":"resources/list"}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"missing"}}`,
+		`{"jsonrpc":"2.0","id":6,"method":"initialize","params":{"protocolVersion":"1999-01-01"}}`,
+		`not json`,
+	)
+
+	if len(responses) != 7 {
+		t.Fatalf("expected 7 responses, got %d: %v", len(responses), responses)
+	}
+
+	var initialized struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		Capabilities    struct {
+			Tools *struct{} `json:"tools"`
+		} `json:"capabilities"`
+	}
+	if err := json.Unmarshal(responses["1"].Result, &initialized); err != nil {
+		t.Fatal(err)
+	}
+	if initialized.ProtocolVersion != "2025-03-26" || initialized.Capabilities.Tools == nil {
+		t.Errorf("expected the requested version and the tools capability, got %+v", initialized)
+	}
+
+	if string(responses["2"].Result) != "{}" {
+		t.Errorf("expected an empty ping result, got %s", responses["2"].Result)
+	}
+
+	var listed struct {
+		Tools []tool `json:"tools"`
+	}
+	if err := json.Unmarshal(responses["3"].Result, &listed); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tool := range listed.Tools {
+		names = append(names, tool.Name)
+	}
+	if expected := []string{"search", "overview", "list_languages"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected tools %v, got %v", expected, names)
+	}
+
+	for id, code := range map[string]int{"4": jsonrpc.CodeMethodNotFound, "5": jsonrpc.CodeInvalidParams, "null": jsonrpc.CodeParseError} {
+		if err := responses[id].Error; err == nil || err.Code != code {
+			t.Errorf("expected response %s to fail with code %d, got %v", id, code, err)
+		}
+	}
+
+	if err := json.Unmarshal(responses["6"].Result, &initialized); err != nil {
+		t.Fatal(err)
+	}
+	if initialized.ProtocolVersion != protocolVersions[len(protocolVersions)-1] {
+		t.Errorf("expected the latest version for an unknown one, got %s", initialized.ProtocolVersion)
+	}
+}
+
+func TestServer_Search(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeFiles(t, map[string]string{
+		"main.go":         greetSource,
+		"lib/greet.go":    strings.ReplaceAll(greetSource, "package main", "package lib"),
+		"lib/notes.txt":   "greet\n",
+		".git/hooks/a.go": "package hooks\n\n// hello\n",
+	})
+
+	greetScope := `  ⋮
+ 5 │ func greet(name string) {
+ 6 │ 	if name == "" {
+ 7 │ 		name = "world"
+ 8 │ 	}
+ 9 █ 	fmt.Println("hello", name)
+10 │ }
+11 │ 
+12 │ func main() {
+13 │ 	greet("")
+14 │ }
+  ⋮
+`
+
+	testCases := []struct {
+		name      string
+		arguments string
+		expected  string
+		isError   bool
+	}{
+		{
+			name:      "searches the supported files of the working directory",
+			arguments: `{"pattern":"hello"}`,
+			expected:  "lib/greet.go (go, 1 matching line)\n" + greetScope + "\nmain.go (go, 1 matching line)\n" + greetScope,
+		},
+		{
+			name:      "applies the search and context options",
+			arguments: `{"pattern":"HELLO","ignore_case":true,"paths":["main.go"],"surrounding_lines":0,"parent_context":false,"expand_scopes":false}`,
+			expected:  "main.go (go, 1 matching line)\n ⋮\n9 █ \tfmt.Println(\"hello\", name)\n ⋮\n",
+		},
+		{
+			name:      "returns json",
+			arguments: `{"pattern":"world","paths":["main.go"],"surrounding_lines":0,"parent_context":false,"expand_scopes":false,"format":"json"}`,
+			expected:  `{"files":[{"path":"main.go","language":"go","lines":[{"line":7,"text":"\t\tname = \"world\"","is_match":true}]}],"omitted_files":0}`,
+		},
+		{
+			name:      "leaves out context and files beyond the token budget",
+			arguments: `{"pattern":"hello","max_tokens":40}`,
+			expected: `lib/greet.go (go, 1 matching line)
+ ⋮
+9 █ 	fmt.Println("hello", name)
+ ⋮
+⋮ (lines left out to fit max_tokens)
+
+1 more file with matches was left out to fit max_tokens, narrow the paths or the pattern to see it.
+`,
+		},
+		{
+			name:      "counts the files left out in json",
+			arguments: `{"pattern":"hello","max_tokens":60,"format":"json"}`,
+			expected:  `{"files":[{"path":"lib/greet.go","language":"go","lines":[{"line":9,"text":"\tfmt.Println(\"hello\", name)","is_match":true}],"truncated":true}],"omitted_files":1}`,
+		},
+		{
+			name:      "reports no matches",
+			arguments: `{"pattern":"missing"}`,
+			expected:  "No matches found.",
+		},
+		{
+			name:      "skips files that can't be parsed",
+			arguments: `{"pattern":"hello","paths":["lib/notes.txt","main.go"],"surrounding_lines":0,"parent_context":false,"expand_scopes":false}`,
+			expected: `main.go (go, 1 matching line)
+ ⋮
+9 █ 	fmt.Println("hello", name)
+ ⋮
+
+Skipped files that could not be read or parsed:
+- failed to determine language for file lib/notes.txt: no language found for file extension .txt
+`,
+		},
+		{
+			name:      "lists the skipped files in json",
+			arguments: `{"pattern":"missing","paths":["lib/notes.txt"],"format":"json"}`,
+			expected:  `{"files":[],"omitted_files":0,"skipped_files":[{"path":"lib/notes.txt","error":"failed to determine language for file lib/notes.txt: no language found for file extension .txt"}]}`,
+		},
+		{
+			name:      "reports invalid patterns",
+			arguments: `{"pattern":"("}`,
+			expected:  "failed to compile regex pattern: error parsing regexp: missing closing ): `(()`",
+			isError:   true,
+		},
+		{
+			name:      "reports missing paths",
+			arguments: `{"pattern":"hello","paths":["missing"]}`,
+			expected:  "failed to stat missing: stat missing: no such file or directory",
+			isError:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := callTool(t, NewServer(), "search", tc.arguments)
+
+			if result.IsError != tc.isError {
+				t.Errorf("expected isError to be %v, got %v", tc.isError, result.IsError)
+			}
+			if text := result.Content[0].Text; text != tc.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tc.expected, text)
+			}
+		})
+	}
+}
+
+func TestServer_Search_SkippedFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeFiles(t, map[string]string{"main.go": greetSource})
+	if err := os.Symlink("missing.go", "broken.go"); err != nil {
+		t.Fatal(err)
+	}
+
+	result := callTool(t, NewServer(), "search", `{"pattern":"missing"}`)
+	if result.IsError {
+		t.Fatalf("expected files that can't be read to be skipped, got error %s", result.Content[0].Text)
+	}
+
+	expected := "No matches found.\n\nSkipped files that could not be read or parsed:\n- failed to read broken.go: open broken.go: no such file or directory\n"
+	if text := result.Content[0].Text; text != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, text)
+	}
+}
+
+func TestServer_Overview(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeFiles(t, map[string]string{"main.go": greetSource})
+
+	t.Run("outlines a file", func(t *testing.T) {
+		expected := `main.go (go)
+ 1 █ package main
+ 2 │ 
+ 3 █ import "fmt"
+ 4 │ 
+ 5 █ func greet(name string) {
+ 6 │ 	if name == "" {
+ 7 │ 		name = "world"
+ 8 │ 	}
+ 9 │ 	fmt.Println("hello", name)
+10 │ }
+11 │ 
+12 █ func main() {
+13 │ 	greet("")
+14 │ }
+  ⋮
+`
+		result := callTool(t, NewServer(), "overview", `{"path":"main.go"}`)
+		if text := result.Content[0].Text; result.IsError || text != expected {
+			t.Errorf("expected:\n%s\ngot:\n%s", expected, text)
+		}
+	})
+
+	t.Run("returns json", func(t *testing.T) {
+		result := callTool(t, NewServer(), "overview", `{"path":"main.go","format":"json"}`)
+
+		var fr fileResult
+		if err := json.Unmarshal([]byte(result.Content[0].Text), &fr); err != nil {
+			t.Fatalf("failed to decode result: %v", err)
+		}
+
+		var matches []int
+		for _, line := range fr.Lines {
+			if line.IsMatch {
+				matches = append(matches, line.Line)
+			}
+		}
+		if expected := []int{1, 3, 5, 12}; !reflect.DeepEqual(matches, expected) {
+			t.Errorf("expected top level lines %v, got %v", expected, matches)
+		}
+	})
+
+	t.Run("reports missing files", func(t *testing.T) {
+		if result := callTool(t, NewServer(), "overview", `{"path":"missing.go"}`); !result.IsError {
+			t.Errorf("expected an error, got %s", result.Content[0].Text)
+		}
+	})
+}
+
+func TestServer_ListLanguages(t *testing.T) {
+	result := callTool(t, NewServer(), "list_languages", `{}`)
+	if !strings.Contains(result.Content[0].Text, "\ngo: .go\n") {
+		t.Errorf("expected go to be listed, got:\n%s", result.Content[0].Text)
+	}
+
+	result = callTool(t, NewServer(), "list_languages", `{"format":"json"}`)
+	if !strings.Contains(result.Content[0].Text, `{"name":"typescript","extensions":[".ts",".tsx"]}`) {
+		t.Errorf("expected typescript to be listed, got:\n%s", result.Content[0].Text)
+	}
+}
@@ -0,0 +1,466 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/andersonjoseph/searchast"
+	"github.com/andersonjoseph/searchast/jsonrpc"
+	"github.com/andersonjoseph/searchast/language"
+)
+
+// defaultMaxTokens is the default token budget of a search.
+const defaultMaxTokens = 8000
+
+// tool describes a tool in the response to tools/list.
+type tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+// formatProperty is the schema of the format argument shared by the tools.
+var formatProperty = map[string]any{
+	"type":        "string",
+	"enum":        []string{"text", "json"},
+	"description": "Output format, text by default",
+}
+
+var tools = []tool{
+	{
+		Name: "search",
+		Description: "Search source files for a regular expression and return the matching lines within their " +
+			"enclosing scopes, such as the functions and classes containing them, with line numbers. " +
+			"Lines marked with █ match, lines marked with │ are context and ⋮ stands for hidden lines.",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"pattern":           map[string]any{"type": "string", "description": "Regular expression in RE2 syntax, or a literal string with fixed_strings"},
+				"paths":             map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Files or directories to search, the working directory by default"},
+				"fixed_strings":     map[string]any{"type": "boolean", "description": "Treat the pattern as a literal string"},
+				"ignore_case":       map[string]any{"type": "boolean", "description": "Search case-insensitively"},
+				"whole_word":        map[string]any{"type": "boolean", "description": "Only match whole words"},
+				"surrounding_lines": map[string]any{"type": "integer", "minimum": 0, "description": "Lines of context around each match"},
+				"child_lines":       map[string]any{"type": "integer", "minimum": 0, "description": "Lines of context after the start of a scope"},
+				"parent_context":    map[string]any{"type": "boolean", "description": "Show the start and end of the scopes containing each match"},
+				"expand_scopes":     map[string]any{"type": "boolean", "description": "Show every line of scopes starting near a match"},
+				"max_tokens":        map[string]any{"type": "integer", "minimum": 1, "description": fmt.Sprintf("Approximate token budget of the result, files beyond it are omitted (default %d)", defaultMaxTokens)},
+				"format":            formatProperty,
+			},
+			"required": []string{"pattern"},
+		},
+	},
+	{
+		Name: "overview",
+		Description: "Outline a source file: its top level declarations and the first lines of each scope, " +
+			"with line numbers, to understand a file without reading all of it.",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path":   map[string]any{"type": "string", "description": "File to outline"},
+				"format": formatProperty,
+			},
+			"required": []string{"path"},
+		},
+	},
+	{
+		Name:        "list_languages",
+		Description: "List the languages that can be searched and outlined, with their file extensions.",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"format": formatProperty,
+			},
+		},
+	},
+}
+
+// CallToolResult is the result of a tool call. Failures of the tool, such as
+// an invalid pattern, are reported with IsError so that agents can see them.
+type CallToolResult struct {
+	Content []Content `json:"content"`
+	IsError bool      `json:"isError,omitempty"`
+}
+
+type Content struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// callTool runs a tool with its arguments.
+func (s *Server) callTool(ctx context.Context, name string, arguments json.RawMessage) (*CallToolResult, error) {
+	var (
+		text string
+		err  error
+	)
+
+	switch name {
+	case "search":
+		text, err = runWithArguments(arguments, func(args searchArgs) (string, error) { return s.search(ctx, args) })
+	case "overview":
+		text, err = runWithArguments(arguments, func(args overviewArgs) (string, error) { return s.overview(ctx, args) })
+	case "list_languages":
+		text, err = runWithArguments(arguments, listLanguages)
+	default:
+		return nil, &ResponseError{Code: jsonrpc.CodeInvalidParams, Message: fmt.Sprintf("unknown tool %q", name)}
+	}
+
+	if err != nil {
+		return &CallToolResult{Content: []Content{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+	}
+
+	return &CallToolResult{Content: []Content{{Type: "text", Text: text}}}, nil
+}
+
+// runWithArguments decodes the arguments of a tool and calls run with them.
+func runWithArguments[A any](arguments json.RawMessage, run func(A) (string, error)) (string, error) {
+	var args A
+	if len(arguments) > 0 {
+		if err := json.Unmarshal(arguments, &args); err != nil {
+			return "", fmt.Errorf("invalid arguments: %w", err)
+		}
+	}
+
+	return run(args)
+}
+
+type searchArgs struct {
+	Pattern          string   `json:"pattern"`
+	Paths            []string `json:"paths"`
+	FixedStrings     bool     `json:"fixed_strings"`
+	IgnoreCase       bool     `json:"ignore_case"`
+	WholeWord        bool     `json:"whole_word"`
+	SurroundingLines *uint32  `json:"surrounding_lines"`
+	ChildLines       *uint32  `json:"child_lines"`
+	ParentContext    *bool    `json:"parent_context"`
+	ExpandScopes     *bool    `json:"expand_scopes"`
+	MaxTokens        int      `json:"max_tokens"`
+	Format           string   `json:"format"`
+}
+
+type overviewArgs struct {
+	Path   string `json:"path"`
+	Format string `json:"format"`
+}
+
+type listLanguagesArgs struct {
+	Format string `json:"format"`
+}
+
+// fileResult is the JSON output of a file. Lines are 1-based.
+type fileResult struct {
+	Path     string       `json:"path"`
+	Language string       `json:"language"`
+	Lines    []resultLine `json:"lines"`
+	// Truncated reports whether lines were left out to fit the token budget.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+type resultLine struct {
+	Line    int    `json:"line"`
+	Text    string `json:"text"`
+	IsMatch bool   `json:"is_match"`
+}
+
+// searchResult is the JSON output of a search, Files holds the encoded
+// fileResult of each file.
+type searchResult struct {
+	Files []json.RawMessage `json:"files"`
+	// OmittedFiles is the number of files with matches left out to fit the
+	// token budget.
+	OmittedFiles int `json:"omitted_files"`
+	// SkippedFiles are the files that could not be read or parsed.
+	SkippedFiles []skippedFile `json:"skipped_files,omitempty"`
+}
+
+type skippedFile struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// search implements the search tool. Files are added to the output while it
+// fits the token budget. If the first file does not fit alone, its context is
+// left out, and then its last lines. Files that can't be read or parsed are
+// skipped and listed after the results.
+func (s *Server) search(ctx context.Context, args searchArgs) (string, error) {
+	if args.Pattern == "" {
+		return "", errors.New("missing pattern")
+	}
+	if err := checkFormat(args.Format); err != nil {
+		return "", err
+	}
+
+	paths := args.Paths
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	budget := args.MaxTokens
+	if budget <= 0 {
+		budget = defaultMaxTokens
+	}
+
+	searchOpts := slices.Clone(s.searchOpts)
+	if args.FixedStrings {
+		searchOpts = append(searchOpts, searchast.WithFixedStrings(true))
+	}
+	if args.IgnoreCase {
+		searchOpts = append(searchOpts, searchast.WithIgnoreCase(true))
+	}
+	if args.WholeWord {
+		searchOpts = append(searchOpts, searchast.WithWholeWord(true))
+	}
+
+	contextOpts := slices.Clone(s.contextOpts)
+	if args.SurroundingLines != nil {
+		contextOpts = append(contextOpts, searchast.WithSurroundingLines(*args.SurroundingLines))
+	}
+	if args.ChildLines != nil {
+		contextOpts = append(contextOpts, searchast.WithChildLines(*args.ChildLines))
+	}
+	if args.ParentContext != nil {
+		contextOpts = append(contextOpts, searchast.WithParentContext(*args.ParentContext))
+	}
+	if args.ExpandScopes != nil {
+		contextOpts = append(contextOpts, searchast.WithExpandChildScopes(*args.ExpandScopes))
+	}
+
+	files, err := s.listFiles(paths)
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		chunks  []string
+		omitted int
+		used    int
+		skipped []skippedFile
+	)
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		tree, lines, err := s.parse(ctx, file)
+		if err != nil {
+			skipped = append(skipped, skippedFile{Path: file, Error: err.Error()})
+			continue
+		}
+
+		matchingLines, err := tree.SearchAny([]string{args.Pattern}, searchOpts...)
+		if err != nil {
+			return "", err
+		}
+		if len(matchingLines) == 0 {
+			continue
+		}
+		if used >= budget {
+			omitted++
+			continue
+		}
+
+		// render returns the output of the file showing the given lines.
+		render := func(linesToShow searchast.Set[uint32], truncated bool) string {
+			if args.Format == "json" {
+				fr := newFileResult(file, lines, linesToShow, matchingLines)
+				fr.Truncated = truncated
+				return string(mustMarshal(fr))
+			}
+
+			var chunk strings.Builder
+			formatter := searchast.NewTextFormatter()
+			_ = formatter.WriteHeader(&chunk, file, languageName(file), len(matchingLines))
+			chunk.WriteString(formatter.Format(tree.LinesFor(formatter), linesToShow, matchingLines))
+			if truncated {
+				chunk.WriteString("⋮ (lines left out to fit max_tokens)\n")
+			}
+			return chunk.String()
+		}
+
+		chunk := render(searchast.NewContextBuilder(contextOpts...).AddContext(tree, matchingLines), false)
+		if used+tokens(chunk) > budget {
+			if len(chunks) > 0 {
+				used = budget
+				omitted++
+				continue
+			}
+
+			shown := matchingLines.ToSlice()
+			slices.Sort(shown)
+			n := sort.Search(len(shown)+1, func(n int) bool {
+				return tokens(render(searchast.NewSetFromSlice(shown[:n]), true)) > budget
+			})
+			chunk = render(searchast.NewSetFromSlice(shown[:max(n-1, 0)]), true)
+			used = budget
+		}
+
+		chunks = append(chunks, chunk)
+		used += tokens(chunk)
+	}
+
+	if args.Format == "json" {
+		result := searchResult{Files: []json.RawMessage{}, OmittedFiles: omitted, SkippedFiles: skipped}
+		for _, chunk := range chunks {
+			result.Files = append(result.Files, json.RawMessage(chunk))
+		}
+		return string(mustMarshal(result)), nil
+	}
+
+	if len(chunks) == 0 && len(skipped) == 0 {
+		return "No matches found.", nil
+	}
+
+	text := strings.Join(chunks, "\n")
+	if len(chunks) == 0 {
+		text = "No matches found.\n"
+	}
+	switch {
+	case omitted == 1:
+		text += "\n1 more file with matches was left out to fit max_tokens, narrow the paths or the pattern to see it.\n"
+	case omitted > 1:
+		text += fmt.Sprintf("\n%d more files with matches were left out to fit max_tokens, narrow the paths or the pattern to see them.\n", omitted)
+	}
+
+	if len(skipped) > 0 {
+		text += "\nSkipped files that could not be read or parsed:\n"
+		for _, file := range skipped {
+			text += "- " + file.Error + "\n"
+		}
+	}
+
+	return text, nil
+}
+
+// overview implements the overview tool, with the same output as
+// cmd/overview.
+func (s *Server) overview(ctx context.Context, args overviewArgs) (string, error) {
+	if args.Path == "" {
+		return "", errors.New("missing path")
+	}
+	if err := checkFormat(args.Format); err != nil {
+		return "", err
+	}
+
+	tree, lines, err := s.parse(ctx, args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	linesOfInterest := tree.TopLevel()
+	linesToShow := searchast.NewContextBuilder(searchast.OverviewOptions()...).AddContext(tree, linesOfInterest)
+
+	if args.Format == "json" {
+		return string(mustMarshal(newFileResult(args.Path, lines, linesToShow, linesOfInterest))), nil
+	}
+
+	var text strings.Builder
+	formatter := searchast.NewTextFormatter()
+	if err := formatter.WriteHeader(&text, args.Path, languageName(args.Path), 0); err != nil {
+		return "", err
+	}
+	if err := formatter.FormatTo(&text, tree.LinesFor(formatter), linesToShow, linesOfInterest); err != nil {
+		return "", err
+	}
+
+	return text.String(), nil
+}
+
+// listLanguages implements the list_languages tool.
+func listLanguages(args listLanguagesArgs) (string, error) {
+	if err := checkFormat(args.Format); err != nil {
+		return "", err
+	}
+
+	languages := language.Languages()
+
+	if args.Format == "json" {
+		type languageResult struct {
+			Name       string   `json:"name"`
+			Extensions []string `json:"extensions"`
+		}
+
+		result := make([]languageResult, 0, len(languages))
+		for _, lang := range languages {
+			result = append(result, languageResult{Name: lang.Name, Extensions: lang.Extensions})
+		}
+		return string(mustMarshal(result)), nil
+	}
+
+	var text strings.Builder
+	for _, lang := range languages {
+		fmt.Fprintf(&text, "%s: %s\n", lang.Name, strings.Join(lang.Extensions, " "))
+	}
+
+	return text.String(), nil
+}
+
+// parse reads and parses a file, returning its tree and its lines.
+func (s *Server) parse(ctx context.Context, path string) (*searchast.SourceTree, []string, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	newSourceTree := searchast.NewSourceTree
+	if s.parseCache != nil {
+		newSourceTree = s.parseCache.NewSourceTree
+	}
+
+	tree, err := newSourceTree(ctx, bytes.NewReader(source), path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tree, strings.Split(string(source), "\n"), nil
+}
+
+// newFileResult returns the JSON output of the lines to show of a file.
+func newFileResult(path string, lines []string, linesToShow searchast.Set[uint32], linesToHighlight searchast.Set[uint32]) fileResult {
+	fr := fileResult{Path: path, Language: languageName(path), Lines: []resultLine{}}
+
+	numbers := linesToShow.ToSlice()
+	slices.Sort(numbers)
+	for _, number := range numbers {
+		fr.Lines = append(fr.Lines, resultLine{
+			Line:    int(number) + 1,
+			Text:    lines[number],
+			IsMatch: linesToHighlight.Has(number),
+		})
+	}
+
+	return fr
+}
+
+// checkFormat returns an error for unknown output formats.
+func checkFormat(format string) error {
+	if format != "" && format != "text" && format != "json" {
+		return fmt.Errorf("unknown format %q, expected text or json", format)
+	}
+	return nil
+}
+
+// languageName returns the language of a file, or an empty string.
+func languageName(path string) string {
+	name, _ := language.NameFromFilename(path)
+	return name
+}
+
+// tokens estimates the number of tokens of a text, at about 4 bytes each.
+func tokens[T string | []byte](text T) int {
+	return (len(text) + 3) / 4
+}
+
+// mustMarshal encodes v, which only holds types that can be encoded.
+func mustMarshal(v any) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
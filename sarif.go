@@ -0,0 +1,272 @@
+package searchast
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifToolURI = "https://github.com/andersonjoseph/searchast"
+
+	// sarifSourceRoot is the base of the URIs of relative paths, which
+	// code-scanning tools resolve to the root of the scanned sources.
+	sarifSourceRoot = "%SRCROOT%"
+)
+
+// SARIFResult is a single match reported in a SARIF log.
+type SARIFResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+	ContextRegion    sarifRegion           `json:"contextRegion"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int          `json:"startLine"`
+	StartColumn int          `json:"startColumn,omitempty"`
+	EndLine     int          `json:"endLine"`
+	EndColumn   int          `json:"endColumn,omitempty"`
+	Snippet     sarifMessage `json:"snippet"`
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []SARIFResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+// SARIFFormatter reports every match of its rules on the highlighted lines as
+// a SARIF 2.1.0 result, including the shown lines around it as context.
+type SARIFFormatter struct {
+	filename string
+	rules    []Rule
+	matchers []*matcher
+}
+
+// NewSARIFFormatter creates a formatter for the given file, whose path is
+// reported relative to the scanned sources unless it is absolute. The rule patterns
+// are compiled with the given search options, which should be the ones used
+// to search the file.
+func NewSARIFFormatter(filename string, rules []Rule, opts ...SearchOption) (*SARIFFormatter, error) {
+	patterns := make([]string, len(rules))
+	for i, rule := range rules {
+		patterns[i] = rule.Pattern
+	}
+
+	matchers, err := newMatchers(patterns, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SARIFFormatter{
+		filename: filename,
+		rules:    rules,
+		matchers: matchers,
+	}, nil
+}
+
+// Results returns the SARIF results for the matches starting on the highlighted lines.
+func (sf *SARIFFormatter) Results(lines []line, linesToShow Set[lineNumber], linesToHighlight Set[lineNumber]) []SARIFResult {
+	var matches []Match
+	for i, m := range sf.matchers {
+		for _, match := range findMatches(lines, m) {
+			if linesToHighlight.Has(match.StartLine) {
+				match.Pattern = i
+				matches = append(matches, match)
+			}
+		}
+	}
+	sortMatches(matches)
+
+	results := make([]SARIFResult, 0, len(matches))
+	for _, match := range matches {
+		rule := sf.rules[match.Pattern]
+
+		message := rule.Description
+		if message == "" {
+			message = fmt.Sprintf("Matches pattern %s", rule.Pattern)
+		}
+
+		contextStart, contextEnd := match.StartLine, match.lastLine()
+		for contextStart > 0 && linesToShow.Has(contextStart-1) {
+			contextStart--
+		}
+		for int(contextEnd) < len(lines)-1 && linesToShow.Has(contextEnd+1) {
+			contextEnd++
+		}
+
+		results = append(results, SARIFResult{
+			RuleID:    rule.ID,
+			RuleIndex: match.Pattern,
+			Level:     ruleLevel(rule),
+			Message:   sarifMessage{Text: message},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifact(sf.filename),
+					Region: sarifRegion{
+						StartLine:   int(match.StartLine) + 1,
+						StartColumn: runeColumn(lines[match.StartLine].text, match.StartColumn),
+						EndLine:     int(match.EndLine) + 1,
+						EndColumn:   runeColumn(lines[match.EndLine].text, match.EndColumn),
+						Snippet:     sarifMessage{Text: joinLines(lines, match.StartLine, match.EndLine, match.StartColumn, match.EndColumn)},
+					},
+					ContextRegion: sarifRegion{
+						StartLine: int(contextStart) + 1,
+						EndLine:   int(contextEnd) + 1,
+						Snippet:   sarifMessage{Text: joinLines(lines, contextStart, contextEnd, 0, len(lines[contextEnd].text))},
+					},
+				},
+			}},
+		})
+	}
+
+	return results
+}
+
+func (sf *SARIFFormatter) Format(lines []line, linesToShow Set[lineNumber], linesToHighlight Set[lineNumber]) string {
+	output := strings.Builder{}
+	_ = sf.FormatTo(&output, lines, linesToShow, linesToHighlight) // writing to a strings.Builder never fails
+
+	return output.String()
+}
+
+// FormatTo writes a SARIF log with the results of a single file to w.
+func (sf *SARIFFormatter) FormatTo(w io.Writer, lines []line, linesToShow Set[lineNumber], linesToHighlight Set[lineNumber]) error {
+	return WriteSARIF(w, sf.rules, sf.Results(lines, linesToShow, linesToHighlight))
+}
+
+// WriteSARIF writes a SARIF log with a single run containing the given rules
+// and results, which may come from several files.
+func WriteSARIF(w io.Writer, rules []Rule, results []SARIFResult) error {
+	driver := sarifDriver{
+		Name:           "searchast",
+		InformationURI: sarifToolURI,
+		Rules:          make([]sarifRule, len(rules)),
+	}
+	for i, rule := range rules {
+		description := rule.Description
+		if description == "" {
+			description = rule.Pattern
+		}
+
+		driver.Rules[i] = sarifRule{
+			ID:                   rule.ID,
+			ShortDescription:     sarifMessage{Text: description},
+			DefaultConfiguration: sarifConfiguration{Level: ruleLevel(rule)},
+		}
+	}
+
+	if results == nil {
+		results = []SARIFResult{}
+	}
+
+	log := sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs: []sarifRun{{
+			Tool:       sarifTool{Driver: driver},
+			ColumnKind: "unicodeCodePoints",
+			Results:    results,
+		}},
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(log)
+}
+
+// sarifArtifact returns the location of a file: a URI relative to
+// sarifSourceRoot for relative paths, and a file URI for absolute ones.
+func sarifArtifact(filename string) sarifArtifactLocation {
+	path := filepath.ToSlash(filepath.Clean(filename))
+	if !filepath.IsAbs(filename) {
+		return sarifArtifactLocation{URI: (&url.URL{Path: path}).String(), URIBaseID: sarifSourceRoot}
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // a Windows volume, as in file:///C:/src/main.go
+	}
+	return sarifArtifactLocation{URI: (&url.URL{Scheme: "file", Path: path}).String()}
+}
+
+// ruleLevel returns the level of the rule, defaulting to "warning".
+func ruleLevel(rule Rule) string {
+	if rule.Level == "" {
+		return "warning"
+	}
+	return rule.Level
+}
+
+// runeColumn converts a 0-based byte column into a 1-based character column.
+func runeColumn(text string, column int) int {
+	return utf8.RuneCountInString(text[:min(column, len(text))]) + 1
+}
+
+// joinLines returns the text between two positions, joining lines with "\n".
+func joinLines(lines []line, startLine lineNumber, endLine lineNumber, startColumn int, endColumn int) string {
+	if startLine == endLine {
+		text := lines[startLine].text
+		return text[min(startColumn, len(text)):min(endColumn, len(text))]
+	}
+
+	parts := []string{lines[startLine].text[min(startColumn, len(lines[startLine].text)):]}
+	for line := startLine + 1; line < endLine; line++ {
+		parts = append(parts, lines[line].text)
+	}
+	parts = append(parts, lines[endLine].text[:min(endColumn, len(lines[endLine].text))])
+
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,97 @@
+package searchast
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSARIFFormatter_Format(t *testing.T) {
+	source := `package main
+
+func main() {
+	fmt.Println("héllo"); fmt.Println("bye")
+	panic("done")
+}`
+
+	st := mustNewSourceTree(t, source)
+	rules := []Rule{
+		{ID: "no-print", Pattern: `fmt\.Println`, Description: "Use the logger"},
+		{ID: "no-panic", Pattern: `panic`, Level: "error"},
+	}
+
+	linesOfInterest, err := st.SearchAny([]string{rules[0].Pattern, rules[1].Pattern})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	linesToShow := NewContextBuilder().AddContext(st, linesOfInterest)
+
+	formatter, err := NewSARIFFormatter("cmd/main.go", rules)
+	if err != nil {
+		t.Fatalf("failed to create formatter: %v", err)
+	}
+	output := formatter.Format(st.Lines(), linesToShow, linesOfInterest)
+
+	var log sarifLog
+	if err := json.Unmarshal([]byte(output), &log); err != nil {
+		t.Fatalf("expected valid JSON, but got: %v\n%s", err, output)
+	}
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("expected a SARIF 2.1.0 log with one run, got: %s", output)
+	}
+	run := log.Runs[0]
+
+	t.Run("describes every rule", func(t *testing.T) {
+		if len(run.Tool.Driver.Rules) != 2 || run.Tool.Driver.Rules[1].DefaultConfiguration.Level != "error" {
+			t.Errorf("unexpected rules: %+v", run.Tool.Driver.Rules)
+		}
+	})
+
+	t.Run("reports one result per match", func(t *testing.T) {
+		if len(run.Results) != 3 {
+			t.Fatalf("expected 3 results, got %d", len(run.Results))
+		}
+
+		second := run.Results[1]
+		if second.RuleID != "no-print" || second.Message.Text != "Use the logger" || second.Level != "warning" {
+			t.Errorf("unexpected result: %+v", second)
+		}
+
+		location := second.Locations[0].PhysicalLocation
+		if location.ArtifactLocation != (sarifArtifactLocation{URI: "cmd/main.go", URIBaseID: "%SRCROOT%"}) {
+			t.Errorf("unexpected artifact location: %+v", location.ArtifactLocation)
+		}
+		// Columns are 1-based and count characters, so é counts once.
+		expected := sarifRegion{StartLine: 4, StartColumn: 24, EndLine: 4, EndColumn: 35, Snippet: sarifMessage{Text: "fmt.Println"}}
+		if location.Region != expected {
+			t.Errorf("expected region %+v, got %+v", expected, location.Region)
+		}
+	})
+
+	t.Run("includes the shown lines as context", func(t *testing.T) {
+		context := run.Results[2].Locations[0].PhysicalLocation.ContextRegion
+		if context.StartLine != 1 || context.EndLine != 6 || !strings.HasPrefix(context.Snippet.Text, "package main\n") {
+			t.Errorf("unexpected context region: %+v", context)
+		}
+	})
+
+	t.Run("encodes the paths of files as URIs", func(t *testing.T) {
+		testCases := map[string]sarifArtifactLocation{
+			"./cmd/my file#1.go": {URI: "cmd/my%20file%231.go", URIBaseID: "%SRCROOT%"},
+			"HEAD:main.go":       {URI: "./HEAD:main.go", URIBaseID: "%SRCROOT%"},
+			"/src/main.go":       {URI: "file:///src/main.go"},
+		}
+		for filename, expected := range testCases {
+			if location := sarifArtifact(filename); location != expected {
+				t.Errorf("expected %s to be located at %+v, got %+v", filename, expected, location)
+			}
+		}
+	})
+
+	t.Run("returns an error for invalid rule patterns", func(t *testing.T) {
+		if _, err := NewSARIFFormatter("main.go", []Rule{{ID: "x", Pattern: "["}}); err == nil {
+			t.Fatal("expected an error but got none")
+		}
+	})
+}
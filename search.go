@@ -0,0 +1,309 @@
+package searchast
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// searchOptions controls how a search pattern is interpreted.
+type searchOptions struct {
+	fixedStrings bool
+	ignoreCase   bool
+	smartCase    bool
+	wholeWord    bool
+	multiline    bool
+}
+
+type SearchOption func(*searchOptions)
+
+// WithFixedStrings treats the pattern as a literal string instead of a regular expression.
+func WithFixedStrings(enabled bool) SearchOption {
+	return func(o *searchOptions) {
+		o.fixedStrings = enabled
+	}
+}
+
+// WithIgnoreCase makes the search case-insensitive.
+func WithIgnoreCase(enabled bool) SearchOption {
+	return func(o *searchOptions) {
+		o.ignoreCase = enabled
+	}
+}
+
+// WithSmartCase makes the search case-insensitive unless the pattern contains
+// an uppercase character. It has no effect when WithIgnoreCase is enabled.
+func WithSmartCase(enabled bool) SearchOption {
+	return func(o *searchOptions) {
+		o.smartCase = enabled
+	}
+}
+
+// WithWholeWord only matches the pattern when it is surrounded by word boundaries.
+func WithWholeWord(enabled bool) SearchOption {
+	return func(o *searchOptions) {
+		o.wholeWord = enabled
+	}
+}
+
+// WithMultiline runs the pattern over the whole source instead of each line,
+// so matches can span several lines. Every line covered by a match is reported.
+// ^ and $ still match at the beginning and end of each line.
+func WithMultiline(enabled bool) SearchOption {
+	return func(o *searchOptions) {
+		o.multiline = enabled
+	}
+}
+
+// matcher wraps a compiled search pattern. The matched text is always the
+// first capture group of re. Whole word patterns are wrapped in word boundary
+// assertions, which don't consume the characters around a match so that
+// adjacent matches are all found.
+type matcher struct {
+	re        *regexp.Regexp
+	multiline bool
+}
+
+// newMatcher compiles pattern according to the given options.
+func newMatcher(pattern string, opts ...SearchOption) (*matcher, error) {
+	var o searchOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	expr := pattern
+	if o.fixedStrings {
+		expr = regexp.QuoteMeta(expr)
+	}
+
+	if o.wholeWord {
+		expr = wordBoundary(expr, false) + `(` + expr + `)` + wordBoundary(expr, true)
+	} else {
+		expr = `(` + expr + `)`
+	}
+
+	if o.ignoreCase || (o.smartCase && !hasUppercase(pattern, o.fixedStrings)) {
+		expr = `(?i)` + expr
+	}
+
+	if o.multiline {
+		expr = `(?m)` + expr
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile regex pattern: %w", err)
+	}
+
+	return &matcher{re: re, multiline: o.multiline}, nil
+}
+
+// match reports whether text contains the pattern.
+func (m *matcher) match(text string) bool {
+	return m.re.MatchString(text)
+}
+
+// spans returns the byte ranges of every match of the pattern in text.
+func (m *matcher) spans(text string) [][2]int {
+	var spans [][2]int
+	for _, loc := range m.re.FindAllStringSubmatchIndex(text, -1) {
+		spans = append(spans, [2]int{loc[2], loc[3]})
+	}
+
+	return spans
+}
+
+// wordBoundary returns the assertion delimiting a whole word match of expr at
+// its start, or at its end if last is true. A match must not be next to a word
+// character, so \b is used when expr starts (or ends) with one, and \B when
+// it starts with another literal character, such as the parenthesis ending
+// fmt.Println(. If the character can't be told, \b is used as grep and rg do.
+func wordBoundary(expr string, last bool) string {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return `\b` // reported when compiling the whole expression
+	}
+
+	if r, ok := edgeRune(re, last); ok && !(r < utf8.RuneSelf && isWordByte(byte(r))) {
+		return `\B`
+	}
+	return `\b`
+}
+
+// edgeRune returns the first character matched by re, or the last one if last
+// is true, when it is a literal.
+func edgeRune(re *syntax.Regexp, last bool) (rune, bool) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if len(re.Rune) == 0 {
+			return 0, false
+		}
+		if last {
+			return re.Rune[len(re.Rune)-1], true
+		}
+		return re.Rune[0], true
+	case syntax.OpCapture:
+		return edgeRune(re.Sub[0], last)
+	case syntax.OpConcat:
+		if len(re.Sub) == 0 {
+			return 0, false
+		}
+		if last {
+			return edgeRune(re.Sub[len(re.Sub)-1], last)
+		}
+		return edgeRune(re.Sub[0], last)
+	default:
+		return 0, false
+	}
+}
+
+// isWordByte reports whether b is an ASCII word character, [0-9A-Za-z_].
+func isWordByte(b byte) bool {
+	return b == '_' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+// hasUppercase reports whether the pattern contains an uppercase letter,
+// ignoring escape sequences such as \W or \S in regular expressions.
+func hasUppercase(pattern string, literal bool) bool {
+	escaped := false
+	for _, r := range pattern {
+		if !literal && !escaped && r == '\\' {
+			escaped = true
+			continue
+		}
+
+		if !escaped && unicode.IsUpper(r) {
+			return true
+		}
+		escaped = false
+	}
+
+	return false
+}
+
+// Rule is a named search pattern, used to identify matches in reports such as SARIF.
+type Rule struct {
+	ID          string
+	Pattern     string
+	Description string
+	// Level is the severity of the matches: "error", "warning" or "note".
+	Level string
+}
+
+// Match is an occurrence of a pattern in the source. Lines are 0-based and
+// columns are 0-based byte offsets within their line, the end is exclusive.
+type Match struct {
+	// Pattern is the index of the pattern that matched.
+	Pattern     int
+	StartLine   lineNumber
+	StartColumn int
+	EndLine     lineNumber
+	EndColumn   int
+}
+
+// lastLine returns the last line covered by the match, which is the line
+// before EndLine when the match ends right after a line break.
+func (m Match) lastLine() lineNumber {
+	if m.EndColumn == 0 && m.EndLine > m.StartLine {
+		return m.EndLine - 1
+	}
+	return m.EndLine
+}
+
+// findMatches returns every match of m in lines. Multiline matchers run over
+// the whole text, so their matches can span several lines.
+func findMatches(lines []line, m *matcher) []Match {
+	var matches []Match
+
+	if !m.multiline {
+		for i, line := range lines {
+			for _, span := range m.spans(line.text) {
+				matches = append(matches, Match{
+					StartLine:   lineNumber(i),
+					StartColumn: span[0],
+					EndLine:     lineNumber(i),
+					EndColumn:   span[1],
+				})
+			}
+		}
+
+		return matches
+	}
+
+	texts := make([]string, len(lines))
+	lineStarts := make([]int, len(lines))
+	offset := 0
+	for i, line := range lines {
+		texts[i] = line.text
+		lineStarts[i] = offset
+		offset += len(line.text) + 1
+	}
+
+	position := func(offset int) (lineNumber, int) {
+		i, found := slices.BinarySearch(lineStarts, offset)
+		if !found {
+			i--
+		}
+		return lineNumber(i), offset - lineStarts[i]
+	}
+
+	for _, span := range m.spans(strings.Join(texts, "\n")) {
+		var match Match
+		match.StartLine, match.StartColumn = position(span[0])
+		match.EndLine, match.EndColumn = position(span[1])
+		matches = append(matches, match)
+	}
+
+	return matches
+}
+
+// sortMatches orders matches by their start position.
+func sortMatches(matches []Match) {
+	slices.SortStableFunc(matches, func(a, b Match) int {
+		if a.StartLine != b.StartLine {
+			return cmp.Compare(a.StartLine, b.StartLine)
+		}
+		return cmp.Compare(a.StartColumn, b.StartColumn)
+	})
+}
+
+// ScopeQuery is a boolean combination of patterns evaluated against the lines
+// of each scope instead of individual lines.
+type ScopeQuery struct {
+	// Any requires a scope to contain a line matching at least one of the patterns.
+	Any []string
+	// All requires a scope to contain a line matching each of the patterns.
+	All []string
+	// None requires a scope to not contain any line matching the patterns.
+	None []string
+	// Kinds restricts the query to scopes of the given kinds, either ScopeKind
+	// categories or raw tree-sitter node types such as "function_declaration".
+	Kinds []string
+}
+
+// ValidatePatterns returns the error a search for the given patterns would
+// fail with, such as an invalid regular expression, so that it can be
+// reported before any file is searched.
+func ValidatePatterns(patterns []string, opts ...SearchOption) error {
+	_, err := newMatchers(patterns, opts...)
+	return err
+}
+
+// newMatchers compiles every pattern with the same options.
+func newMatchers(patterns []string, opts ...SearchOption) ([]*matcher, error) {
+	matchers := make([]*matcher, 0, len(patterns))
+	for _, pattern := range patterns {
+		m, err := newMatcher(pattern, opts...)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+
+	return matchers, nil
+}
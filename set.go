@@ -40,3 +40,15 @@ func (s Set[T]) ToSlice() []T {
 func (s *Set[T]) Clear() {
 	*s = make(Set[T])
 }
+
+// Intersect returns a new set with the elements present in both sets.
+func (s Set[T]) Intersect(other Set[T]) Set[T] {
+	intersection := make(Set[T])
+	for e := range s {
+		if other.Has(e) {
+			intersection.Add(e)
+		}
+	}
+
+	return intersection
+}
@@ -8,7 +8,7 @@ import (
 	"fmt"
 	"io"
 	"iter"
-	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/andersonjoseph/searchast/language"
@@ -17,17 +17,31 @@ import (
 
 type lineNumber = uint32
 
-// line represents a single line in the source file, including its text and scope information.
+// line represents a single line in the source file, including its text, scope information
+// and the tokens used for syntax highlighting.
 type line struct {
-	text  string
-	scope scope
+	text   string
+	scope  scope
+	tokens []token
+}
+
+// Text returns the text of the line, without its line break.
+func (l line) Text() string {
+	return l.text
 }
 
 // scope defines a block of code, linking it to a parent and tracking its start and end lines.
+// kind is the tree-sitter node type that defines the scope, if any, and kinds
+// the types of every multiline node starting on its line, such as the
+// export_statement and function_declaration of an exported function. category
+// is the scope kind category of the outermost node starting on its line.
 type scope struct {
-	parent lineNumber
-	start  lineNumber
-	end    lineNumber
+	parent   lineNumber
+	start    lineNumber
+	end      lineNumber
+	kind     string
+	kinds    []string
+	category string
 }
 
 // size calculates the number of lines contained within a scope.
@@ -35,6 +49,24 @@ func (s scope) size() uint32 {
 	return s.end - s.start
 }
 
+// contains reports whether any of the given lines is within the scope.
+func (s scope) contains(lines Set[lineNumber]) bool {
+	for line := range lines {
+		if line >= s.start && line <= s.end {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isKind reports whether the scope is of the given kind, which is either one
+// of the ScopeKind categories or the raw tree-sitter type of one of the nodes
+// starting on its line.
+func (s scope) isKind(kind string) bool {
+	return s.category == kind || slices.Contains(s.kinds, kind)
+}
+
 // children returns an iterator sequence for all line numbers within a scope.
 func (s scope) children() iter.Seq[lineNumber] {
 	return func(yield func(lineNumber) bool) {
@@ -48,6 +80,20 @@ func (s scope) children() iter.Seq[lineNumber] {
 
 type sourceTree struct {
 	lines []line
+
+	// tree, source and lang are kept for incremental parsing by Edit. tree is
+	// nil for trees read from a ParseCache, and all of them are nil once the
+	// tree is released, see Release.
+	tree   *sitter.Tree
+	source []byte
+	lang   *sitter.Language
+
+	// langName is the name of the language, used to categorize scopes.
+	langName string
+
+	// highlighted reports whether the tokens of the lines were computed. They
+	// are only computed for the formatters using them, see Lines.
+	highlighted bool
 }
 
 // NewSourceTree constructs a new sourceTree from a reader and filename.
@@ -57,6 +103,13 @@ func NewSourceTree(ctx context.Context, r io.Reader, filename string) (*sourceTr
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file %s: %w", filename, err)
 	}
+
+	return parseSourceTree(ctx, sourceCode, filename)
+}
+
+// parseSourceTree parses sourceCode with the grammar of the language used for
+// filename and computes the scopes of its lines.
+func parseSourceTree(ctx context.Context, sourceCode []byte, filename string) (*sourceTree, error) {
 	parser := sitter.NewParser()
 	defer parser.Close()
 
@@ -64,6 +117,10 @@ func NewSourceTree(ctx context.Context, r io.Reader, filename string) (*sourceTr
 	if err != nil {
 		return nil, fmt.Errorf("failed to determine language for file %s: %w", filename, err)
 	}
+	langName, err := language.NameFromFilename(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine language for file %s: %w", filename, err)
+	}
 	parser.SetLanguage(lang)
 
 	tree, err := parser.ParseCtx(ctx, nil, sourceCode)
@@ -72,6 +129,18 @@ func NewSourceTree(ctx context.Context, r io.Reader, filename string) (*sourceTr
 	}
 	root := tree.RootNode()
 
+	st := newSourceTree(sourceCode)
+	st.tree = tree
+	st.lang = lang
+	st.langName = langName
+	st.build(root, 0, lineNumber(len(st.lines)-1))
+
+	return st, nil
+}
+
+// newSourceTree returns a sourceTree with the lines of sourceCode, each line
+// being its own scope until the tree is built.
+func newSourceTree(sourceCode []byte) *sourceTree {
 	sourceLines := strings.Split(string(sourceCode), "\n")
 
 	lines := make([]line, len(sourceLines))
@@ -81,73 +150,352 @@ func NewSourceTree(ctx context.Context, r io.Reader, filename string) (*sourceTr
 		lines[i].scope.end = lineNumber(i)
 	}
 
-	st := &sourceTree{
-		lines: lines,
+	return &sourceTree{
+		lines:  lines,
+		source: sourceCode,
 	}
-
-	st.build(root)
-
-	return st, nil
 }
 
+// Lines returns the lines of the tree, computing their syntax highlighting
+// tokens on the first call. Use LinesFor to only compute them for the
+// formatters using them.
 func (st *sourceTree) Lines() []line {
+	st.ensureHighlighted()
 	return st.lines
 }
 
-// build recursively traverses the tree-sitter abstract syntax tree (AST)
-// to populate the scope information for each line.
-func (st *sourceTree) build(node *sitter.Node) {
+// LinesFor returns the lines of the tree to pass to f. Their syntax
+// highlighting tokens are only computed if f uses them, which are the
+// HTMLFormatter and a TextFormatter with syntax highlighting.
+func (st *sourceTree) LinesFor(f Formatter) []line {
+	if tf, ok := f.(tokenFormatter); ok && tf.usesTokens() {
+		st.ensureHighlighted()
+	}
+
+	return st.lines
+}
+
+// tokenFormatter is implemented by the formatters which may use the syntax
+// highlighting tokens of the lines.
+type tokenFormatter interface {
+	usesTokens() bool
+}
+
+// ensureHighlighted computes the syntax highlighting tokens of every line, if
+// they were not computed yet.
+func (st *sourceTree) ensureHighlighted() {
+	if st.highlighted || st.tree == nil {
+		return
+	}
+
+	st.highlight(st.tree.RootNode(), 0, lineNumber(len(st.lines)-1))
+	st.highlighted = true
+}
+
+// Release drops the syntax tree, the source and the syntax highlighting
+// tokens kept by st, for trees that are only searched. The tree can't be
+// edited afterwards, and its lines have no tokens.
+func (st *sourceTree) Release() {
+	st.tree = nil
+	st.source = nil
+	st.lang = nil
+	for i := range st.lines {
+		st.lines[i].tokens = nil
+	}
+	st.highlighted = true
+}
+
+// build populates the scope information for the lines from first to last,
+// then categorizes them once their scopes are known.
+func (st *sourceTree) build(node *sitter.Node, first lineNumber, last lineNumber) {
+	st.buildScopes(node, first, last)
+	st.categorize(node, first, last)
+}
+
+// buildScopes recursively traverses the tree-sitter abstract syntax tree
+// (AST) to populate the scopes of the lines from first to last. Nodes outside
+// of those lines are skipped.
+func (st *sourceTree) buildScopes(node *sitter.Node, first lineNumber, last lineNumber) {
 	childCount := int(node.ChildCount())
 
 	startLine := node.StartPoint().Row
 	endLine := node.EndPoint().Row
 
+	if endLine < first || startLine > last {
+		return
+	}
+
 	if !node.IsNamed() { // If the node is not named, it is a leaf node and has no scope information.
 		for i := range childCount {
-			st.build(node.Child(i))
+			st.buildScopes(node.Child(i), first, last)
 		}
 		return
 	}
 
 	nodeSize := endLine - startLine
 
-	if nodeSize > 0 && (st.lines[startLine].scope.size() == 0 || nodeSize > st.lines[startLine].scope.size()) {
+	if startLine >= first && nodeSize > 0 && (st.lines[startLine].scope.size() == 0 || nodeSize > st.lines[startLine].scope.size()) {
 		st.lines[startLine].scope.start = startLine
 		st.lines[startLine].scope.end = endLine
+		st.lines[startLine].scope.kind = node.Type()
+	}
+	if startLine >= first {
+		s := &st.lines[startLine].scope
+		if nodeSize > 0 && !slices.Contains(s.kinds, node.Type()) {
+			s.kinds = append(s.kinds, node.Type())
+		}
 	}
 
 	for i := range childCount {
 		child := node.Child(i)
 		childLine := child.StartPoint().Row
 
-		if startLine != childLine {
+		if startLine != childLine && childLine >= first && childLine <= last {
 			if st.lines[childLine].scope.parent == 0 {
 				st.lines[childLine].scope.parent = startLine
 			}
 		}
 
-		st.build(child)
+		st.buildScopes(child, first, last)
+	}
+}
+
+// categorize sets the category of the lines from first to last to the one
+// of the first node starting on them that belongs to the outermost node of
+// the line, the one ending last apart from the root. Wrappers such as exports
+// and variable declarations start on the same line as the function or class
+// they hold and end with it, and signatures are single line nodes in some
+// grammars, followed by the body or within the outermost node. Other nodes,
+// such as a single line function literal in the condition of an if
+// statement, are nested in the outermost node and don't categorize the line.
+func (st *sourceTree) categorize(root *sitter.Node, first lineNumber, last lineNumber) {
+	ends := make([]lineNumber, last-first+1)
+	for i := range int(root.ChildCount()) {
+		outerEnds(root.Child(i), first, last, ends)
+	}
+	for i := range int(root.ChildCount()) {
+		st.categorizeNode(root.Child(i), first, last, ends)
+	}
+}
+
+// outerEnds sets ends to the last line of the outermost node starting on each
+// line from first to last, among node and its descendants.
+func outerEnds(node *sitter.Node, first lineNumber, last lineNumber, ends []lineNumber) {
+	startLine, endLine := node.StartPoint().Row, node.EndPoint().Row
+	if endLine < first || startLine > last {
+		return
+	}
+
+	if startLine >= first && node.IsNamed() {
+		ends[startLine-first] = max(ends[startLine-first], endLine)
+	}
+
+	for i := range int(node.ChildCount()) {
+		outerEnds(node.Child(i), first, last, ends)
+	}
+}
+
+// categorizeNode sets the category of the line node starts on, unless it is
+// already set or node doesn't belong to the outermost node of the line, then
+// categorizes its descendants.
+func (st *sourceTree) categorizeNode(node *sitter.Node, first lineNumber, last lineNumber, ends []lineNumber) {
+	startLine, endLine := node.StartPoint().Row, node.EndPoint().Row
+	if endLine < first || startLine > last {
+		return
+	}
+
+	if startLine >= first && node.IsNamed() {
+		s := &st.lines[startLine].scope
+		if s.category == "" && endsWith(node, ends[startLine-first]) {
+			s.category = nodeCategory(st.langName, node)
+		}
+	}
+
+	for i := range int(node.ChildCount()) {
+		st.categorizeNode(node.Child(i), first, last, ends)
 	}
 }
 
+// endsWith reports whether node, its parent or the node following it ends on
+// line end.
+func endsWith(node *sitter.Node, end lineNumber) bool {
+	if node.EndPoint().Row == end {
+		return true
+	}
+	if parent := node.Parent(); parent != nil && parent.EndPoint().Row == end {
+		return true
+	}
+	next := node.NextNamedSibling()
+	return next != nil && next.EndPoint().Row == end
+}
+
 // Search finds all lines that match a given regular expression pattern and returns
-// their line numbers.
-func (st *sourceTree) Search(pattern string) (Set[lineNumber], error) {
+// their line numbers. The options control how the pattern is interpreted.
+func (st *sourceTree) Search(pattern string, opts ...SearchOption) (Set[lineNumber], error) {
+	return st.SearchAny([]string{pattern}, opts...)
+}
+
+// SearchAny finds all lines that match at least one of the given patterns and
+// returns their line numbers.
+func (st *sourceTree) SearchAny(patterns []string, opts ...SearchOption) (Set[lineNumber], error) {
+	matchers, err := newMatchers(patterns, opts...)
+	if err != nil {
+		return nil, err
+	}
+
 	linesOfInterest := NewSet[lineNumber]()
-	re, err := regexp.Compile(pattern)
+	for _, m := range matchers {
+		for line := range st.matchLines(m) {
+			linesOfInterest.Add(line)
+		}
+	}
+
+	return linesOfInterest, nil
+}
+
+// SearchScopes evaluates a query against the scopes of the given Kinds, every
+// function and method by default, and returns the lines matching the Any and
+// All patterns within the scopes that satisfy it. Each scope is evaluated on
+// its own, including nested ones, so a class is never reported for patterns
+// found in different methods. Trees without functions or methods evaluate
+// every scope instead, reporting the outermost ones that satisfy the query. If
+// the query has no Any or All patterns, the start lines of the satisfying
+// scopes are returned instead. The root scope is never considered.
+func (st *sourceTree) SearchScopes(q ScopeQuery, opts ...SearchOption) (Set[lineNumber], error) {
+	compile := func(patterns []string) ([]Set[lineNumber], error) {
+		matchers, err := newMatchers(patterns, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		matches := make([]Set[lineNumber], len(matchers))
+		for i, m := range matchers {
+			matches[i] = st.matchLines(m)
+		}
+		return matches, nil
+	}
+
+	anyMatches, err := compile(q.Any)
+	if err != nil {
+		return nil, err
+	}
+	allMatches, err := compile(q.All)
 	if err != nil {
-		return nil, fmt.Errorf("failed to compile regex pattern: %w", err)
+		return nil, err
+	}
+	noneMatches, err := compile(q.None)
+	if err != nil {
+		return nil, err
 	}
 
-	for i, line := range st.lines {
-		if re.MatchString(line.text) {
-			linesOfInterest.Add(lineNumber(i))
+	satisfies := func(s scope) bool {
+		if len(anyMatches) > 0 && !slices.ContainsFunc(anyMatches, s.contains) {
+			return false
+		}
+		for _, matches := range allMatches {
+			if !s.contains(matches) {
+				return false
+			}
+		}
+		return !slices.ContainsFunc(noneMatches, s.contains)
+	}
+
+	positive := append(slices.Clone(anyMatches), allMatches...)
+
+	kinds := q.Kinds
+	if len(kinds) == 0 && st.hasScopeKind(ScopeKindFunction, ScopeKindMethod) {
+		kinds = []string{ScopeKindFunction, ScopeKindMethod}
+	}
+
+	linesOfInterest := NewSet[lineNumber]()
+	var reported *scope
+	for i := 1; i < len(st.lines); i++ {
+		s := st.lines[i].scope
+		if s.size() == 0 || !satisfies(s) {
+			continue
+		}
+		if len(kinds) > 0 {
+			if !slices.ContainsFunc(kinds, s.isKind) {
+				continue
+			}
+		} else if reported != nil && s.start <= reported.end {
+			continue
+		}
+		reported = &s
+
+		if len(positive) == 0 {
+			linesOfInterest.Add(s.start)
+			continue
+		}
+
+		for line := range s.children() {
+			for _, matches := range positive {
+				if matches.Has(line) {
+					linesOfInterest.Add(line)
+				}
+			}
 		}
 	}
 
 	return linesOfInterest, nil
 }
 
+// hasScopeKind reports whether the tree has a scope of any of the given kinds.
+func (st *sourceTree) hasScopeKind(kinds ...string) bool {
+	for i := 1; i < len(st.lines); i++ {
+		s := st.lines[i].scope
+		if s.size() > 0 && slices.ContainsFunc(kinds, s.isKind) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Matches finds every occurrence of the given patterns and returns them
+// ordered by position. Unlike Search, it reports each match on a line.
+func (st *sourceTree) Matches(patterns []string, opts ...SearchOption) ([]Match, error) {
+	matchers, err := newMatchers(patterns, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Match
+	for i, m := range matchers {
+		for _, match := range findMatches(st.lines, m) {
+			match.Pattern = i
+			matches = append(matches, match)
+		}
+	}
+	sortMatches(matches)
+
+	return matches, nil
+}
+
+// matchLines returns the lines matched by m. Multiline matchers run over the
+// whole source and report every line covered by each match.
+func (st *sourceTree) matchLines(m *matcher) Set[lineNumber] {
+	lines := NewSet[lineNumber]()
+
+	if m.multiline {
+		for _, match := range findMatches(st.lines, m) {
+			for line := match.StartLine; line <= match.lastLine(); line++ {
+				lines.Add(line)
+			}
+		}
+
+		return lines
+	}
+
+	for i, line := range st.lines {
+		if m.match(line.text) {
+			lines.Add(lineNumber(i))
+		}
+	}
+
+	return lines
+}
+
 func (st *sourceTree) TopLevel() Set[lineNumber] {
 	lines := NewSet[lineNumber]()
 	for _, line := range st.lines {
@@ -55,6 +55,11 @@ func main() { // Line 4
 		if parentOfPrint != 5 {
 			t.Errorf("expected line 7's parent to be 5, but got %d", parentOfPrint)
 		}
+
+		// Scopes keep the node type that defines them.
+		if kind := st.lines[4].scope.kind; kind != "function_declaration" {
+			t.Errorf("expected line 4's scope kind to be function_declaration, but got %q", kind)
+		}
 	})
 
 	t.Run("returns an error if reading fails", func(t *testing.T) {
@@ -76,6 +81,36 @@ func main() { // Line 4
 	})
 }
 
+func TestSourceTree_Release(t *testing.T) {
+	const source = "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n"
+
+	st, err := NewSourceTree(context.Background(), strings.NewReader(source), "test.go")
+	if err != nil {
+		t.Fatalf("failed to create sourceTree: %v", err)
+	}
+	st.Release()
+
+	for i, l := range st.Lines() {
+		if expected := strings.Split(source, "\n")[i]; l.Text() != expected {
+			t.Errorf("expected line %d to be %q, got %q", i, expected, l.Text())
+		}
+		if len(l.tokens) > 0 {
+			t.Errorf("expected line %d to have no tokens, got %v", i, l.tokens)
+		}
+	}
+
+	lines, err := st.Search("hello")
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if !lines.Has(3) || len(lines) != 1 {
+		t.Errorf("expected the search to match line 3, got %v", lines.ToSlice())
+	}
+	if st.lines[3].scope.parent != 2 {
+		t.Errorf("expected line 3's parent to be 2, got %d", st.lines[3].scope.parent)
+	}
+}
+
 func TestSearch(t *testing.T) {
 	const sourceForSearch = `package main
 
@@ -101,6 +136,7 @@ func main() {
 	testCases := []struct {
 		name          string
 		pattern       string
+		opts          []SearchOption
 		expectedLines Set[lineNumber]
 		expectErr     bool
 	}{
@@ -133,11 +169,107 @@ func main() {
 			pattern:   `[`,
 			expectErr: true,
 		},
+		{
+			name:          "matches fixed strings literally",
+			pattern:       `fmt.Println("inside")`,
+			opts:          []SearchOption{WithFixedStrings(true)},
+			expectedLines: NewSetFromSlice([]lineNumber{9}),
+			expectErr:     false,
+		},
+		{
+			name:          "fixed strings do not need escaping",
+			pattern:       `[`,
+			opts:          []SearchOption{WithFixedStrings(true)},
+			expectedLines: NewSetFromSlice([]lineNumber{}),
+			expectErr:     false,
+		},
+		{
+			name:          "is case-sensitive by default",
+			pattern:       `a comment`,
+			expectedLines: NewSetFromSlice([]lineNumber{}),
+			expectErr:     false,
+		},
+		{
+			name:          "ignores case",
+			pattern:       `a COMMENT`,
+			opts:          []SearchOption{WithIgnoreCase(true)},
+			expectedLines: NewSetFromSlice([]lineNumber{8}),
+			expectErr:     false,
+		},
+		{
+			name:          "smart case ignores case for lowercase patterns",
+			pattern:       `a comment`,
+			opts:          []SearchOption{WithSmartCase(true)},
+			expectedLines: NewSetFromSlice([]lineNumber{8}),
+			expectErr:     false,
+		},
+		{
+			name:          "smart case respects case for uppercase patterns",
+			pattern:       `a Comment`,
+			opts:          []SearchOption{WithSmartCase(true)},
+			expectedLines: NewSetFromSlice([]lineNumber{}),
+			expectErr:     false,
+		},
+		{
+			name:          "smart case ignores escape sequences",
+			pattern:       `\Sprintln`,
+			opts:          []SearchOption{WithSmartCase(true)},
+			expectedLines: NewSetFromSlice([]lineNumber{5, 9}),
+			expectErr:     false,
+		},
+		{
+			name:          "whole word does not match inside words",
+			pattern:       `rue`,
+			opts:          []SearchOption{WithWholeWord(true)},
+			expectedLines: NewSetFromSlice([]lineNumber{}),
+			expectErr:     false,
+		},
+		{
+			name:          "whole word matches complete words",
+			pattern:       `true`,
+			opts:          []SearchOption{WithWholeWord(true)},
+			expectedLines: NewSetFromSlice([]lineNumber{7}),
+			expectErr:     false,
+		},
+		{
+			name:          "whole word works with fixed strings",
+			pattern:       `fmt.Println(`,
+			opts:          []SearchOption{WithWholeWord(true), WithFixedStrings(true)},
+			expectedLines: NewSetFromSlice([]lineNumber{5, 9}),
+			expectErr:     false,
+		},
+		{
+			name:          "does not match across lines by default",
+			pattern:       `if true \{.*\s*// A comment`,
+			expectedLines: NewSetFromSlice([]lineNumber{}),
+			expectErr:     false,
+		},
+		{
+			name:          "multiline matches span several lines",
+			pattern:       `if true \{.*\s*// A comment`,
+			opts:          []SearchOption{WithMultiline(true)},
+			expectedLines: NewSetFromSlice([]lineNumber{7, 8}),
+			expectErr:     false,
+		},
+		{
+			name:          "multiline reports every covered line",
+			pattern:       `"start"\).*$\s+if`,
+			opts:          []SearchOption{WithMultiline(true)},
+			expectedLines: NewSetFromSlice([]lineNumber{5, 6, 7}),
+			expectErr:     false,
+		},
+		{
+			name:          "multiline keeps line anchors",
+			pattern:       `^\treturn`,
+			opts:          []SearchOption{WithMultiline(true)},
+			expectedLines: NewSetFromSlice([]lineNumber{12}),
+			expectErr:     false,
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			lines, err := st.Search(tc.pattern)
+			lines, err := st.Search(tc.pattern, tc.opts...)
 
 			if tc.expectErr {
 				if err == nil {
@@ -157,6 +289,340 @@ func main() {
 	}
 }
 
+func TestSearchAny(t *testing.T) {
+	const source = `package main
+
+func main() {
+	fmt.Println("start") // Line 3
+	return // Line 4
+}
+`
+	st := mustNewSourceTree(t, source)
+
+	t.Run("finds lines matching any pattern", func(t *testing.T) {
+		lines, err := st.SearchAny([]string{`"start"`, `return`})
+		if err != nil {
+			t.Fatalf("did not expect an error, but got: %v", err)
+		}
+
+		expected := NewSetFromSlice([]lineNumber{3, 4})
+		if !reflect.DeepEqual(lines, expected) {
+			t.Errorf("expected lines %v, but got %v", expected, lines)
+		}
+	})
+
+	t.Run("returns an error if any pattern is invalid", func(t *testing.T) {
+		if _, err := st.SearchAny([]string{`main`, `[`}); err == nil {
+			t.Fatal("expected an error but got none")
+		}
+	})
+}
+
+func TestMatches(t *testing.T) {
+	const source = `package main
+
+func main() {
+	fmt.Println("a"); fmt.Println("b") // Line 3
+	if err != nil {
+		return nil
+	}
+}
+`
+	st := mustNewSourceTree(t, source)
+
+	t.Run("reports every match with its position", func(t *testing.T) {
+		matches, err := st.Matches([]string{`fmt`, `main`})
+		if err != nil {
+			t.Fatalf("did not expect an error, but got: %v", err)
+		}
+
+		expected := []Match{
+			{Pattern: 1, StartLine: 0, StartColumn: 8, EndLine: 0, EndColumn: 12},
+			{Pattern: 1, StartLine: 2, StartColumn: 5, EndLine: 2, EndColumn: 9},
+			{Pattern: 0, StartLine: 3, StartColumn: 1, EndLine: 3, EndColumn: 4},
+			{Pattern: 0, StartLine: 3, StartColumn: 19, EndLine: 3, EndColumn: 22},
+		}
+		if !reflect.DeepEqual(matches, expected) {
+			t.Errorf("expected matches %+v, but got %+v", expected, matches)
+		}
+	})
+
+	t.Run("reports multiline matches across lines", func(t *testing.T) {
+		matches, err := st.Matches([]string{`err != nil \{\s*return nil`}, WithMultiline(true))
+		if err != nil {
+			t.Fatalf("did not expect an error, but got: %v", err)
+		}
+
+		expected := []Match{{Pattern: 0, StartLine: 4, StartColumn: 4, EndLine: 5, EndColumn: 12}}
+		if !reflect.DeepEqual(matches, expected) {
+			t.Errorf("expected matches %+v, but got %+v", expected, matches)
+		}
+	})
+
+	t.Run("reports adjacent whole word matches", func(t *testing.T) {
+		st := mustNewSourceTree(t, "package main\n\nvar x = foo+foo + food\n")
+
+		matches, err := st.Matches([]string{`foo`}, WithWholeWord(true))
+		if err != nil {
+			t.Fatalf("did not expect an error, but got: %v", err)
+		}
+
+		expected := []Match{
+			{Pattern: 0, StartLine: 2, StartColumn: 8, EndLine: 2, EndColumn: 11},
+			{Pattern: 0, StartLine: 2, StartColumn: 12, EndLine: 2, EndColumn: 15},
+		}
+		if !reflect.DeepEqual(matches, expected) {
+			t.Errorf("expected matches %+v, but got %+v", expected, matches)
+		}
+	})
+
+	t.Run("reports whole words matched by a later alternative", func(t *testing.T) {
+		st := mustNewSourceTree(t, "package main\n\nvar x = abc + abcd\n")
+
+		matches, err := st.Matches([]string{`ab|abc`}, WithWholeWord(true))
+		if err != nil {
+			t.Fatalf("did not expect an error, but got: %v", err)
+		}
+
+		expected := []Match{{Pattern: 0, StartLine: 2, StartColumn: 8, EndLine: 2, EndColumn: 11}}
+		if !reflect.DeepEqual(matches, expected) {
+			t.Errorf("expected matches %+v, but got %+v", expected, matches)
+		}
+	})
+}
+
+func TestSearchScopes(t *testing.T) {
+	const source = `package main
+
+func create(db *DB) error { // Line 2
+	tx := db.Begin() // Line 3
+	tx.Exec("insert")
+	return tx.Commit() // Line 5
+}
+
+func update(db *DB) error { // Line 8
+	tx := db.Begin() // Line 9
+	if err := tx.Exec("update"); err != nil { // Line 10
+		return err
+	}
+	return nil
+}
+
+func read(db *DB) error { // Line 16
+	return db.Query("select") // Line 17
+}
+`
+	st := mustNewSourceTree(t, source)
+
+	testCases := []struct {
+		name          string
+		query         ScopeQuery
+		expectedLines Set[lineNumber]
+	}{
+		{
+			name:          "finds scopes containing all patterns",
+			query:         ScopeQuery{All: []string{`Begin`, `Commit`}},
+			expectedLines: NewSetFromSlice([]lineNumber{3, 5}),
+		},
+		{
+			name:          "excludes scopes containing a negated pattern",
+			query:         ScopeQuery{All: []string{`Begin`}, None: []string{`Commit`}},
+			expectedLines: NewSetFromSlice([]lineNumber{9}),
+		},
+		{
+			name:          "combines any and all patterns",
+			query:         ScopeQuery{Any: []string{`Query`, `Exec`}, All: []string{`db`}},
+			expectedLines: NewSetFromSlice([]lineNumber{2, 3, 4, 8, 9, 10, 16, 17}),
+		},
+		{
+			name:          "returns function start lines without positive patterns",
+			query:         ScopeQuery{None: []string{`Begin`}},
+			expectedLines: NewSetFromSlice([]lineNumber{16}),
+		},
+		{
+			// The `if` scope on line 10 satisfies the query even though its function does not.
+			name:          "restricts the query to scope kinds",
+			query:         ScopeQuery{None: []string{`Begin`}, Kinds: []string{"if_statement"}},
+			expectedLines: NewSetFromSlice([]lineNumber{10}),
+		},
+		{
+			name:          "finds functions that never check a pattern",
+			query:         ScopeQuery{None: []string{`err != nil`}, Kinds: []string{ScopeKindFunction}},
+			expectedLines: NewSetFromSlice([]lineNumber{2, 16}),
+		},
+		{
+			name:          "accepts raw node types as kinds",
+			query:         ScopeQuery{All: []string{`Exec`}, Kinds: []string{"if_statement"}},
+			expectedLines: NewSetFromSlice([]lineNumber{10}),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lines, err := st.SearchScopes(tc.query)
+			if err != nil {
+				t.Fatalf("did not expect an error, but got: %v", err)
+			}
+
+			if !reflect.DeepEqual(lines, tc.expectedLines) {
+				t.Errorf("expected lines %v, but got %v", tc.expectedLines, lines)
+			}
+		})
+	}
+}
+
+func TestSearchScopes_Methods(t *testing.T) {
+	const source = `// Store persists records.
+class Store {
+  save() {
+    this.db.begin(); // Line 3
+  }
+
+  close() {
+    this.db.commit(); // Line 7
+  }
+
+  flush() {
+    this.db.begin(); // Line 11
+    this.db.commit(); // Line 12
+  }
+}
+`
+	st, err := NewSourceTree(context.Background(), strings.NewReader(source), "store.js")
+	if err != nil {
+		t.Fatalf("failed to create sourceTree: %v", err)
+	}
+
+	t.Run("does not combine patterns found in sibling methods", func(t *testing.T) {
+		lines, err := st.SearchScopes(ScopeQuery{All: []string{`begin`, `commit`}})
+		if err != nil {
+			t.Fatalf("did not expect an error, but got: %v", err)
+		}
+
+		if expected := NewSetFromSlice([]lineNumber{11, 12}); !reflect.DeepEqual(lines, expected) {
+			t.Errorf("expected lines %v, but got %v", expected, lines)
+		}
+	})
+
+	t.Run("evaluates classes when asked to", func(t *testing.T) {
+		lines, err := st.SearchScopes(ScopeQuery{All: []string{`begin`, `commit`}, Kinds: []string{ScopeKindClass}})
+		if err != nil {
+			t.Fatalf("did not expect an error, but got: %v", err)
+		}
+
+		if expected := NewSetFromSlice([]lineNumber{3, 7, 11, 12}); !reflect.DeepEqual(lines, expected) {
+			t.Errorf("expected lines %v, but got %v", expected, lines)
+		}
+	})
+}
+
+func TestSearchScopes_Kinds(t *testing.T) {
+	const javascript = `// Line 0
+export function save() {
+  db.begin();
+}
+const load = () => {
+  db.begin();
+};
+const point = {
+  x: 1,
+};
+class Store {
+  flush() {
+    items.forEach((item) => {
+      db.write(item);
+    });
+  }
+}
+export default class Cache {
+}
+`
+	const typescript = `// Line 0
+export const handler = async (req: Request): Promise<void> => {
+  await db.begin();
+};
+export interface Repository {
+  find(id: string): Promise<Row>;
+}
+export abstract class Base {
+  abstract save(): void;
+}
+export class Store extends Base {
+  save(): void {
+    db.begin();
+  }
+}
+`
+	const golang = `package main
+
+type point struct {
+	x int
+}
+
+var origin = point{
+	x: 0,
+}
+
+func (p point) move() {
+}
+
+func main() {
+	if err := run(func() { x() }); err != nil {
+		return
+	}
+	go func() {
+	}()
+}
+`
+	const python = `# Line 0
+class Store:
+    def save(self):
+        pass
+
+def main():
+    pass
+`
+
+	testCases := []struct {
+		name          string
+		filename      string
+		source        string
+		kind          string
+		expectedLines Set[lineNumber]
+	}{
+		{"finds exported and assigned javascript functions", "test.js", javascript, ScopeKindFunction, NewSetFromSlice([]lineNumber{1, 4, 12})},
+		{"finds javascript methods", "test.js", javascript, ScopeKindMethod, NewSetFromSlice([]lineNumber{11})},
+		{"finds javascript classes", "test.js", javascript, ScopeKindClass, NewSetFromSlice([]lineNumber{10, 17})},
+		{"finds javascript wrapper node types", "test.js", javascript, "export_statement", NewSetFromSlice([]lineNumber{1, 17})},
+		{"finds exported typescript arrow functions", "test.ts", typescript, ScopeKindFunction, NewSetFromSlice([]lineNumber{1})},
+		{"finds typescript methods", "test.ts", typescript, ScopeKindMethod, NewSetFromSlice([]lineNumber{11})},
+		{"finds typescript classes and interfaces", "test.ts", typescript, ScopeKindClass, NewSetFromSlice([]lineNumber{4, 7, 10})},
+		{"does not count go struct literals as classes", "test.go", golang, ScopeKindClass, NewSetFromSlice([]lineNumber{2})},
+		{"finds go methods", "test.go", golang, ScopeKindMethod, NewSetFromSlice([]lineNumber{10})},
+		{"finds go functions but not single line literals", "test.go", golang, ScopeKindFunction, NewSetFromSlice([]lineNumber{13, 17})},
+		{"finds python methods in classes", "test.py", python, ScopeKindMethod, NewSetFromSlice([]lineNumber{2})},
+		{"finds python functions", "test.py", python, ScopeKindFunction, NewSetFromSlice([]lineNumber{5})},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			st, err := NewSourceTree(context.Background(), strings.NewReader(tc.source), tc.filename)
+			if err != nil {
+				t.Fatalf("failed to create sourceTree: %v", err)
+			}
+
+			lines, err := st.SearchScopes(ScopeQuery{None: []string{`never`}, Kinds: []string{tc.kind}})
+			if err != nil {
+				t.Fatalf("did not expect an error, but got: %v", err)
+			}
+
+			if !reflect.DeepEqual(lines, tc.expectedLines) {
+				t.Errorf("expected lines %v, but got %v", tc.expectedLines, lines)
+			}
+		})
+	}
+}
+
 func TestTopLevel(t *testing.T) {
 	const sourceForSearch = `package main
 
@@ -0,0 +1,46 @@
+package searchast
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SearchStats holds counters about a search, using the names of the
+// statistics reported by ripgrep. A search is the search of a single file.
+type SearchStats struct {
+	Elapsed           time.Duration
+	Searches          int
+	SearchesWithMatch int
+	ParseErrors       int
+	BytesSearched     int64
+	BytesPrinted      int64
+	MatchedLines      int
+	Matches           int
+}
+
+// Add adds the counters of other to the stats.
+func (s *SearchStats) Add(other SearchStats) {
+	s.Elapsed += other.Elapsed
+	s.Searches += other.Searches
+	s.SearchesWithMatch += other.SearchesWithMatch
+	s.ParseErrors += other.ParseErrors
+	s.BytesSearched += other.BytesSearched
+	s.BytesPrinted += other.BytesPrinted
+	s.MatchedLines += other.MatchedLines
+	s.Matches += other.Matches
+}
+
+// String returns a human readable summary of the stats, one counter per line.
+func (s SearchStats) String() string {
+	output := strings.Builder{}
+	fmt.Fprintf(&output, "%d matches\n", s.Matches)
+	fmt.Fprintf(&output, "%d matched lines\n", s.MatchedLines)
+	fmt.Fprintf(&output, "%d files contained matches\n", s.SearchesWithMatch)
+	fmt.Fprintf(&output, "%d files searched\n", s.Searches)
+	fmt.Fprintf(&output, "%d parse errors\n", s.ParseErrors)
+	fmt.Fprintf(&output, "%d bytes searched\n", s.BytesSearched)
+	fmt.Fprintf(&output, "%.6f seconds\n", s.Elapsed.Seconds())
+
+	return output.String()
+}
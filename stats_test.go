@@ -0,0 +1,25 @@
+package searchast
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSearchStats(t *testing.T) {
+	stats := SearchStats{Searches: 1, BytesSearched: 10}
+	stats.Add(SearchStats{Searches: 1, SearchesWithMatch: 1, MatchedLines: 2, Matches: 3, BytesSearched: 20})
+	stats.Add(SearchStats{ParseErrors: 1})
+	stats.Elapsed = 1500 * time.Millisecond
+
+	expected := `3 matches
+2 matched lines
+1 files contained matches
+2 files searched
+1 parse errors
+30 bytes searched
+1.500000 seconds
+`
+	if stats.String() != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, stats.String())
+	}
+}
@@ -0,0 +1,76 @@
+package searchast
+
+import (
+	"strings"
+)
+
+// SourceTree is the tree returned by NewSourceTree, exported under this name
+// so that other packages can keep trees around.
+type SourceTree = sourceTree
+
+// Symbol is a scope of the source spanning several lines, such as a function
+// or a class. Lines are 0-based.
+type Symbol struct {
+	// Name is the text of the first line of the scope, without indentation
+	// and trailing openers such as "{" or ":".
+	Name string
+	// Kind is the tree-sitter node type of the scope.
+	Kind string
+	// Category is the ScopeKind category of Kind, or empty if there is none.
+	Category  string
+	StartLine lineNumber
+	EndLine   lineNumber
+	Children  []Symbol
+}
+
+// Symbols returns the scope tree of the source, nested as in the source and
+// ordered by start line. The root scope spanning the whole file is omitted.
+func (st *sourceTree) Symbols() []Symbol {
+	var root []Symbol
+	// stack holds the path of symbols containing the current line, each
+	// pointing into the children of the previous one. Children are only
+	// appended to the last one, so the pointers stay valid.
+	var stack []*Symbol
+
+	rootSeen := false
+	for i, l := range st.lines {
+		s := l.scope
+		if s.size() == 0 || s.start != lineNumber(i) {
+			continue
+		}
+		if !rootSeen {
+			rootSeen = true
+			if int(s.end) == len(st.lines)-1 {
+				continue
+			}
+		}
+
+		// Nodes sharing a line may overlap, a scope that does not fit in the
+		// previous ones is not nested in them.
+		for len(stack) > 0 && stack[len(stack)-1].EndLine < s.end {
+			stack = stack[:len(stack)-1]
+		}
+
+		symbol := Symbol{
+			Name:      symbolName(l.text),
+			Kind:      s.kind,
+			Category:  s.category,
+			StartLine: s.start,
+			EndLine:   s.end,
+		}
+
+		siblings := &root
+		if len(stack) > 0 {
+			siblings = &stack[len(stack)-1].Children
+		}
+		*siblings = append(*siblings, symbol)
+		stack = append(stack, &(*siblings)[len(*siblings)-1])
+	}
+
+	return root
+}
+
+// symbolName returns the name of a symbol starting on a line of text.
+func symbolName(text string) string {
+	return strings.TrimRight(strings.TrimSpace(text), " \t{([:=")
+}
@@ -0,0 +1,69 @@
+package searchast
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSymbols(t *testing.T) {
+	testCases := []struct {
+		name     string
+		source   string
+		expected []Symbol
+	}{
+		{
+			name: "nests scopes",
+			source: `package main
+
+type point struct {
+	x, y int
+}
+
+func main() {
+	if true {
+		println("yes")
+	} else {
+		println("no")
+	}
+}
+`,
+			expected: []Symbol{
+				{Name: "type point struct", Kind: "type_declaration", Category: ScopeKindClass, StartLine: 2, EndLine: 4},
+				{Name: "func main()", Kind: "function_declaration", Category: ScopeKindFunction, StartLine: 6, EndLine: 12, Children: []Symbol{
+					{Name: "if true", Kind: "if_statement", StartLine: 7, EndLine: 11, Children: []Symbol{
+						{Name: "} else", Kind: "block", StartLine: 9, EndLine: 11},
+					}},
+				}},
+			},
+		},
+		{
+			name: "omits the root scope after leading lines",
+			source: `
+// Package main is an example.
+package main
+
+func main() {
+}
+`,
+			expected: []Symbol{
+				{Name: "func main()", Kind: "function_declaration", Category: ScopeKindFunction, StartLine: 4, EndLine: 5},
+			},
+		},
+		{
+			name:     "returns no symbols for a single line",
+			source:   "package main",
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			st := mustNewSourceTree(t, tc.source)
+
+			symbols := st.Symbols()
+			if !reflect.DeepEqual(symbols, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, symbols)
+			}
+		})
+	}
+}
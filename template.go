@@ -0,0 +1,133 @@
+package searchast
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// TemplateFile is the data a template is executed with, once per file.
+type TemplateFile struct {
+	Filename string
+	Language string
+	// Lines holds the lines to show in order, with a gap entry in place of
+	// each run of hidden lines.
+	Lines []TemplateLine
+}
+
+// TemplateLine is a shown line or a gap. Line numbers are 1-based.
+type TemplateLine struct {
+	Number  int
+	Text    string
+	IsMatch bool
+	// ScopeStart and ScopeEnd delimit the innermost scope containing the
+	// line. Lines outside any scope are their own scope.
+	ScopeStart int
+	ScopeEnd   int
+	// IsGap reports whether the entry stands for Hidden hidden lines rather
+	// than a shown line. Only Number, the first hidden line, is set otherwise.
+	IsGap  bool
+	Hidden int
+}
+
+// ParseTemplate parses a template for the TemplateFormatter. Besides the
+// text/template builtins, templates can use csv and json to quote a string as
+// a CSV field or a JSON string.
+func ParseTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("searchast").Funcs(template.FuncMap{
+		"csv":  csvField,
+		"json": jsonString,
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// TemplateFormatter renders the lines to show with a text/template, so the
+// output can be customized without writing a formatter.
+type TemplateFormatter struct {
+	filename string
+	language string
+	tmpl     *template.Template
+}
+
+// NewTemplateFormatter creates a formatter for the given file. language is
+// passed to the template and may be empty.
+func NewTemplateFormatter(filename string, language string, tmpl *template.Template) *TemplateFormatter {
+	return &TemplateFormatter{
+		filename: filename,
+		language: language,
+		tmpl:     tmpl,
+	}
+}
+
+// Format returns the output of the template. If the template fails, it returns
+// the output written before the failure, use FormatTo to get the error.
+func (tf *TemplateFormatter) Format(lines []line, linesToShow Set[lineNumber], linesToHighlight Set[lineNumber]) string {
+	output := strings.Builder{}
+	_ = tf.FormatTo(&output, lines, linesToShow, linesToHighlight)
+
+	return output.String()
+}
+
+// FormatTo executes the template with the file and writes the result to w.
+// Nothing is written if there are no lines to show or highlight.
+func (tf *TemplateFormatter) FormatTo(w io.Writer, lines []line, linesToShow Set[lineNumber], linesToHighlight Set[lineNumber]) error {
+	if len(linesToShow) == 0 || len(linesToHighlight) == 0 {
+		return nil
+	}
+
+	file := TemplateFile{
+		Filename: tf.filename,
+		Language: tf.language,
+	}
+
+	for i, l := range lines {
+		if !linesToShow.Has(lineNumber(i)) {
+			if last := len(file.Lines) - 1; last >= 0 && file.Lines[last].IsGap {
+				file.Lines[last].Hidden++
+			} else {
+				file.Lines = append(file.Lines, TemplateLine{Number: i + 1, IsGap: true, Hidden: 1})
+			}
+			continue
+		}
+
+		s := l.scope
+		if s.size() == 0 && s.parent != 0 {
+			s = lines[s.parent].scope
+		}
+
+		file.Lines = append(file.Lines, TemplateLine{
+			Number:     i + 1,
+			Text:       l.text,
+			IsMatch:    linesToHighlight.Has(lineNumber(i)),
+			ScopeStart: int(s.start) + 1,
+			ScopeEnd:   int(s.end) + 1,
+		})
+	}
+
+	if err := tf.tmpl.Execute(w, file); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return nil
+}
+
+// csvField quotes s as a CSV field if needed.
+func csvField(s string) string {
+	if !strings.ContainsAny(s, ",\"\r\n") && strings.TrimSpace(s) == s {
+		return s
+	}
+
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// jsonString quotes s as a JSON string.
+func jsonString(s string) (string, error) {
+	quoted, err := json.Marshal(s)
+	return string(quoted), err
+}
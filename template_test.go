@@ -0,0 +1,77 @@
+package searchast
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTemplateFormatter_Format(t *testing.T) {
+	source := `package main
+
+func main() {
+	fmt.Println("a, b")
+}`
+
+	st := mustNewSourceTree(t, source)
+	linesOfInterest := NewSetFromSlice([]lineNumber{3})
+	linesToShow := NewSetFromSlice([]lineNumber{2, 3, 4})
+
+	testCases := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{
+			name:     "passes the file name and language",
+			template: `{{.Filename}} ({{.Language}})`,
+			expected: "main.go (go)",
+		},
+		{
+			name:     "passes the lines with gaps",
+			template: `{{range .Lines}}{{if .IsGap}}~{{.Hidden}} at {{.Number}}{{else}}{{.Number}}{{if .IsMatch}}*{{end}}{{end}};{{end}}`,
+			expected: "~2 at 1;3;4*;5;",
+		},
+		{
+			name:     "passes the innermost scope of each line",
+			template: `{{range .Lines}}{{if not .IsGap}}{{.Number}}:{{.ScopeStart}}-{{.ScopeEnd}} {{end}}{{end}}`,
+			expected: "3:3-5 4:3-5 5:3-5 ",
+		},
+		{
+			name:     "quotes CSV fields and JSON strings",
+			template: `{{range .Lines}}{{if .IsMatch}}{{csv .Text}}|{{json .Text}}{{end}}{{end}}`,
+			expected: `"	fmt.Println(""a, b"")"|"\tfmt.Println(\"a, b\")"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl, err := ParseTemplate(tc.template)
+			if err != nil {
+				t.Fatalf("failed to parse template: %v", err)
+			}
+
+			output := NewTemplateFormatter("main.go", "go", tmpl).Format(st.Lines(), linesToShow, linesOfInterest)
+			if output != tc.expected {
+				t.Errorf("expected output:\n%s\nbut got:\n%s", tc.expected, output)
+			}
+		})
+	}
+
+	t.Run("returns template errors", func(t *testing.T) {
+		tmpl, err := ParseTemplate(`{{.Missing}}`)
+		if err != nil {
+			t.Fatalf("failed to parse template: %v", err)
+		}
+
+		err = NewTemplateFormatter("main.go", "go", tmpl).FormatTo(&strings.Builder{}, st.Lines(), linesToShow, linesOfInterest)
+		if err == nil {
+			t.Fatal("expected an error but got none")
+		}
+	})
+
+	t.Run("returns parse errors", func(t *testing.T) {
+		if _, err := ParseTemplate(`{{.Lines`); err == nil {
+			t.Fatal("expected an error but got none")
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package searchast
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Color is an RGB color used by syntax highlighting themes.
+type Color struct {
+	R, G, B uint8
+}
+
+// ColorDepth selects the ANSI escape sequences used to render theme colors.
+type ColorDepth int
+
+const (
+	// Color256 renders colors with the xterm 256-color palette.
+	Color256 ColorDepth = iota
+	// TrueColor renders colors as 24-bit RGB.
+	TrueColor
+)
+
+// Theme maps token classes ("keyword", "string", "comment", "number",
+// "constant", "type", "function" and "identifier") to colors. Classes without
+// a color are printed with the default terminal color.
+type Theme struct {
+	Name            string
+	Colors          map[string]Color
+	MatchBackground Color
+}
+
+var themes = map[string]*Theme{
+	"dark": {
+		Name: "dark",
+		Colors: map[string]Color{
+			tokenKeyword:  {0xff, 0x7b, 0x72},
+			tokenString:   {0xa5, 0xd6, 0xff},
+			tokenComment:  {0x8b, 0x94, 0x9e},
+			tokenNumber:   {0x79, 0xc0, 0xff},
+			tokenConstant: {0x79, 0xc0, 0xff},
+			tokenType:     {0xff, 0xa6, 0x57},
+			tokenFunction: {0xd2, 0xa8, 0xff},
+		},
+		MatchBackground: Color{0x3a, 0x2e, 0x00},
+	},
+	"light": {
+		Name: "light",
+		Colors: map[string]Color{
+			tokenKeyword:  {0xcf, 0x22, 0x2e},
+			tokenString:   {0x0a, 0x30, 0x69},
+			tokenComment:  {0x6e, 0x77, 0x81},
+			tokenNumber:   {0x05, 0x50, 0xae},
+			tokenConstant: {0x05, 0x50, 0xae},
+			tokenType:     {0x95, 0x38, 0x00},
+			tokenFunction: {0x82, 0x50, 0xdf},
+		},
+		MatchBackground: Color{0xff, 0xf8, 0xc5},
+	},
+	"monokai": {
+		Name: "monokai",
+		Colors: map[string]Color{
+			tokenKeyword:  {0xf9, 0x26, 0x72},
+			tokenString:   {0xe6, 0xdb, 0x74},
+			tokenComment:  {0x75, 0x71, 0x5e},
+			tokenNumber:   {0xae, 0x81, 0xff},
+			tokenConstant: {0xae, 0x81, 0xff},
+			tokenType:     {0x66, 0xd9, 0xef},
+			tokenFunction: {0xa6, 0xe2, 0x2e},
+		},
+		MatchBackground: Color{0x49, 0x48, 0x3e},
+	},
+}
+
+// ThemeByName returns one of the built-in themes.
+func ThemeByName(name string) (*Theme, error) {
+	theme, exists := themes[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown theme %s, available themes: %s", name, strings.Join(ThemeNames(), ", "))
+	}
+
+	return theme, nil
+}
+
+// ThemeNames returns the names of the built-in themes, sorted.
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// foreground returns the escape sequence setting c as the text color.
+func (c Color) foreground(depth ColorDepth) string {
+	if depth == TrueColor {
+		return fmt.Sprintf("\033[38;2;%d;%d;%dm", c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("\033[38;5;%dm", c.to256())
+}
+
+// background returns the escape sequence setting c as the background color.
+func (c Color) background(depth ColorDepth) string {
+	if depth == TrueColor {
+		return fmt.Sprintf("\033[48;2;%d;%d;%dm", c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("\033[48;5;%dm", c.to256())
+}
+
+// to256 returns the closest color of the xterm 256-color palette, using the
+// grayscale ramp for gray colors and the 6x6x6 color cube otherwise.
+func (c Color) to256() int {
+	if c.R == c.G && c.G == c.B {
+		switch {
+		case c.R < 8:
+			return 16
+		case c.R > 238:
+			return 231
+		default:
+			return 232 + (int(c.R)-8)*24/231
+		}
+	}
+
+	level := func(v uint8) int {
+		return (int(v)*5 + 127) / 255
+	}
+
+	return 16 + 36*level(c.R) + 6*level(c.G) + level(c.B)
+}
+
+// highlightLine renders the line with the colors of the theme. Matched lines
+// are drawn on the theme's match background.
+func (t *Theme) highlightLine(l line, isMatch bool, depth ColorDepth) string {
+	output := strings.Builder{}
+	if isMatch {
+		output.WriteString(t.MatchBackground.background(depth))
+	}
+
+	for text, class := range l.segments() {
+		color, exists := t.Colors[class]
+		if !exists {
+			output.WriteString(text)
+			continue
+		}
+
+		output.WriteString(color.foreground(depth))
+		output.WriteString(text)
+		output.WriteString(ansiCodeDefaultForeground)
+	}
+
+	if isMatch {
+		output.WriteString(ansiCodeReset)
+	}
+
+	return output.String()
+}
@@ -0,0 +1,96 @@
+package searchast
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestThemeByName(t *testing.T) {
+	for _, name := range ThemeNames() {
+		t.Run(name, func(t *testing.T) {
+			theme, err := ThemeByName(name)
+			if err != nil {
+				t.Fatalf("expected no error, but got: %v", err)
+			}
+			if theme.Name != name {
+				t.Errorf("expected theme %s, got %s", name, theme.Name)
+			}
+		})
+	}
+
+	t.Run("returns an error for unknown themes", func(t *testing.T) {
+		if _, err := ThemeByName("nonexistent"); err == nil {
+			t.Fatal("expected an error but got none")
+		}
+	})
+}
+
+func TestColor_to256(t *testing.T) {
+	testCases := []struct {
+		name     string
+		color    Color
+		expected int
+	}{
+		{"black", Color{0, 0, 0}, 16},
+		{"white", Color{255, 255, 255}, 231},
+		{"pure red", Color{255, 0, 0}, 196},
+		{"pure blue", Color{0, 0, 255}, 21},
+		{"gray", Color{128, 128, 128}, 244},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.color.to256(); got != tc.expected {
+				t.Errorf("expected %d, got %d", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestTextFormatter_SyntaxHighlighting(t *testing.T) {
+	source := `package main
+
+func main() {
+	return
+}`
+
+	st := mustNewSourceTree(t, source)
+	linesOfInterest := NewSetFromSlice([]lineNumber{3})
+	linesToShow := NewSetFromSlice([]lineNumber{2, 3, 4})
+	theme, err := ThemeByName("dark")
+	if err != nil {
+		t.Fatalf("failed to get theme: %v", err)
+	}
+
+	t.Run("colors tokens with truecolor sequences", func(t *testing.T) {
+		formatter := NewTextFormatter(WithSyntaxHighlighting(theme), WithColorDepth(TrueColor))
+		output := formatter.Format(st.Lines(), linesToShow, linesOfInterest)
+
+		keyword := theme.Colors[tokenKeyword]
+		expected := keyword.foreground(TrueColor) + "func" + ansiCodeDefaultForeground
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected output to contain %q, got: %q", expected, output)
+		}
+		if !strings.Contains(output, "\033[38;2;") {
+			t.Errorf("expected truecolor sequences, got: %q", output)
+		}
+	})
+
+	t.Run("colors tokens with 256-color sequences", func(t *testing.T) {
+		formatter := NewTextFormatter(WithSyntaxHighlighting(theme))
+		output := formatter.Format(st.Lines(), linesToShow, linesOfInterest)
+
+		if !strings.Contains(output, "\033[38;5;") || strings.Contains(output, "\033[38;2;") {
+			t.Errorf("expected only 256-color sequences, got: %q", output)
+		}
+	})
+
+	t.Run("draws matched lines on the match background", func(t *testing.T) {
+		formatter := NewTextFormatter(WithSyntaxHighlighting(theme))
+		output := formatter.Format(st.Lines(), linesToShow, linesOfInterest)
+
+		if count := strings.Count(output, theme.MatchBackground.background(Color256)); count != 1 {
+			t.Errorf("expected the match background once, got %d times in: %q", count, output)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+// Package walk lists the source files of directories, as searched by the
+// searchast command and its servers.
+package walk
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/andersonjoseph/searchast/language"
+)
+
+// Files expands paths into the files to search. Directories are walked
+// recursively for files with a supported language, skipping .git directories
+// and the entries for which ignored, if not nil, returns true. Files passed
+// explicitly are always included.
+func Files(paths []string, ignored func(path string) bool) ([]string, error) {
+	var files []string
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(current string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if current != path && ignored != nil && ignored(current) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if d.IsDir() {
+				if current != path && d.Name() == ".git" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if _, err := language.FromFilename(current); err == nil {
+				files = append(files, current)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk %s: %w", path, err)
+		}
+	}
+
+	return files, nil
+}
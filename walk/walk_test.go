@@ -0,0 +1,70 @@
+package walk
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"main.go", "README.md", "vendor/lib.go", ".git/hooks/hook.go", "sub/util.py"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ignoreVendor := func(path string) bool { return filepath.Base(path) == "vendor" }
+
+	tests := []struct {
+		name     string
+		paths    []string
+		ignored  func(path string) bool
+		expected []string
+	}{
+		{
+			name:     "walks directories for supported languages",
+			paths:    []string{dir},
+			expected: []string{"main.go", "sub/util.py", "vendor/lib.go"},
+		},
+		{
+			name:     "skips ignored entries",
+			paths:    []string{dir},
+			ignored:  ignoreVendor,
+			expected: []string{"main.go", "sub/util.py"},
+		},
+		{
+			name:     "includes explicit files",
+			paths:    []string{filepath.Join(dir, "README.md"), filepath.Join(dir, "vendor")},
+			ignored:  ignoreVendor,
+			expected: []string{"README.md", "vendor/lib.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := Files(tt.paths, tt.ignored)
+			if err != nil {
+				t.Fatalf("failed to list files: %v", err)
+			}
+
+			var relative []string
+			for _, file := range files {
+				relative = append(relative, filepath.ToSlash(strings.TrimPrefix(file, dir+string(filepath.Separator))))
+			}
+			if !reflect.DeepEqual(relative, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, relative)
+			}
+		})
+	}
+
+	if _, err := Files([]string{filepath.Join(dir, "missing")}, nil); err == nil {
+		t.Error("expected an error for a missing path")
+	}
+}